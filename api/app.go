@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/anacrolix/torrent/bencode"
 	"github.com/anacrolix/torrent/metainfo"
@@ -89,23 +90,6 @@ type MediaInfoTrack struct {
 	Title          string `json:"Title,omitempty"`
 }
 
-// ============ LOGGING HELPERS ============
-
-// logInfo logs an info-level message with timestamp
-func logInfo(format string, args ...interface{}) {
-	log.Printf("[INFO] "+format, args...)
-}
-
-// logError logs an error-level message with timestamp
-func logError(format string, args ...interface{}) {
-	log.Printf("[ERROR] "+format, args...)
-}
-
-// logWarn logs a warning-level message with timestamp
-func logWarn(format string, args ...interface{}) {
-	log.Printf("[WARN] "+format, args...)
-}
-
 // shortPath returns just the filename/dirname for cleaner logs
 func shortPath(path string) string {
 	return filepath.Base(path)
@@ -439,7 +423,9 @@ func (a *App) GetMediaInfo(filePath string) (*MediaInfoResponse, error) {
 	}
 
 	cmd := exec.Command(path, "--Output=JSON", targetFile)
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	mediainfoDuration.WithLabelValues("json").Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, fmt.Errorf("mediainfo execution failed: %w", err)
 	}
@@ -478,7 +464,9 @@ func (a *App) GetMediaInfoText(filePath string) (string, error) {
 	}
 
 	cmd := exec.Command(path, targetFile)
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	mediainfoDuration.WithLabelValues("text").Observe(time.Since(start).Seconds())
 	if err != nil {
 		return "", fmt.Errorf("mediainfo execution failed: %w", err)
 	}
@@ -498,7 +486,10 @@ func (a *App) GetMediaInfoText(filePath string) (string, error) {
 
 // CreateTorrent creates a .torrent file for the given source path
 // torrentName is the name that will appear in the torrent (the release name)
-func (a *App) CreateTorrent(sourcePath string, trackers []string, comment string, isPrivate bool, torrentName string) (string, error) {
+func (a *App) CreateTorrent(ctx context.Context, sourcePath string, trackers []string, comment string, isPrivate bool, torrentName string) (string, error) {
+	defer trackInProgress("create_torrent")()
+	start := time.Now()
+
 	info := metainfo.Info{
 		PieceLength: 256 * 1024,
 	}
@@ -510,9 +501,10 @@ func (a *App) CreateTorrent(sourcePath string, trackers []string, comment string
 
 	err := info.BuildFromFilePath(sourcePath)
 	if err != nil {
-		logError("CreateTorrent: failed to build torrent info for %s: %v", shortPath(sourcePath), err)
+		logErrorCtx(ctx, "CreateTorrent: failed to build torrent info for %s: %v", shortPath(sourcePath), err)
 		return "", err
 	}
+	torrentBytesHashed.Add(float64(info.TotalLength()))
 
 	// Utiliser le nom personnalisé si fourni, sinon garder le nom du fichier source
 	if torrentName != "" {
@@ -522,7 +514,7 @@ func (a *App) CreateTorrent(sourcePath string, trackers []string, comment string
 			sourceFileName := filepath.Base(sourcePath)
 			ext := filepath.Ext(sourceFileName)
 			info.Name = torrentName + ext
-			logInfo("CreateTorrent: single-file torrent, using name with extension: %s", info.Name)
+			logInfoCtx(ctx, "CreateTorrent: single-file torrent, using name with extension: %s", info.Name)
 		} else {
 			// Multi-file torrent
 			info.Name = torrentName
@@ -570,18 +562,40 @@ func (a *App) CreateTorrent(sourcePath string, trackers []string, comment string
 
 	outFile, err := os.Create(outputPath)
 	if err != nil {
-		logError("CreateTorrent: failed to create file %s: %v", shortPath(outputPath), err)
+		logErrorCtx(ctx, "CreateTorrent: failed to create file %s: %v", shortPath(outputPath), err)
 		return "", err
 	}
 	defer outFile.Close()
 
 	err = mi.Write(outFile)
 	if err != nil {
-		logError("CreateTorrent: failed to write torrent file: %v", err)
+		logErrorCtx(ctx, "CreateTorrent: failed to write torrent file: %v", err)
 		return "", err
 	}
 
-	logInfo("CreateTorrent: created %s (name: %s)", shortPath(outputPath), torrentName)
+	torrentCreateDuration.Observe(time.Since(start).Seconds())
+	logInfoCtx(ctx, "CreateTorrent: created %s (name: %s)", shortPath(outputPath), torrentName)
+
+	// Record the release in the history so later runs can detect duplicates
+	fingerprint, totalSize, err := ComputeContentFingerprint(sourcePath)
+	if err != nil {
+		logWarnCtx(ctx, "CreateTorrent: could not fingerprint %s: %v", shortPath(sourcePath), err)
+	}
+	releaseName := torrentName
+	if releaseName == "" {
+		releaseName = baseName
+	}
+	if err := recordRelease(Release{
+		Name:        releaseName,
+		SourcePath:  sourcePath,
+		TorrentPath: outputPath,
+		InfoHash:    mi.HashInfoBytes().HexString(),
+		Fingerprint: fingerprint,
+		TotalSize:   totalSize,
+	}); err != nil {
+		logWarnCtx(ctx, "CreateTorrent: could not record release history: %v", err)
+	}
+
 	return outputPath, nil
 }
 
@@ -691,10 +705,10 @@ func (a *App) FindMatchingHardlinkDir(sourcePath string, hardlinkDirs []string)
 
 // CreateHardlink creates hardlinks for the source path in the destination directory
 // torrentName is the release name from the torrent metadata (optional)
-func (a *App) CreateHardlink(sourcePath string, destDir string, torrentName string) (string, error) {
+func (a *App) CreateHardlink(ctx context.Context, sourcePath string, destDir string, torrentName string) (string, error) {
 	sourceInfo, err := os.Stat(sourcePath)
 	if err != nil {
-		logError("CreateHardlink: cannot stat source %s: %v", shortPath(sourcePath), err)
+		logErrorCtx(ctx, "CreateHardlink: cannot stat source %s: %v", shortPath(sourcePath), err)
 		return "", fmt.Errorf("cannot stat source: %w", err)
 	}
 
@@ -715,9 +729,9 @@ func (a *App) CreateHardlink(sourcePath string, destDir string, torrentName stri
 	// Check if destination already exists
 	if _, err := os.Stat(destPath); err == nil {
 		// File/directory already exists - remove it first
-		logInfo("CreateHardlink: destination already exists, removing: %s", shortPath(destPath))
+		logInfoCtx(ctx, "CreateHardlink: destination already exists, removing: %s", shortPath(destPath))
 		if err := os.RemoveAll(destPath); err != nil {
-			logError("CreateHardlink: failed to remove existing destination: %v", err)
+			logErrorCtx(ctx, "CreateHardlink: failed to remove existing destination: %v", err)
 			return "", fmt.Errorf("failed to remove existing destination: %w", err)
 		}
 	}
@@ -726,29 +740,29 @@ func (a *App) CreateHardlink(sourcePath string, destDir string, torrentName stri
 		// For directories, create directory structure and hardlink all files
 		err = a.hardlinkDirectory(sourcePath, destPath)
 		if err != nil {
-			logError("CreateHardlink: failed to hardlink directory: %v", err)
+			logErrorCtx(ctx, "CreateHardlink: failed to hardlink directory: %v", err)
 			return "", err
 		}
 		// Rename the video file inside the directory to match the directory name
 		if err := a.renameVideoInDir(destPath, baseName); err != nil {
-			logWarn("CreateHardlink: could not rename video in %s: %v", shortPath(destPath), err)
+			logWarnCtx(ctx, "CreateHardlink: could not rename video in %s: %v", shortPath(destPath), err)
 		}
 	} else {
 		// For single files, just create the hardlink
 		err = os.Link(sourcePath, destPath)
 		if err != nil {
-			logError("CreateHardlink: failed to create hardlink: %v", err)
+			logErrorCtx(ctx, "CreateHardlink: failed to create hardlink: %v", err)
 			return "", fmt.Errorf("failed to create hardlink: %w", err)
 		}
 	}
 
-	logInfo("CreateHardlink: created %s (name: %s)", shortPath(destPath), torrentName)
+	logInfoCtx(ctx, "CreateHardlink: created %s (name: %s)", shortPath(destPath), torrentName)
 	return destPath, nil
 }
 
 // hardlinkDirectory recursively creates hardlinks for all files in a directory
 func (a *App) hardlinkDirectory(srcDir, destDir string) error {
-	log.Printf("[DEBUG] hardlinkDirectory: %s -> %s", shortPath(srcDir), shortPath(destDir))
+	logDebug("hardlinkDirectory: %s -> %s", shortPath(srcDir), shortPath(destDir))
 	// Create destination directory
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		logError("hardlinkDirectory: failed to create directory %s: %v", shortPath(destDir), err)
@@ -819,14 +833,13 @@ func (a *App) renameVideoInDir(dirPath, newName string) error {
 
 // GetLaCaleTagsPreview returns the La Cale tag names (for display) that would be selected for a given media
 func (a *App) GetLaCaleTagsPreview(mediaType string, releaseInfo ReleaseInfo) ([]string, error) {
-	// Load embedded tags data
-	var meta LocalMetaRoot
-	if err := json.Unmarshal([]byte(tagsData), &meta); err != nil {
-		return nil, fmt.Errorf("failed to parse embedded tags data: %w", err)
+	catalog, err := currentTagCatalog()
+	if err != nil {
+		return nil, err
 	}
 
 	// Find category and characteristics
-	_, relevantChars := findLocalCategory(meta.Categories, mediaType)
+	_, relevantChars := catalog.Category(mediaType)
 	if len(relevantChars) == 0 {
 		return []string{}, nil
 	}
@@ -851,14 +864,13 @@ type TagInfo struct {
 
 // GetLaCaleAllTags returns all available tags organized by category, plus the auto-selected tags
 func (a *App) GetLaCaleAllTags(mediaType string, releaseInfo ReleaseInfo) ([]TagCategory, []string, error) {
-	// Load embedded tags data
-	var meta LocalMetaRoot
-	if err := json.Unmarshal([]byte(tagsData), &meta); err != nil {
-		return nil, nil, fmt.Errorf("failed to parse embedded tags data: %w", err)
+	catalog, err := currentTagCatalog()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Find category and characteristics for this media type
-	_, relevantChars := findLocalCategory(meta.Categories, mediaType)
+	_, relevantChars := catalog.Category(mediaType)
 	if len(relevantChars) == 0 {
 		return []TagCategory{}, []string{}, nil
 	}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// backupFormatVersion is bumped whenever the BackupArchive layout changes
+// 1: settings, processed files and releases; 2: client and seeding state of the releases, built-in seeder torrents
+const backupFormatVersion = 2
+
+// BackupArchive is the portable export of aatm.db
+// Tracker profiles, client instances and routing rules are part of the settings. Two tables are left out on purpose:
+// tag_catalogs is synced again from La-Cale, and tracker_sessions is encrypted with the key of this install.
+type BackupArchive struct {
+	Version        int                 `json:"version"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	Settings       AppSettings         `json:"settings"`
+	ProcessedFiles []map[string]string `json:"processedFiles"`
+	Releases       []Release           `json:"releases"`
+	SeederTorrents []seederTorrent     `json:"seederTorrents"`
+}
+
+// SettingChange describes one settings field that a restore would modify
+type SettingChange struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Incoming interface{} `json:"incoming"`
+}
+
+// RestoreReport is the diff between the current database and a backup archive
+type RestoreReport struct {
+	DryRun            bool            `json:"dryRun"`
+	SettingsErrors    []SettingsError `json:"settingsErrors,omitempty"`
+	SettingsChanges   []SettingChange `json:"settingsChanges"`
+	NewProcessedFiles int             `json:"newProcessedFiles"`
+	NewReleases       int             `json:"newReleases"`
+	ExistingReleases  int             `json:"existingReleases"`
+	NewSeederTorrents int             `json:"newSeederTorrents"`
+	ArchiveVersion    int             `json:"archiveVersion"`
+	ArchiveCreatedAt  time.Time       `json:"archiveCreatedAt"`
+	SupportedVersion  int             `json:"supportedVersion"`
+}
+
+// ExportBackup builds a BackupArchive from the current database
+func (a *App) ExportBackup() (*BackupArchive, error) {
+	processed, err := a.GetAllProcessedFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read processed files: %w", err)
+	}
+	if processed == nil {
+		processed = []map[string]string{}
+	}
+	releases, err := a.GetReleases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read releases: %w", err)
+	}
+	seederTorrents, err := querySeederTorrents("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seeder torrents: %w", err)
+	}
+	if seederTorrents == nil {
+		seederTorrents = []seederTorrent{}
+	}
+	// Export the stored settings: values forced by the environment belong to the deployment
+	stored, _ := loadStoredSettings()
+	return &BackupArchive{
+		Version:        backupFormatVersion,
+		CreatedAt:      time.Now().UTC(),
+		Settings:       stored,
+		ProcessedFiles: processed,
+		Releases:       releases,
+		SeederTorrents: seederTorrents,
+	}, nil
+}
+
+// RestoreBackup compares the archive with the database and, unless dryRun is set, applies it
+// Settings are replaced; processed files, releases and seeder torrents are merged with the existing ones.
+// Archives whose settings don't pass validateSettingsToSave are only reported, never applied.
+func (a *App) RestoreBackup(archive BackupArchive, dryRun bool) (*RestoreReport, error) {
+	if archive.Version < 1 || archive.Version > backupFormatVersion {
+		return nil, fmt.Errorf("unsupported backup version %d (supported: 1-%d)", archive.Version, backupFormatVersion)
+	}
+
+	// Archives written before a field existed leave it empty: it gets its default, as after POST /api/settings
+	settingsErrors, err := validateSettingsToSave(archive.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, _ := loadStoredSettings()
+	report := &RestoreReport{
+		DryRun:           dryRun,
+		SettingsChanges:  diffSettings(stored, archive.Settings),
+		ArchiveVersion:   archive.Version,
+		ArchiveCreatedAt: archive.CreatedAt,
+		SupportedVersion: backupFormatVersion,
+		SettingsErrors:   settingsErrors,
+	}
+
+	for _, f := range archive.ProcessedFiles {
+		if f["path"] != "" && !isProcessed(f["path"]) {
+			report.NewProcessedFiles++
+		}
+	}
+	for _, r := range archive.Releases {
+		var exists int
+		err := db.QueryRow("SELECT 1 FROM releases WHERE info_hash = ? OR torrent_path = ?", r.InfoHash, r.TorrentPath).Scan(&exists)
+		if err == nil {
+			report.ExistingReleases++
+		} else {
+			report.NewReleases++
+		}
+	}
+	for _, t := range archive.SeederTorrents {
+		if _, err := seederTorrentRow(t.InfoHash); errors.Is(err, ErrTorrentNotFound) {
+			report.NewSeederTorrents++
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if len(settingsErrors) > 0 {
+		return report, fmt.Errorf("the backup settings are invalid (%d errors)", len(settingsErrors))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	data, err := json.Marshal(archive.Settings)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO settings (id, data) VALUES (1, ?)", string(data)); err != nil {
+		return nil, fmt.Errorf("failed to restore settings: %w", err)
+	}
+
+	for _, f := range archive.ProcessedFiles {
+		if f["path"] == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO processed_files (path, processed_at) VALUES (?, COALESCE(NULLIF(?, ''), CURRENT_TIMESTAMP))",
+			f["path"], f["processedAt"]); err != nil {
+			return nil, fmt.Errorf("failed to restore processed file: %w", err)
+		}
+	}
+
+	// Releases are keyed like recordRelease does, on the infohash or the torrent file
+	for _, r := range archive.Releases {
+		var clients interface{}
+		if len(r.Clients) > 0 {
+			data, err := json.Marshal(r.Clients)
+			if err != nil {
+				return nil, err
+			}
+			clients = string(data)
+		}
+		if _, err := tx.Exec(`INSERT INTO releases (name, source_path, torrent_path, info_hash, fingerprint, total_size, created_at,
+			client, clients, client_added_at, seed_state, seed_progress, seed_uploaded, seed_ratio, tracker_status, seed_error,
+			seeded_at, seed_checked_at, seed_timed_out)
+			SELECT ?, ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), CURRENT_TIMESTAMP),
+			NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?
+			WHERE NOT EXISTS (SELECT 1 FROM releases WHERE info_hash = ? OR torrent_path = ?)`,
+			r.Name, r.SourcePath, r.TorrentPath, r.InfoHash, r.Fingerprint, r.TotalSize, r.CreatedAt,
+			r.Client, clients, r.ClientAddedAt, r.SeedState, r.SeedProgress, r.SeedUploaded, r.SeedRatio, r.TrackerStatus, r.SeedError,
+			r.SeededAt, r.SeedCheckedAt, r.SeedTimedOut,
+			r.InfoHash, r.TorrentPath); err != nil {
+			return nil, fmt.Errorf("failed to restore release: %w", err)
+		}
+	}
+
+	for _, t := range archive.SeederTorrents {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO seeder_torrents (info_hash, name, torrent_path, save_dir, size, uploaded, paused, added_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), CURRENT_TIMESTAMP))`,
+			strings.ToLower(t.InfoHash), t.Name, t.TorrentPath, t.SaveDir, t.Size, t.Uploaded, t.Paused, t.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to restore seeder torrent: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Same runtime configuration as after saving the settings
+	configureLogging(a.GetSettings())
+	if err := a.ConfigureSeeder(a.GetSettings()); err != nil {
+		logError("RestoreBackup: seeder: %v", err)
+	}
+	logInfo("RestoreBackup: restored archive from %s (%d settings changes, %d processed files, %d releases, %d seeder torrents)",
+		archive.CreatedAt.Format(time.RFC3339), len(report.SettingsChanges), report.NewProcessedFiles, report.NewReleases,
+		report.NewSeederTorrents)
+	return report, nil
+}
+
+// diffSettings lists the fields that differ between two settings, masking secrets
+func diffSettings(current, incoming AppSettings) []SettingChange {
+	changes := []SettingChange{}
+	cv := reflect.ValueOf(current)
+	iv := reflect.ValueOf(incoming)
+	for _, f := range appSettingsFields() {
+		c := cv.Field(f.Index).Interface()
+		n := iv.Field(f.Index).Interface()
+		if reflect.DeepEqual(c, n) {
+			continue
+		}
+		if isSecretField(f.Name) {
+			c, n = "********", "********"
+		}
+		changes = append(changes, SettingChange{Field: f.Name, Current: c, Incoming: n})
+	}
+	return changes
+}
+
+// isSecretField reports whether a settings field holds a credential
+func isSecretField(field string) bool {
+	f := strings.ToLower(field)
+	return strings.Contains(f, "password") || strings.Contains(f, "passkey") ||
+		strings.Contains(f, "token") || strings.Contains(f, "apikey") || strings.Contains(f, "secret")
+}
+
+// backupDir returns the directory holding automatic database snapshots
+func backupDir() string {
+	return filepath.Join(dataDir, "backups")
+}
+
+// SnapshotDatabase copies the live database into the backups directory using SQLite's online backup API
+func (a *App) SnapshotDatabase() (string, error) {
+	dir := backupDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	dest := filepath.Join(dir, "aatm-"+time.Now().Format("20060102-150405")+".db")
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn interface{}) error {
+		bc, ok := driverConn.(interface {
+			NewBackup(string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("sqlite driver does not support online backup")
+		}
+		bk, err := bc.NewBackup(dest)
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = bk.Step(-1); err != nil {
+				bk.Finish()
+				return err
+			}
+		}
+		return bk.Finish()
+	})
+	if err != nil {
+		os.Remove(dest)
+		return "", fmt.Errorf("database snapshot failed: %w", err)
+	}
+
+	logInfo("SnapshotDatabase: created %s", shortPath(dest))
+	return dest, nil
+}
+
+// pruneSnapshots removes the oldest snapshots so that at most keep remain
+func pruneSnapshots(keep int) error {
+	matches, err := filepath.Glob(filepath.Join(backupDir(), "aatm-*.db"))
+	if err != nil {
+		return err
+	}
+	if len(matches) <= keep {
+		return nil
+	}
+	// Names embed the timestamp, so lexical order is chronological order
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-keep] {
+		if err := os.Remove(old); err != nil {
+			logWarn("pruneSnapshots: could not remove %s: %v", shortPath(old), err)
+			continue
+		}
+		logInfo("pruneSnapshots: removed %s", shortPath(old))
+	}
+	return nil
+}
+
+// lastSnapshotTime returns the modification time of the most recent snapshot (zero if none)
+func lastSnapshotTime() time.Time {
+	matches, _ := filepath.Glob(filepath.Join(backupDir(), "aatm-*.db"))
+	var last time.Time
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last
+}
+
+// StartBackupScheduler periodically snapshots the database when auto backup is enabled
+// Settings are re-read on every tick so changes apply without a restart.
+func (a *App) StartBackupScheduler() {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			settings := a.GetSettings()
+			interval := time.Duration(settings.BackupIntervalHours) * time.Hour
+			if settings.EnableAutoBackup && time.Since(lastSnapshotTime()) >= interval {
+				if _, err := a.SnapshotDatabase(); err != nil {
+					logError("BackupScheduler: %v", err)
+				} else if err := pruneSnapshots(settings.BackupRetention); err != nil {
+					logWarn("BackupScheduler: pruning failed: %v", err)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
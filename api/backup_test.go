@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	initTestDB(t)
+	app := &App{}
+
+	settings := getDefaultSettings()
+	settings.RootPath = t.TempDir()
+	settings.TrackerProfiles = []TrackerProfile{{Name: "blutopia", Type: "unit3d", URL: "https://blutopia.test", APIKey: "token"}}
+	settings.ClientInstances = []ClientInstance{{Name: "seedbox", Type: "transmission", URL: "http://seedbox.test:9091"}}
+	settings.ClientRoutes = []ClientRoute{{Tracker: "blutopia", Clients: []string{"seedbox"}}}
+	if err := app.SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.MarkProcessed("/data/Movie.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordRelease(Release{Name: "Movie", SourcePath: "/data/Movie.mkv", TorrentPath: "/data/Movie.torrent",
+		InfoHash: "aaaa", Fingerprint: "fp", TotalSize: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if err := markReleaseAddedToClient("aaaa", []string{"qbittorrent", "seedbox"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO seeder_torrents (info_hash, name, torrent_path, save_dir, size, uploaded, paused)
+		VALUES ('bbbb', 'Show', '/data/Show.torrent', '/data', 100, 250, 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := app.ExportBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Version != backupFormatVersion || len(exported.Releases) != 1 || len(exported.SeederTorrents) != 1 {
+		t.Fatalf("export = %+v", exported)
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restored into an empty database, the archive gives back the same content
+	for _, table := range []string{"settings", "processed_files", "releases", "seeder_torrents"} {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var archive BackupArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatal(err)
+	}
+	report, err := app.RestoreBackup(archive, false)
+	if err != nil {
+		t.Fatalf("restore: %v (%+v)", err, report.SettingsErrors)
+	}
+	if report.NewReleases != 1 || report.NewProcessedFiles != 1 || report.NewSeederTorrents != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	restored, err := app.ExportBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Row ids are assigned again
+	for i := range restored.Releases {
+		restored.Releases[i].ID = exported.Releases[i].ID
+	}
+	restored.CreatedAt = exported.CreatedAt
+	if !reflect.DeepEqual(restored, exported) {
+		t.Errorf("restored archive differs:\n got %+v\nwant %+v", restored, exported)
+	}
+
+	// A second restore adds nothing
+	report, err = app.RestoreBackup(archive, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NewReleases != 0 || report.ExistingReleases != 1 || report.NewProcessedFiles != 0 || report.NewSeederTorrents != 0 {
+		t.Errorf("second restore report = %+v", report)
+	}
+}
+
+func TestRestoreBackupVersions(t *testing.T) {
+	initTestDB(t)
+	app := &App{}
+	settings := getDefaultSettings()
+	settings.RootPath = t.TempDir()
+	// A version 1 archive has no seeder torrents nor release tracking
+	v1 := BackupArchive{Version: 1, Settings: settings, Releases: []Release{{Name: "Movie", TorrentPath: "/data/Movie.torrent", InfoHash: "aaaa"}}}
+	report, err := app.RestoreBackup(v1, false)
+	if err != nil {
+		t.Fatalf("restore v1: %v (%+v)", err, report)
+	}
+	if releases, _ := app.GetReleases(); len(releases) != 1 || releases[0].Client != "" {
+		t.Errorf("releases = %+v", releases)
+	}
+	if _, err := app.RestoreBackup(BackupArchive{Version: backupFormatVersion + 1, Settings: settings}, true); err == nil {
+		t.Error("restore of a newer archive version succeeded")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerTorrentClient("aria2", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return newAria2Client(s.Aria2Url, s.Aria2Secret)
+		},
+		key: func(s AppSettings) string {
+			return s.Aria2Url + "\x00" + s.Aria2Secret
+		},
+	})
+}
+
+// aria2StatusKeys are the aria2.tellStatus keys needed to build a TorrentStatus
+var aria2StatusKeys = []string{
+	"gid", "status", "infoHash", "totalLength", "completedLength", "uploadLength", "dir", "errorMessage", "bittorrent",
+}
+
+// aria2Client talks to the aria2 JSON-RPC interface (--enable-rpc)
+type aria2Client struct {
+	rpcURL string
+	secret string
+	http   *http.Client
+
+	mu     sync.Mutex
+	nextID int
+}
+
+// aria2RPCError is an error returned in the "error" member of a response
+type aria2RPCError struct {
+	Method  string
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *aria2RPCError) Error() string {
+	return fmt.Sprintf("%s: aria2 error %d: %s", e.Method, e.Code, e.Message)
+}
+
+func newAria2Client(rpcURL, secret string) (*aria2Client, error) {
+	if rpcURL == "" {
+		return nil, fmt.Errorf("aria2 URL is not configured")
+	}
+	return &aria2Client{
+		rpcURL: rpcURL,
+		secret: secret,
+		http:   &http.Client{Timeout: torrentClientTimeout},
+	}, nil
+}
+
+func (c *aria2Client) Name() string { return "aria2" }
+
+func (c *aria2Client) Capabilities() ClientCapabilities {
+	// bt-seed-unverified skips the hash check of existing files
+	return ClientCapabilities{SavePath: true, SkipChecking: true}
+}
+
+// Version returns the aria2 version and its enabled features
+func (c *aria2Client) Version(ctx context.Context) (string, map[string]string, error) {
+	raw, err := c.call(ctx, "aria2.getVersion")
+	if err != nil {
+		return "", nil, err
+	}
+	var version struct {
+		Version         string   `json:"version"`
+		EnabledFeatures []string `json:"enabledFeatures"`
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return "", nil, fmt.Errorf("invalid aria2.getVersion response: %w", err)
+	}
+	return version.Version, map[string]string{"features": strings.Join(version.EnabledFeatures, ", ")}, nil
+}
+
+// call sends a JSON-RPC request, prepending the secret token when one is configured
+func (c *aria2Client) call(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+	if c.secret != "" {
+		params = append([]interface{}{"token:" + c.secret}, params...)
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      strconv.Itoa(id),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	// aria2 answers errors with a 4xx status and a JSON-RPC error body
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *aria2RPCError  `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("%s: status %d: failed to decode response: %w", method, resp.StatusCode, err)
+	}
+	if rpcResp.Error != nil {
+		rpcResp.Error.Method = method
+		return nil, rpcResp.Error
+	}
+	return rpcResp.Result, nil
+}
+
+func (c *aria2Client) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	torrentData, err := os.ReadFile(torrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to read torrent file: %w", err)
+	}
+	// aria2 option values are strings
+	options := map[string]string{
+		// Existing files are verified instead of being downloaded again
+		"check-integrity": "true",
+		"pause":           strconv.FormatBool(opts.Paused),
+	}
+	if opts.SavePath != "" {
+		options["dir"] = opts.SavePath
+	}
+	if opts.SkipChecking {
+		options["check-integrity"] = "false"
+		options["bt-seed-unverified"] = "true"
+	}
+	_, err = c.call(ctx, "aria2.addTorrent", base64.StdEncoding.EncodeToString(torrentData), []string{}, options)
+	if err != nil {
+		return fmt.Errorf("failed to add torrent to aria2: %w", err)
+	}
+	return nil
+}
+
+// aria2Download is an entry of aria2.tellActive/tellWaiting/tellStopped (numbers are strings)
+type aria2Download struct {
+	GID             string `json:"gid"`
+	Status          string `json:"status"`
+	InfoHash        string `json:"infoHash"`
+	TotalLength     string `json:"totalLength"`
+	CompletedLength string `json:"completedLength"`
+	UploadLength    string `json:"uploadLength"`
+	Dir             string `json:"dir"`
+	ErrorMessage    string `json:"errorMessage"`
+	Bittorrent      struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"`
+	} `json:"bittorrent"`
+}
+
+func (d aria2Download) toStatus() TorrentStatus {
+	total, _ := strconv.ParseInt(d.TotalLength, 10, 64)
+	completed, _ := strconv.ParseInt(d.CompletedLength, 10, 64)
+	uploaded, _ := strconv.ParseInt(d.UploadLength, 10, 64)
+	status := TorrentStatus{
+		InfoHash: strings.ToLower(d.InfoHash),
+		Name:     d.Bittorrent.Info.Name,
+		Size:     total,
+		Uploaded: uploaded,
+		SavePath: d.Dir,
+	}
+	if total > 0 {
+		status.Progress = float64(completed) / float64(total)
+		status.Ratio = float64(uploaded) / float64(total)
+	}
+	switch d.Status {
+	case "active":
+		// A finished torrent stays active while seeding
+		if total > 0 && completed == total {
+			status.State = "seeding"
+		} else {
+			status.State = "downloading"
+		}
+	case "waiting":
+		status.State = "queued"
+	case "paused", "complete":
+		status.State = "paused"
+	case "error":
+		status.State = "error"
+		status.Error = d.ErrorMessage
+	default:
+		status.State = "unknown"
+	}
+	return status
+}
+
+// downloads returns the torrent downloads of every queue (active, waiting and stopped)
+func (c *aria2Client) downloads(ctx context.Context) ([]aria2Download, error) {
+	var all []aria2Download
+	queries := []struct {
+		method string
+		params []interface{}
+	}{
+		{"aria2.tellActive", []interface{}{aria2StatusKeys}},
+		{"aria2.tellWaiting", []interface{}{0, 1000, aria2StatusKeys}},
+		{"aria2.tellStopped", []interface{}{0, 1000, aria2StatusKeys}},
+	}
+	for _, q := range queries {
+		result, err := c.call(ctx, q.method, q.params...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list torrents: %w", err)
+		}
+		var list []aria2Download
+		if err := json.Unmarshal(result, &list); err != nil {
+			return nil, fmt.Errorf("failed to decode torrent list: %w", err)
+		}
+		for _, d := range list {
+			// Plain HTTP/FTP downloads have no info hash
+			if d.InfoHash != "" {
+				all = append(all, d)
+			}
+		}
+	}
+	return all, nil
+}
+
+// find returns the download of a torrent
+func (c *aria2Client) find(ctx context.Context, infoHash string) (*aria2Download, error) {
+	list, err := c.downloads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if strings.EqualFold(list[i].InfoHash, infoHash) {
+			return &list[i], nil
+		}
+	}
+	return nil, ErrTorrentNotFound
+}
+
+func (c *aria2Client) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	if deleteData {
+		return fmt.Errorf("aria2 cannot delete torrent data: %w", ErrNotSupported)
+	}
+	d, err := c.find(ctx, infoHash)
+	if err != nil {
+		return err
+	}
+	if d.Status == "active" || d.Status == "waiting" || d.Status == "paused" {
+		if _, err := c.call(ctx, "aria2.forceRemove", d.GID); err != nil {
+			return fmt.Errorf("failed to remove torrent from aria2: %w", err)
+		}
+	}
+	// Drop the stopped entry so the torrent no longer shows up
+	if _, err := c.call(ctx, "aria2.removeDownloadResult", d.GID); err != nil {
+		logDebug("aria2: removeDownloadResult %s: %v", d.GID, err)
+	}
+	return nil
+}
+
+func (c *aria2Client) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	d, err := c.find(ctx, infoHash)
+	if err != nil {
+		return nil, err
+	}
+	status := d.toStatus()
+	return &status, nil
+}
+
+func (c *aria2Client) List(ctx context.Context) ([]TorrentStatus, error) {
+	list, err := c.downloads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]TorrentStatus, 0, len(list))
+	for _, d := range list {
+		result = append(result, d.toStatus())
+	}
+	return result, nil
+}
+
+func (c *aria2Client) SetCategory(ctx context.Context, infoHash string, category string) error {
+	return fmt.Errorf("aria2 has no categories: %w", ErrNotSupported)
+}
+
+func (c *aria2Client) Recheck(ctx context.Context, infoHash string) error {
+	return fmt.Errorf("aria2 cannot recheck a loaded torrent: %w", ErrNotSupported)
+}
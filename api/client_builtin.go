@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func init() {
+	registerTorrentClient("builtin", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return builtinClient{}, nil
+		},
+		key: func(s AppSettings) string {
+			// The engine is shared and follows the settings itself (see ConfigureSeeder)
+			return ""
+		},
+	})
+}
+
+// builtinClient exposes the built-in seeder as a TorrentClient
+type builtinClient struct{}
+
+func (builtinClient) Name() string { return "builtin" }
+
+func (builtinClient) Capabilities() ClientCapabilities {
+	return ClientCapabilities{Recheck: true, SavePath: true}
+}
+
+func (builtinClient) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	e, err := currentSeeder()
+	if err != nil {
+		return err
+	}
+	if opts.SavePath == "" {
+		return fmt.Errorf("the built-in seeder needs the content path of the torrent")
+	}
+	mi, err := metainfo.LoadFromFile(torrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to load torrent file: %w", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return fmt.Errorf("invalid torrent file: %w", err)
+	}
+
+	row := seederTorrent{
+		InfoHash:    mi.HashInfoBytes().HexString(),
+		Name:        info.BestName(),
+		TorrentPath: torrentPath,
+		SaveDir:     opts.SavePath,
+		Size:        info.TotalLength(),
+		Paused:      opts.Paused,
+	}
+	prev, prevErr := seederTorrentRow(row.InfoHash)
+	// Adding the same torrent again keeps its upload counter
+	_, err = db.Exec(`INSERT INTO seeder_torrents (info_hash, name, torrent_path, save_dir, size, paused)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(info_hash) DO UPDATE SET name = excluded.name, torrent_path = excluded.torrent_path,
+		save_dir = excluded.save_dir, size = excluded.size, paused = excluded.paused`,
+		row.InfoHash, row.Name, row.TorrentPath, row.SaveDir, row.Size, row.Paused)
+	if err != nil {
+		return fmt.Errorf("failed to save seeder torrent: %w", err)
+	}
+	if row.Paused {
+		return nil
+	}
+	// A torrent moved to another directory must be reopened with its new storage
+	e.drop(row.InfoHash)
+	if err := e.start(row); err != nil {
+		return err
+	}
+	if prevErr == nil && prev.SaveDir != row.SaveDir {
+		// The stored piece completion describes the previous directory
+		return builtinClient{}.Recheck(ctx, row.InfoHash)
+	}
+	return nil
+}
+
+func (builtinClient) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	if deleteData {
+		return fmt.Errorf("the built-in seeder never deletes source data: %w", ErrNotSupported)
+	}
+	return removeSeederTorrent(infoHash)
+}
+
+func (builtinClient) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	e, err := currentSeeder()
+	if err != nil {
+		return nil, err
+	}
+	row, err := seederTorrentRow(infoHash)
+	if err != nil {
+		return nil, err
+	}
+	status := e.status(row).TorrentStatus
+	return &status, nil
+}
+
+func (builtinClient) List(ctx context.Context) ([]TorrentStatus, error) {
+	e, err := currentSeeder()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := querySeederTorrents("")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]TorrentStatus, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, e.status(row).TorrentStatus)
+	}
+	return list, nil
+}
+
+func (builtinClient) SetCategory(ctx context.Context, infoHash string, category string) error {
+	return fmt.Errorf("the built-in seeder has no categories: %w", ErrNotSupported)
+}
+
+func (builtinClient) Recheck(ctx context.Context, infoHash string) error {
+	e, err := currentSeeder()
+	if err != nil {
+		return err
+	}
+	t, ok := e.torrent(infoHash)
+	if !ok {
+		return ErrTorrentNotFound
+	}
+	if t.Info() == nil {
+		return fmt.Errorf("torrent metadata is not loaded yet")
+	}
+	e.mu.Lock()
+	if e.checking[infoHash] {
+		e.mu.Unlock()
+		return nil
+	}
+	e.checking[infoHash] = true
+	e.mu.Unlock()
+
+	// VerifyData blocks until every piece was hashed
+	go func() {
+		t.VerifyData()
+		e.mu.Lock()
+		delete(e.checking, infoHash)
+		e.mu.Unlock()
+		logInfo("Seeder: recheck of %s done", t.Name())
+	}()
+	return nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerTorrentClient("deluge", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return newDelugeClient(s.DelugeUrl, s.DelugePassword)
+		},
+		key: func(s AppSettings) string {
+			return s.DelugeUrl + "\x00" + s.DelugePassword
+		},
+	})
+}
+
+// DelugeRPCRequest represents a Deluge JSON-RPC request
+type DelugeRPCRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     int           `json:"id"`
+}
+
+// DelugeRPCResponse represents a Deluge JSON-RPC response
+type DelugeRPCResponse struct {
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+	ID     int         `json:"id"`
+}
+
+// delugeTorrentFields are the core.get_torrent_status keys needed to build a TorrentStatus
+var delugeTorrentFields = []string{
+	"name", "state", "progress", "total_size", "total_uploaded", "ratio", "save_path", "label", "message", "tracker_status",
+}
+
+// delugeNotAuthenticated is the JSON-RPC error code returned once the session cookie expired
+const delugeNotAuthenticated = 1
+
+// delugeClient talks to the Deluge WebUI JSON-RPC, keeping the session cookie between calls
+type delugeClient struct {
+	rpcURL   string
+	password string
+	http     *http.Client
+
+	mu       sync.Mutex
+	nextID   int
+	loggedIn bool
+}
+
+// delugeRPCError is an error returned in the "error" member of a response
+type delugeRPCError struct {
+	Method  string
+	Code    int
+	Message string
+}
+
+func (e *delugeRPCError) Error() string {
+	return fmt.Sprintf("%s: Deluge error: %s", e.Method, e.Message)
+}
+
+func newDelugeClient(baseURL, password string) (*delugeClient, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("Deluge URL is not configured")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &delugeClient{
+		rpcURL:   strings.TrimSuffix(baseURL, "/") + "/json",
+		password: password,
+		http:     &http.Client{Jar: jar, Timeout: torrentClientTimeout},
+	}, nil
+}
+
+func (c *delugeClient) Name() string { return "deluge" }
+
+func (c *delugeClient) Capabilities() ClientCapabilities {
+	// Labels need the Label plugin to be enabled; seed_mode (skip checking) needs Deluge 2
+	return ClientCapabilities{Labels: true, Recheck: true, SavePath: true, SkipChecking: true}
+}
+
+// rawCall sends a single JSON-RPC request without session handling
+func (c *delugeClient) rawCall(ctx context.Context, method string, params ...interface{}) (interface{}, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	body, err := json.Marshal(DelugeRPCRequest{Method: method, Params: params, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d", method, resp.StatusCode)
+	}
+
+	var rpcResp DelugeRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("%s: failed to decode response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		rpcErr := &delugeRPCError{Method: method, Message: fmt.Sprint(rpcResp.Error)}
+		if m, ok := rpcResp.Error.(map[string]interface{}); ok {
+			rpcErr.Message = fmt.Sprint(m["message"])
+			if code, ok := m["code"].(float64); ok {
+				rpcErr.Code = int(code)
+			}
+		}
+		return nil, rpcErr
+	}
+	return rpcResp.Result, nil
+}
+
+// ensureSession logs in and attaches the WebUI to a daemon if needed
+func (c *delugeClient) ensureSession(ctx context.Context) error {
+	c.mu.Lock()
+	loggedIn := c.loggedIn
+	c.mu.Unlock()
+	if loggedIn {
+		return nil
+	}
+
+	result, err := c.rawCall(ctx, "auth.login", c.password)
+	if err != nil {
+		return fmt.Errorf("Deluge login failed: %w", err)
+	}
+	if ok, _ := result.(bool); !ok {
+		return fmt.Errorf("Deluge login failed: invalid password")
+	}
+
+	connected, err := c.rawCall(ctx, "web.connected")
+	if err != nil {
+		return fmt.Errorf("failed to check Deluge connection: %w", err)
+	}
+	if ok, _ := connected.(bool); !ok {
+		// Try to connect to first available host
+		hosts, _ := c.rawCall(ctx, "web.get_hosts")
+		if list, ok := hosts.([]interface{}); ok && len(list) > 0 {
+			if host, ok := list[0].([]interface{}); ok && len(host) > 0 {
+				c.rawCall(ctx, "web.connect", host[0])
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.loggedIn = true
+	c.mu.Unlock()
+	return nil
+}
+
+// call sends a JSON-RPC request, logging in again once if the session expired
+func (c *delugeClient) call(ctx context.Context, method string, params ...interface{}) (interface{}, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.ensureSession(ctx); err != nil {
+			return nil, err
+		}
+		result, err := c.rawCall(ctx, method, params...)
+		if rpcErr, ok := err.(*delugeRPCError); ok && rpcErr.Code == delugeNotAuthenticated && attempt == 0 {
+			c.mu.Lock()
+			c.loggedIn = false
+			c.mu.Unlock()
+			continue
+		}
+		return result, err
+	}
+}
+
+// Version returns the daemon version, failing when the WebUI is not attached to a daemon
+func (c *delugeClient) Version(ctx context.Context) (string, map[string]string, error) {
+	connected, err := c.call(ctx, "web.connected")
+	if err != nil {
+		return "", nil, err
+	}
+	ok, _ := connected.(bool)
+	details := map[string]string{"daemonConnected": fmt.Sprint(ok)}
+	if !ok {
+		// The WebUI is reachable but not attached to a daemon: uploads would fail
+		return "", details, fmt.Errorf("Deluge WebUI is not connected to a daemon")
+	}
+	info, err := c.call(ctx, "daemon.info")
+	if err != nil {
+		return "", details, nil
+	}
+	return fmt.Sprint(info), details, nil
+}
+
+func (c *delugeClient) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	torrentData, err := os.ReadFile(torrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to read torrent file: %w", err)
+	}
+	options := map[string]interface{}{"add_paused": opts.Paused}
+	if opts.SavePath != "" {
+		options["download_location"] = opts.SavePath
+	}
+	if opts.SkipChecking {
+		options["seed_mode"] = true
+	}
+	_, err = c.call(ctx, "core.add_torrent_file",
+		filepath.Base(torrentPath),
+		base64.StdEncoding.EncodeToString(torrentData),
+		options,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add torrent to Deluge: %w", err)
+	}
+
+	if opts.Category != "" {
+		infoHash, err := torrentInfoHash(torrentPath)
+		if err != nil {
+			return err
+		}
+		if err := c.SetCategory(ctx, infoHash, opts.Category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *delugeClient) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	if _, err := c.call(ctx, "core.remove_torrent", infoHash, deleteData); err != nil {
+		return fmt.Errorf("failed to remove torrent from Deluge: %w", err)
+	}
+	return nil
+}
+
+// delugeTorrent is the status dict returned for each torrent
+type delugeTorrent struct {
+	Name          string  `json:"name"`
+	State         string  `json:"state"`
+	Progress      float64 `json:"progress"`
+	TotalSize     int64   `json:"total_size"`
+	TotalUploaded int64   `json:"total_uploaded"`
+	Ratio         float64 `json:"ratio"`
+	SavePath      string  `json:"save_path"`
+	Label         string  `json:"label"`
+	Message       string  `json:"message"`
+	TrackerStatus string  `json:"tracker_status"`
+}
+
+// delugeState maps a Deluge state to a TorrentStatus state
+func delugeState(state string) string {
+	switch state {
+	case "Seeding":
+		return "seeding"
+	case "Downloading", "Allocating":
+		return "downloading"
+	case "Paused":
+		return "paused"
+	case "Checking", "Moving":
+		return "checking"
+	case "Queued":
+		return "queued"
+	case "Error":
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (t delugeTorrent) toStatus(infoHash string) TorrentStatus {
+	status := TorrentStatus{
+		InfoHash:      strings.ToLower(infoHash),
+		Name:          t.Name,
+		State:         delugeState(t.State),
+		Progress:      t.Progress / 100,
+		Size:          t.TotalSize,
+		Uploaded:      t.TotalUploaded,
+		Ratio:         t.Ratio,
+		SavePath:      t.SavePath,
+		Category:      t.Label,
+		TrackerStatus: t.TrackerStatus,
+	}
+	if status.State == "error" {
+		status.Error = t.Message
+	}
+	if status.Ratio < 0 {
+		status.Ratio = 0
+	}
+	return status
+}
+
+// decodeDelugeResult re-encodes a generic result into a typed value
+func decodeDelugeResult(result interface{}, target interface{}) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+func (c *delugeClient) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	result, err := c.call(ctx, "core.get_torrent_status", infoHash, delugeTorrentFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get torrent status: %w", err)
+	}
+	// Unknown torrents give an empty dict
+	if m, ok := result.(map[string]interface{}); !ok || len(m) == 0 {
+		return nil, ErrTorrentNotFound
+	}
+	var t delugeTorrent
+	if err := decodeDelugeResult(result, &t); err != nil {
+		return nil, fmt.Errorf("failed to decode torrent status: %w", err)
+	}
+	status := t.toStatus(infoHash)
+	return &status, nil
+}
+
+func (c *delugeClient) List(ctx context.Context) ([]TorrentStatus, error) {
+	result, err := c.call(ctx, "core.get_torrents_status", map[string]interface{}{}, delugeTorrentFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+	var torrents map[string]delugeTorrent
+	if err := decodeDelugeResult(result, &torrents); err != nil {
+		return nil, fmt.Errorf("failed to decode torrent list: %w", err)
+	}
+	list := make([]TorrentStatus, 0, len(torrents))
+	for hash, t := range torrents {
+		list = append(list, t.toStatus(hash))
+	}
+	return list, nil
+}
+
+func (c *delugeClient) SetCategory(ctx context.Context, infoHash string, category string) error {
+	// The Label plugin only accepts lowercase labels that already exist
+	label := strings.ToLower(category)
+	if label != "" {
+		if _, err := c.call(ctx, "label.add", label); err != nil && !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("failed to create label %q (is the Label plugin enabled?): %w", label, err)
+		}
+	}
+	if _, err := c.call(ctx, "label.set_torrent", infoHash, label); err != nil {
+		return fmt.Errorf("failed to set label: %w", err)
+	}
+	return nil
+}
+
+func (c *delugeClient) Recheck(ctx context.Context, infoHash string) error {
+	if _, err := c.call(ctx, "core.force_recheck", []string{infoHash}); err != nil {
+		return fmt.Errorf("failed to recheck torrent: %w", err)
+	}
+	return nil
+}
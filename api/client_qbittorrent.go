@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerTorrentClient("qbittorrent", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return newQBittorrentClient(s.QbitUrl, s.QbitUsername, s.QbitPassword)
+		},
+		key: func(s AppSettings) string {
+			return strings.Join([]string{s.QbitUrl, s.QbitUsername, s.QbitPassword}, "\x00")
+		},
+	})
+}
+
+// qbitClient talks to the qBittorrent WebUI API v2, keeping the SID cookie between calls
+type qbitClient struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+// qbitHTTPError is a non-200 answer of the WebUI
+type qbitHTTPError struct {
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *qbitHTTPError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("%s: status %d: %s", e.Path, e.Status, e.Body)
+	}
+	return fmt.Sprintf("%s: status %d", e.Path, e.Status)
+}
+
+func newQBittorrentClient(baseURL, username, password string) (*qbitClient, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("qBittorrent URL is not configured")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &qbitClient{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Jar: jar, Timeout: torrentClientTimeout},
+	}, nil
+}
+
+func (c *qbitClient) Name() string { return "qbittorrent" }
+
+func (c *qbitClient) Capabilities() ClientCapabilities {
+	return ClientCapabilities{Categories: true, Tags: true, Recheck: true, SavePath: true, SkipChecking: true, ContentLayout: true}
+}
+
+// Version returns the qBittorrent version and its WebUI API version
+func (c *qbitClient) Version(ctx context.Context) (string, map[string]string, error) {
+	data, err := c.do(ctx, "/api/v2/app/version", "", nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("version request failed: %w", err)
+	}
+	details := map[string]string{}
+	if apiVersion, err := c.do(ctx, "/api/v2/app/webapiVersion", "", nil); err == nil {
+		details["webapiVersion"] = strings.TrimSpace(string(apiVersion))
+	}
+	return strings.TrimSpace(string(data)), details, nil
+}
+
+// ensureLogin logs in once; the session is reused until qBittorrent answers 403
+func (c *qbitClient) ensureLogin() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loggedIn {
+		return nil
+	}
+	if err := qbitLogin(c.http, c.baseURL, c.username, c.password); err != nil {
+		return err
+	}
+	c.loggedIn = true
+	return nil
+}
+
+// do sends a request (GET when body is nil) and logs in again once if the session expired
+func (c *qbitClient) do(ctx context.Context, path string, contentType string, body []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.ensureLogin(); err != nil {
+			return nil, err
+		}
+
+		method := "GET"
+		var reader io.Reader
+		if body != nil {
+			method = "POST"
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusForbidden && attempt == 0 {
+			c.mu.Lock()
+			c.loggedIn = false
+			c.mu.Unlock()
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, &qbitHTTPError{Path: path, Status: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+		}
+		return data, nil
+	}
+}
+
+// postForm sends url-encoded values to a WebUI endpoint
+func (c *qbitClient) postForm(ctx context.Context, path string, vals url.Values) ([]byte, error) {
+	return c.do(ctx, path, "application/x-www-form-urlencoded", []byte(vals.Encode()))
+}
+
+func (c *qbitClient) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	file, err := os.Open(torrentPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("torrents", "release.torrent")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	// qBittorrent 5 renamed "paused" to "stopped"; send both
+	paused := fmt.Sprint(opts.Paused)
+	writer.WriteField("paused", paused)
+	writer.WriteField("stopped", paused)
+	if opts.SavePath != "" {
+		writer.WriteField("savepath", opts.SavePath)
+		// Automatic torrent management would move the torrent to the category path
+		writer.WriteField("autoTMM", "false")
+	}
+	if opts.SkipChecking {
+		writer.WriteField("skip_checking", "true")
+	}
+	if opts.Category != "" {
+		writer.WriteField("category", opts.Category)
+	}
+	if len(opts.Tags) > 0 {
+		writer.WriteField("tags", strings.Join(opts.Tags, ","))
+	}
+	if opts.ContentLayout != "" {
+		writer.WriteField("contentLayout", opts.ContentLayout)
+	}
+	writer.Close()
+
+	data, err := c.do(ctx, "/api/v2/torrents/add", writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to add torrent: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "Fails." {
+		return fmt.Errorf("failed to add torrent: qBittorrent rejected the file")
+	}
+	return nil
+}
+
+func (c *qbitClient) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	vals := url.Values{}
+	vals.Set("hashes", infoHash)
+	vals.Set("deleteFiles", fmt.Sprint(deleteData))
+	if _, err := c.postForm(ctx, "/api/v2/torrents/delete", vals); err != nil {
+		return fmt.Errorf("failed to delete torrent: %w", err)
+	}
+	return nil
+}
+
+// qbitTorrentInfo is an entry of /api/v2/torrents/info
+type qbitTorrentInfo struct {
+	Hash      string  `json:"hash"`
+	Name      string  `json:"name"`
+	State     string  `json:"state"`
+	Progress  float64 `json:"progress"`
+	TotalSize int64   `json:"total_size"`
+	Uploaded  int64   `json:"uploaded"`
+	Ratio     float64 `json:"ratio"`
+	SavePath  string  `json:"save_path"`
+	Category  string  `json:"category"`
+}
+
+// qbitState maps a qBittorrent state to a TorrentStatus state
+func qbitState(state string) string {
+	switch state {
+	case "uploading", "stalledUP", "forcedUP":
+		return "seeding"
+	case "downloading", "stalledDL", "forcedDL", "metaDL", "forcedMetaDL", "allocating":
+		return "downloading"
+	case "pausedUP", "pausedDL", "stoppedUP", "stoppedDL":
+		return "paused"
+	case "checkingUP", "checkingDL", "checkingResumeData", "moving":
+		return "checking"
+	case "queuedUP", "queuedDL":
+		return "queued"
+	case "error", "missingFiles":
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (c *qbitClient) list(ctx context.Context, hashes string) ([]TorrentStatus, error) {
+	path := "/api/v2/torrents/info"
+	if hashes != "" {
+		path += "?hashes=" + url.QueryEscape(hashes)
+	}
+	data, err := c.do(ctx, path, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+	var infos []qbitTorrentInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, fmt.Errorf("failed to decode torrent list: %w", err)
+	}
+	result := make([]TorrentStatus, 0, len(infos))
+	for _, t := range infos {
+		status := TorrentStatus{
+			InfoHash: strings.ToLower(t.Hash),
+			Name:     t.Name,
+			State:    qbitState(t.State),
+			Progress: t.Progress,
+			Size:     t.TotalSize,
+			Uploaded: t.Uploaded,
+			Ratio:    t.Ratio,
+			SavePath: t.SavePath,
+			Category: t.Category,
+		}
+		if status.State == "error" {
+			status.Error = t.State
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+func (c *qbitClient) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	list, err := c.list(ctx, infoHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrTorrentNotFound
+	}
+	status := &list[0]
+	status.TrackerStatus = c.trackerStatus(ctx, infoHash)
+	return status, nil
+}
+
+// qbitTracker is an entry of /api/v2/torrents/trackers
+type qbitTracker struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+}
+
+// trackerStatus summarizes the announce state of the first real tracker of a torrent
+func (c *qbitClient) trackerStatus(ctx context.Context, infoHash string) string {
+	data, err := c.do(ctx, "/api/v2/torrents/trackers?hash="+url.QueryEscape(infoHash), "", nil)
+	if err != nil {
+		return ""
+	}
+	var trackers []qbitTracker
+	if json.Unmarshal(data, &trackers) != nil {
+		return ""
+	}
+	for _, t := range trackers {
+		// DHT, PeX and LSD are listed as "** [DHT] **"...
+		if strings.HasPrefix(t.URL, "** [") {
+			continue
+		}
+		switch t.Status {
+		case 2:
+			return "working"
+		case 3:
+			return "updating"
+		case 4:
+			return strings.TrimSpace("not working: " + t.Msg)
+		case 1:
+			return "not contacted"
+		default:
+			return "disabled"
+		}
+	}
+	return ""
+}
+
+func (c *qbitClient) List(ctx context.Context) ([]TorrentStatus, error) {
+	return c.list(ctx, "")
+}
+
+func (c *qbitClient) SetCategory(ctx context.Context, infoHash string, category string) error {
+	vals := url.Values{}
+	vals.Set("hashes", infoHash)
+	vals.Set("category", category)
+	_, err := c.postForm(ctx, "/api/v2/torrents/setCategory", vals)
+
+	// 409: the category doesn't exist yet
+	var httpErr *qbitHTTPError
+	if errors.As(err, &httpErr) && httpErr.Status == http.StatusConflict && category != "" {
+		create := url.Values{}
+		create.Set("category", category)
+		if _, err := c.postForm(ctx, "/api/v2/torrents/createCategory", create); err != nil {
+			return fmt.Errorf("failed to create category %q: %w", category, err)
+		}
+		_, err = c.postForm(ctx, "/api/v2/torrents/setCategory", vals)
+		if err != nil {
+			return fmt.Errorf("failed to set category: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set category: %w", err)
+	}
+	return nil
+}
+
+func (c *qbitClient) Recheck(ctx context.Context, infoHash string) error {
+	vals := url.Values{}
+	vals.Set("hashes", infoHash)
+	if _, err := c.postForm(ctx, "/api/v2/torrents/recheck", vals); err != nil {
+		return fmt.Errorf("failed to recheck torrent: %w", err)
+	}
+	return nil
+}
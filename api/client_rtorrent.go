@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerTorrentClient("rtorrent", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return newRtorrentClient(s.RtorrentUrl, s.RtorrentUsername, s.RtorrentPassword)
+		},
+		key: func(s AppSettings) string {
+			return strings.Join([]string{s.RtorrentUrl, s.RtorrentUsername, s.RtorrentPassword}, "\x00")
+		},
+	})
+}
+
+// rtorrentListFields are the d.multicall2 commands used to build a TorrentStatus, in column order
+var rtorrentListFields = []string{
+	"d.hash=", "d.name=", "d.state=", "d.is_active=", "d.complete=", "d.hashing=",
+	"d.completed_bytes=", "d.size_bytes=", "d.up.total=", "d.ratio=", "d.directory=", "d.custom1=", "d.message=",
+}
+
+// rtorrentClient talks to rTorrent's XML-RPC interface, over HTTP (ruTorrent RPC plugin, nginx /RPC2) or SCGI
+type rtorrentClient struct {
+	username string
+	password string
+	http     *http.Client
+	// httpURL is set for HTTP(S) endpoints, scgiNetwork/scgiAddress for SCGI (tcp or unix socket)
+	httpURL     string
+	scgiNetwork string
+	scgiAddress string
+}
+
+// newRtorrentClient accepts http(s)://host/RPC2, scgi://host:port, scgi:///path/to/socket or unix:///path/to/socket
+func newRtorrentClient(rawURL, username, password string) (*rtorrentClient, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("rTorrent URL is not configured")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rTorrent URL %q: %v", rawURL, err)
+	}
+	c := &rtorrentClient{username: username, password: password}
+	switch u.Scheme {
+	case "http", "https":
+		c.httpURL = rawURL
+		c.http = &http.Client{Timeout: torrentClientTimeout}
+	case "scgi":
+		if u.Host != "" {
+			c.scgiNetwork, c.scgiAddress = "tcp", u.Host
+		} else {
+			c.scgiNetwork, c.scgiAddress = "unix", u.Path
+		}
+	case "unix":
+		c.scgiNetwork, c.scgiAddress = "unix", u.Path
+	default:
+		return nil, fmt.Errorf("invalid rTorrent URL %q: scheme must be http, https, scgi or unix", rawURL)
+	}
+	return c, nil
+}
+
+func (c *rtorrentClient) Name() string { return "rtorrent" }
+
+func (c *rtorrentClient) Capabilities() ClientCapabilities {
+	// Labels are ruTorrent's d.custom1; skipping the hash check would need fast resume data
+	return ClientCapabilities{Labels: true, Recheck: true, SavePath: true}
+}
+
+// Version returns the rTorrent version and the libtorrent version
+func (c *rtorrentClient) Version(ctx context.Context) (string, map[string]string, error) {
+	version, err := c.call(ctx, "system.client_version")
+	if err != nil {
+		return "", nil, err
+	}
+	details := map[string]string{}
+	if lib, err := c.call(ctx, "system.library_version"); err == nil {
+		details["libtorrentVersion"] = fmt.Sprint(lib)
+	}
+	return fmt.Sprint(version), details, nil
+}
+
+// call sends an XML-RPC request and decodes the answer
+func (c *rtorrentClient) call(ctx context.Context, method string, params ...interface{}) (interface{}, error) {
+	body, err := encodeXMLRPCCall(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if c.httpURL != "" {
+		data, err = c.httpRoundTrip(ctx, body)
+	} else {
+		data, err = c.scgiRoundTrip(ctx, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	result, err := decodeXMLRPCResponse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	return result, nil
+}
+
+func (c *rtorrentClient) httpRoundTrip(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.httpURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("authentication failed: invalid username or password")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// scgiRoundTrip sends the request as an SCGI netstring and strips the CGI headers of the answer
+func (c *rtorrentClient) scgiRoundTrip(ctx context.Context, body []byte) ([]byte, error) {
+	dialer := net.Dialer{Timeout: torrentClientTimeout}
+	conn, err := dialer.DialContext(ctx, c.scgiNetwork, c.scgiAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(torrentClientTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	headers := "CONTENT_LENGTH\x00" + strconv.Itoa(len(body)) + "\x00" +
+		"SCGI\x001\x00" +
+		"REQUEST_METHOD\x00POST\x00" +
+		"REQUEST_URI\x00/RPC2\x00"
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "%d:%s,", len(headers), headers)
+	req.Write(body)
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.Index(data, []byte("\r\n\r\n")); i >= 0 {
+		return data[i+4:], nil
+	}
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		return data[i+2:], nil
+	}
+	return data, nil
+}
+
+// rtorrentQuote quotes a value for use inside an rTorrent command argument
+func rtorrentQuote(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+func (c *rtorrentClient) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	torrentData, err := os.ReadFile(torrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to read torrent file: %w", err)
+	}
+
+	// load.raw_start(target, data, commands run on the new torrent...)
+	params := []interface{}{"", torrentData}
+	if opts.SavePath != "" {
+		// For multi-file torrents rTorrent appends the torrent name to d.directory
+		params = append(params, "d.directory.set="+rtorrentQuote(opts.SavePath))
+	}
+	if opts.Category != "" {
+		// ruTorrent stores labels URL-encoded
+		params = append(params, "d.custom1.set="+rtorrentQuote(url.PathEscape(opts.Category)))
+	}
+	method := "load.raw_start"
+	if opts.Paused {
+		method = "load.raw"
+	}
+	if _, err := c.call(ctx, method, params...); err != nil {
+		return fmt.Errorf("failed to add torrent to rTorrent: %w", err)
+	}
+	return nil
+}
+
+func (c *rtorrentClient) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	if deleteData {
+		return fmt.Errorf("rTorrent cannot delete torrent data remotely")
+	}
+	if _, err := c.call(ctx, "d.erase", strings.ToUpper(infoHash)); err != nil {
+		return fmt.Errorf("failed to remove torrent from rTorrent: %w", err)
+	}
+	return nil
+}
+
+// rtorrentStatus converts a d.multicall2 row (see rtorrentListFields) to a TorrentStatus
+func rtorrentStatus(row []interface{}) (TorrentStatus, error) {
+	if len(row) != len(rtorrentListFields) {
+		return TorrentStatus{}, fmt.Errorf("unexpected d.multicall2 row of %d columns", len(row))
+	}
+	str := func(i int) string { s, _ := row[i].(string); return s }
+	num := func(i int) int64 { n, _ := row[i].(int64); return n }
+
+	status := TorrentStatus{
+		InfoHash: strings.ToLower(str(0)),
+		Name:     str(1),
+		Size:     num(7),
+		Uploaded: num(8),
+		Ratio:    float64(num(9)) / 1000, // d.ratio is in thousandths
+		SavePath: str(10),
+		Category: str(11),
+	}
+	if label, err := url.PathUnescape(status.Category); err == nil {
+		status.Category = label
+	}
+	if size := num(7); size > 0 {
+		status.Progress = float64(num(6)) / float64(size)
+	}
+
+	switch {
+	case num(5) != 0:
+		status.State = "checking"
+	case num(2) == 0 || num(3) == 0:
+		status.State = "paused"
+	case num(4) == 1:
+		status.State = "seeding"
+	default:
+		status.State = "downloading"
+	}
+	// d.message holds the last tracker or storage error
+	if msg := str(12); msg != "" {
+		status.TrackerStatus = msg
+		if strings.Contains(strings.ToLower(msg), "storage") || strings.Contains(strings.ToLower(msg), "no such file") {
+			status.State = "error"
+			status.Error = msg
+		}
+	} else if status.State == "seeding" || status.State == "downloading" {
+		status.TrackerStatus = "working"
+	}
+	return status, nil
+}
+
+func (c *rtorrentClient) List(ctx context.Context) ([]TorrentStatus, error) {
+	params := []interface{}{"", "main"}
+	for _, f := range rtorrentListFields {
+		params = append(params, f)
+	}
+	result, err := c.call(ctx, "d.multicall2", params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+	rows, _ := result.([]interface{})
+	list := make([]TorrentStatus, 0, len(rows))
+	for _, r := range rows {
+		row, _ := r.([]interface{})
+		status, err := rtorrentStatus(row)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, status)
+	}
+	return list, nil
+}
+
+func (c *rtorrentClient) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	list, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].InfoHash == strings.ToLower(infoHash) {
+			return &list[i], nil
+		}
+	}
+	return nil, ErrTorrentNotFound
+}
+
+func (c *rtorrentClient) SetCategory(ctx context.Context, infoHash string, category string) error {
+	if _, err := c.call(ctx, "d.custom1.set", strings.ToUpper(infoHash), url.PathEscape(category)); err != nil {
+		return fmt.Errorf("failed to set label: %w", err)
+	}
+	return nil
+}
+
+func (c *rtorrentClient) Recheck(ctx context.Context, infoHash string) error {
+	if _, err := c.call(ctx, "d.check_hash", strings.ToUpper(infoHash)); err != nil {
+		return fmt.Errorf("failed to recheck torrent: %w", err)
+	}
+	return nil
+}
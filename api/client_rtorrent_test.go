@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rtorrentCall is an XML-RPC call received by fakeRtorrent
+type rtorrentCall struct {
+	Method string
+	Params []interface{}
+}
+
+// fakeRtorrent answers the XML-RPC methods used by rtorrentClient
+type fakeRtorrent struct {
+	mu    sync.Mutex
+	calls []rtorrentCall
+	// rows are the d.multicall2 answer, in rtorrentListFields order
+	rows []interface{}
+	// scgiHeaders are the headers of the last SCGI request, in order
+	scgiHeaders []string
+}
+
+func (f *fakeRtorrent) lastCall(method string) (rtorrentCall, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.calls) - 1; i >= 0; i-- {
+		if f.calls[i].Method == method {
+			return f.calls[i], true
+		}
+	}
+	return rtorrentCall{}, false
+}
+
+// handle decodes a <methodCall> and returns the <methodResponse>
+func (f *fakeRtorrent) handle(body []byte) []byte {
+	var call struct {
+		MethodName string        `xml:"methodName"`
+		Params     []xmlrpcValue `xml:"params>param>value"`
+	}
+	if err := xml.Unmarshal(body, &call); err != nil {
+		return xmlrpcFaultResponse(-32700, "parse error: "+err.Error())
+	}
+	params := make([]interface{}, 0, len(call.Params))
+	for _, p := range call.Params {
+		v, err := p.native()
+		if err != nil {
+			return xmlrpcFaultResponse(-32700, err.Error())
+		}
+		params = append(params, v)
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, rtorrentCall{Method: call.MethodName, Params: params})
+	rows := f.rows
+	f.mu.Unlock()
+
+	switch call.MethodName {
+	case "system.client_version":
+		return xmlrpcResultResponse("0.9.8")
+	case "system.library_version":
+		return xmlrpcResultResponse("0.13.8")
+	case "load.raw_start", "load.raw", "d.custom1.set", "d.check_hash":
+		return xmlrpcResultResponse(0)
+	case "d.erase":
+		for _, r := range rows {
+			if row := r.([]interface{}); row[0] == params[0] {
+				return xmlrpcResultResponse(0)
+			}
+		}
+		return xmlrpcFaultResponse(-501, "Could not find info-hash.")
+	case "d.multicall2":
+		return xmlrpcResultResponse(rows)
+	default:
+		return xmlrpcFaultResponse(-506, "Method '"+call.MethodName+"' not defined")
+	}
+}
+
+func xmlrpcResultResponse(v interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param>`)
+	if err := encodeXMLRPCValue(&buf, v); err != nil {
+		panic(err)
+	}
+	buf.WriteString(`</param></params></methodResponse>`)
+	return buf.Bytes()
+}
+
+func xmlrpcFaultResponse(code int, message string) []byte {
+	var msg bytes.Buffer
+	xml.EscapeText(&msg, []byte(message))
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><methodResponse><fault><value><struct>`+
+		`<member><name>faultCode</name><value><i4>%d</i4></value></member>`+
+		`<member><name>faultString</name><value><string>%s</string></value></member>`+
+		`</struct></value></fault></methodResponse>`, code, msg.String()))
+}
+
+// serveHTTP serves the XML-RPC over HTTP on /RPC2 with basic auth
+func (f *fakeRtorrent) serveHTTP(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/RPC2" || r.Header.Get("Content-Type") != "text/xml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(f.handle(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/RPC2"
+}
+
+// serveSCGI accepts SCGI requests on a listener, one call per connection like rTorrent
+func (f *fakeRtorrent) serveSCGI(t *testing.T, l net.Listener) {
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serveSCGIConn(t, conn)
+		}
+	}()
+}
+
+func (f *fakeRtorrent) serveSCGIConn(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	headers, body, err := readSCGIRequest(bufio.NewReader(conn))
+	if err != nil {
+		t.Errorf("invalid SCGI request: %v", err)
+		return
+	}
+	f.mu.Lock()
+	f.scgiHeaders = headers
+	f.mu.Unlock()
+	resp := f.handle(body)
+	fmt.Fprintf(conn, "Status: 200 OK\r\nContent-Type: text/xml\r\nContent-Length: %d\r\n\r\n", len(resp))
+	conn.Write(resp)
+}
+
+// readSCGIRequest parses "<len>:<headers>," followed by CONTENT_LENGTH bytes of body
+func readSCGIRequest(r *bufio.Reader) ([]string, []byte, error) {
+	lenStr, err := r.ReadString(':')
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(lenStr, ":"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid netstring length %q", lenStr)
+	}
+	raw := make([]byte, n+1)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, nil, err
+	}
+	if raw[n] != ',' {
+		return nil, nil, fmt.Errorf("netstring not terminated by a comma")
+	}
+	if n == 0 || raw[n-1] != 0 {
+		return nil, nil, fmt.Errorf("headers not terminated by NUL")
+	}
+	headers := strings.Split(string(raw[:n-1]), "\x00")
+	if len(headers)%2 != 0 {
+		return nil, nil, fmt.Errorf("odd number of header fields")
+	}
+	if headers[0] != "CONTENT_LENGTH" {
+		return nil, nil, fmt.Errorf("first header is %q, want CONTENT_LENGTH", headers[0])
+	}
+	length, err := strconv.Atoi(headers[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+	return headers, body, nil
+}
+
+func TestRtorrentClientTransports(t *testing.T) {
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	torrentData, err := os.ReadFile(torrentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper := strings.ToUpper(hash)
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, f *fakeRtorrent) string
+	}{
+		{"http", func(t *testing.T, f *fakeRtorrent) string {
+			return f.serveHTTP(t)
+		}},
+		{"scgi tcp", func(t *testing.T, f *fakeRtorrent) string {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.serveSCGI(t, l)
+			return "scgi://" + l.Addr().String()
+		}},
+		{"scgi unix socket", func(t *testing.T, f *fakeRtorrent) string {
+			sock := filepath.Join(t.TempDir(), "rpc.sock")
+			l, err := net.Listen("unix", sock)
+			if err != nil {
+				t.Skipf("unix sockets unavailable: %v", err)
+			}
+			f.serveSCGI(t, l)
+			return "unix://" + sock
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := &fakeRtorrent{rows: []interface{}{
+				[]interface{}{upper, "Release.mkv", int64(1), int64(1), int64(1), int64(0),
+					int64(17), int64(17), int64(34), int64(2000), "/data", "s%C3%A9ries", ""},
+			}}
+			c, err := newRtorrentClient(tt.setup(t, f), "admin", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version, details, err := c.Version(ctx)
+			if err != nil || version != "0.9.8" || details["libtorrentVersion"] != "0.13.8" {
+				t.Fatalf("Version = %q, %v, %v", version, details, err)
+			}
+
+			if err := c.Add(ctx, torrentPath, AddOptions{SavePath: `/data/my "dir"`, Category: "séries"}); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			call, ok := f.lastCall("load.raw_start")
+			if !ok {
+				t.Fatal("Add did not call load.raw_start")
+			}
+			if len(call.Params) != 4 || call.Params[0] != "" || !bytes.Equal(call.Params[1].([]byte), torrentData) {
+				t.Fatalf("load.raw_start params = %v", call.Params)
+			}
+			if call.Params[2] != `d.directory.set="/data/my \"dir\""` || call.Params[3] != `d.custom1.set="s%C3%A9ries"` {
+				t.Errorf("load.raw_start commands = %q, %q", call.Params[2], call.Params[3])
+			}
+
+			if err := c.Add(ctx, torrentPath, AddOptions{Paused: true}); err != nil {
+				t.Fatalf("Add paused: %v", err)
+			}
+			if call, ok := f.lastCall("load.raw"); !ok || len(call.Params) != 2 {
+				t.Errorf("a paused add must use load.raw without commands, got %v", call)
+			}
+
+			status, err := c.Status(ctx, hash)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			want := TorrentStatus{InfoHash: hash, Name: "Release.mkv", State: "seeding", Progress: 1, Size: 17,
+				Uploaded: 34, Ratio: 2, SavePath: "/data", Category: "séries", TrackerStatus: "working"}
+			if *status != want {
+				t.Errorf("Status = %+v, want %+v", *status, want)
+			}
+			call, _ = f.lastCall("d.multicall2")
+			if len(call.Params) != 2+len(rtorrentListFields) || call.Params[0] != "" || call.Params[1] != "main" {
+				t.Errorf("d.multicall2 params = %v", call.Params)
+			}
+
+			if err := c.SetCategory(ctx, hash, "films"); err != nil {
+				t.Fatalf("SetCategory: %v", err)
+			}
+			if call, _ := f.lastCall("d.custom1.set"); fmt.Sprint(call.Params) != fmt.Sprint([]interface{}{upper, "films"}) {
+				t.Errorf("d.custom1.set params = %v", call.Params)
+			}
+			if err := c.Recheck(ctx, hash); err != nil {
+				t.Fatalf("Recheck: %v", err)
+			}
+			if err := c.Remove(ctx, hash, false); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			if call, _ := f.lastCall("d.erase"); len(call.Params) != 1 || call.Params[0] != upper {
+				t.Errorf("d.erase params = %v", call.Params)
+			}
+		})
+	}
+}
+
+func TestRtorrentSCGIFraming(t *testing.T) {
+	f := &fakeRtorrent{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.serveSCGI(t, l)
+	c, err := newRtorrentClient("scgi://"+l.Addr().String(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.call(context.Background(), "system.client_version"); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := encodeXMLRPCCall("system.client_version")
+	want := []string{"CONTENT_LENGTH", strconv.Itoa(len(body)), "SCGI", "1", "REQUEST_METHOD", "POST", "REQUEST_URI", "/RPC2"}
+	f.mu.Lock()
+	got := f.scgiHeaders
+	f.mu.Unlock()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("SCGI headers = %q, want %q", got, want)
+	}
+}
+
+func TestRtorrentSCGIResponseHeaders(t *testing.T) {
+	// rTorrent answers with CGI headers separated by CRLF; some proxies use bare LF
+	for _, sep := range []string{"\r\n", "\n"} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			readSCGIRequest(bufio.NewReader(conn))
+			io.WriteString(conn, "Status: 200 OK"+sep+"Content-Type: text/xml"+sep+sep)
+			conn.Write(xmlrpcResultResponse("0.9.8"))
+		}()
+		c, _ := newRtorrentClient("scgi://"+l.Addr().String(), "", "")
+		version, err := c.call(context.Background(), "system.client_version")
+		l.Close()
+		if err != nil || version != "0.9.8" {
+			t.Errorf("separator %q: version = %v, err = %v", sep, version, err)
+		}
+	}
+}
+
+func TestRtorrentFault(t *testing.T) {
+	f := &fakeRtorrent{}
+	c, err := newRtorrentClient(f.serveHTTP(t), "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Remove(context.Background(), strings.Repeat("a", 40), false)
+	var fault *xmlrpcFault
+	if !errors.As(err, &fault) {
+		t.Fatalf("err = %v, want an XML-RPC fault", err)
+	}
+	if fault.Code != -501 || fault.Message != "Could not find info-hash." {
+		t.Errorf("fault = %+v", fault)
+	}
+	if !strings.Contains(err.Error(), "d.erase") {
+		t.Errorf("err = %v, want the method name", err)
+	}
+
+	if _, err := c.Status(context.Background(), strings.Repeat("a", 40)); !errors.Is(err, ErrTorrentNotFound) {
+		t.Errorf("Status of an unknown torrent: err = %v", err)
+	}
+	if err := c.Remove(context.Background(), strings.Repeat("a", 40), true); err == nil {
+		t.Error("Remove with deleteData must fail")
+	}
+}
+
+func TestRtorrentBadCredentials(t *testing.T) {
+	f := &fakeRtorrent{}
+	c, err := newRtorrentClient(f.serveHTTP(t), "admin", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.List(context.Background()); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("err = %v, want authentication failed", err)
+	}
+}
+
+func TestRtorrentStatusStates(t *testing.T) {
+	row := func(state, active, complete, hashing int64, message string) []interface{} {
+		return []interface{}{"ABC", "n", state, active, complete, hashing, int64(5), int64(10), int64(0), int64(0), "/d", "", message}
+	}
+	tests := []struct {
+		name    string
+		row     []interface{}
+		state   string
+		tracker string
+	}{
+		{"seeding", row(1, 1, 1, 0, ""), "seeding", "working"},
+		{"downloading", row(1, 1, 0, 0, ""), "downloading", "working"},
+		{"stopped", row(0, 0, 1, 0, ""), "paused", ""},
+		{"hashing", row(1, 1, 0, 1, ""), "checking", ""},
+		{"tracker error", row(1, 1, 1, 0, "Tracker: [Failure reason \"unregistered torrent\"]"), "seeding", "Tracker: [Failure reason \"unregistered torrent\"]"},
+		{"storage error", row(1, 1, 0, 0, "Storage error: [No such file or directory]"), "error", "Storage error: [No such file or directory]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := rtorrentStatus(tt.row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.State != tt.state || status.TrackerStatus != tt.tracker || status.Progress != 0.5 || status.InfoHash != "abc" {
+				t.Errorf("status = %+v", status)
+			}
+		})
+	}
+	if _, err := rtorrentStatus([]interface{}{"ABC"}); err == nil {
+		t.Error("a short row must be rejected")
+	}
+}
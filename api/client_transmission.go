@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerTorrentClient("transmission", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return newTransmissionClient(s.TransmissionUrl, s.TransmissionUsername, s.TransmissionPassword)
+		},
+		key: func(s AppSettings) string {
+			return strings.Join([]string{s.TransmissionUrl, s.TransmissionUsername, s.TransmissionPassword}, "\x00")
+		},
+	})
+}
+
+// TransmissionRPCRequest represents a Transmission RPC request
+type TransmissionRPCRequest struct {
+	Method    string      `json:"method"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+// TransmissionRPCResponse represents a Transmission RPC response
+type TransmissionRPCResponse struct {
+	Result    string                 `json:"result"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// transmissionTorrentFields are the torrent-get fields needed to build a TorrentStatus
+var transmissionTorrentFields = []string{
+	"hashString", "name", "status", "percentDone", "totalSize", "uploadedEver",
+	"uploadRatio", "downloadDir", "labels", "error", "errorString", "trackerStats",
+}
+
+// transmissionClient talks to the Transmission RPC, keeping the CSRF session id between calls
+type transmissionClient struct {
+	rpcURL   string
+	username string
+	password string
+	http     *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newTransmissionClient(baseURL, username, password string) (*transmissionClient, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("Transmission URL is not configured")
+	}
+	return &transmissionClient{
+		rpcURL:   strings.TrimSuffix(baseURL, "/") + "/transmission/rpc",
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: torrentClientTimeout},
+	}, nil
+}
+
+func (c *transmissionClient) Name() string { return "transmission" }
+
+func (c *transmissionClient) Capabilities() ClientCapabilities {
+	// Labels need Transmission 3.0 (RPC 16); data is always verified on add
+	return ClientCapabilities{Labels: true, Tags: true, Recheck: true, SavePath: true}
+}
+
+// Version returns the Transmission version and its RPC versions
+func (c *transmissionClient) Version(ctx context.Context) (string, map[string]string, error) {
+	resp, err := c.rpc(ctx, "session-get", map[string]interface{}{
+		"fields": []string{"version", "rpc-version", "rpc-version-minimum"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprint(resp.Arguments["version"]), map[string]string{
+		"rpcVersion":        fmt.Sprint(resp.Arguments["rpc-version"]),
+		"rpcVersionMinimum": fmt.Sprint(resp.Arguments["rpc-version-minimum"]),
+	}, nil
+}
+
+// rpc calls a method, fetching a new session id once when Transmission answers 409
+func (c *transmissionClient) rpc(ctx context.Context, method string, args interface{}) (*TransmissionRPCResponse, error) {
+	body, err := json.Marshal(TransmissionRPCRequest{Method: method, Arguments: args})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.rpcURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		c.mu.Lock()
+		if c.sessionID != "" {
+			req.Header.Set("X-Transmission-Session-Id", c.sessionID)
+		}
+		c.mu.Unlock()
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		if resp.StatusCode == http.StatusConflict && attempt == 0 {
+			c.mu.Lock()
+			c.sessionID = resp.Header.Get("X-Transmission-Session-Id")
+			c.mu.Unlock()
+			resp.Body.Close()
+			continue
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: authentication failed: invalid username or password", method)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: request failed with status %d", method, resp.StatusCode)
+		}
+
+		var rpcResp TransmissionRPCResponse
+		err = json.NewDecoder(resp.Body).Decode(&rpcResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to decode response: %w", method, err)
+		}
+		if rpcResp.Result != "success" {
+			return nil, fmt.Errorf("Transmission returned: %s", rpcResp.Result)
+		}
+		return &rpcResp, nil
+	}
+}
+
+func (c *transmissionClient) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	torrentData, err := os.ReadFile(torrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to read torrent file: %w", err)
+	}
+	args := map[string]interface{}{
+		"metainfo": base64.StdEncoding.EncodeToString(torrentData),
+		"paused":   opts.Paused,
+	}
+	if opts.SavePath != "" {
+		args["download-dir"] = opts.SavePath
+	}
+	if _, err := c.rpc(ctx, "torrent-add", args); err != nil {
+		return fmt.Errorf("failed to add torrent to Transmission: %w", err)
+	}
+
+	// Category and tags are all stored as labels
+	labels := opts.Tags
+	if opts.Category != "" {
+		labels = append([]string{opts.Category}, opts.Tags...)
+	}
+	if len(labels) > 0 {
+		infoHash, err := torrentInfoHash(torrentPath)
+		if err != nil {
+			return err
+		}
+		if err := c.setLabels(ctx, infoHash, labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *transmissionClient) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	args := map[string]interface{}{
+		"ids":               []string{infoHash},
+		"delete-local-data": deleteData,
+	}
+	if _, err := c.rpc(ctx, "torrent-remove", args); err != nil {
+		return fmt.Errorf("failed to remove torrent from Transmission: %w", err)
+	}
+	return nil
+}
+
+// transmissionTorrent is an entry of the torrent-get response
+type transmissionTorrent struct {
+	HashString   string   `json:"hashString"`
+	Name         string   `json:"name"`
+	Status       int      `json:"status"`
+	PercentDone  float64  `json:"percentDone"`
+	TotalSize    int64    `json:"totalSize"`
+	UploadedEver int64    `json:"uploadedEver"`
+	UploadRatio  float64  `json:"uploadRatio"`
+	DownloadDir  string   `json:"downloadDir"`
+	Labels       []string `json:"labels"`
+	Error        int      `json:"error"`
+	ErrorString  string   `json:"errorString"`
+	TrackerStats []struct {
+		HasAnnounced          bool   `json:"hasAnnounced"`
+		LastAnnounceSucceeded bool   `json:"lastAnnounceSucceeded"`
+		LastAnnounceResult    string `json:"lastAnnounceResult"`
+	} `json:"trackerStats"`
+}
+
+// trackerStatus summarizes the announce state of the first tracker
+func (t transmissionTorrent) trackerStatus() string {
+	if len(t.TrackerStats) == 0 {
+		return ""
+	}
+	stats := t.TrackerStats[0]
+	switch {
+	case !stats.HasAnnounced:
+		return "not contacted"
+	case stats.LastAnnounceSucceeded:
+		return "working"
+	default:
+		return strings.TrimSpace("not working: " + stats.LastAnnounceResult)
+	}
+}
+
+// transmissionState maps the tr_torrent_activity values to a TorrentStatus state
+func transmissionState(t transmissionTorrent) string {
+	if t.Error != 0 {
+		return "error"
+	}
+	switch t.Status {
+	case 0:
+		return "paused"
+	case 1, 2:
+		return "checking"
+	case 3, 5:
+		return "queued"
+	case 4:
+		return "downloading"
+	case 6:
+		return "seeding"
+	default:
+		return "unknown"
+	}
+}
+
+func (c *transmissionClient) get(ctx context.Context, ids []string) ([]TorrentStatus, error) {
+	args := map[string]interface{}{"fields": transmissionTorrentFields}
+	if ids != nil {
+		args["ids"] = ids
+	}
+	resp, err := c.rpc(ctx, "torrent-get", args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	// Arguments are decoded as a generic map; re-encode the torrents to get typed values
+	raw, _ := json.Marshal(resp.Arguments["torrents"])
+	var torrents []transmissionTorrent
+	if err := json.Unmarshal(raw, &torrents); err != nil {
+		return nil, fmt.Errorf("failed to decode torrent list: %w", err)
+	}
+
+	result := make([]TorrentStatus, 0, len(torrents))
+	for _, t := range torrents {
+		status := TorrentStatus{
+			InfoHash:      strings.ToLower(t.HashString),
+			Name:          t.Name,
+			State:         transmissionState(t),
+			Progress:      t.PercentDone,
+			Size:          t.TotalSize,
+			Uploaded:      t.UploadedEver,
+			Ratio:         t.UploadRatio,
+			SavePath:      t.DownloadDir,
+			Error:         t.ErrorString,
+			TrackerStatus: t.trackerStatus(),
+		}
+		if status.Ratio < 0 {
+			// -1 means "not available", -2 "infinite"
+			status.Ratio = 0
+		}
+		if len(t.Labels) > 0 {
+			status.Category = t.Labels[0]
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+func (c *transmissionClient) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	list, err := c.get(ctx, []string{infoHash})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrTorrentNotFound
+	}
+	return &list[0], nil
+}
+
+func (c *transmissionClient) List(ctx context.Context) ([]TorrentStatus, error) {
+	return c.get(ctx, nil)
+}
+
+func (c *transmissionClient) SetCategory(ctx context.Context, infoHash string, category string) error {
+	labels := []string{}
+	if category != "" {
+		labels = append(labels, category)
+	}
+	return c.setLabels(ctx, infoHash, labels)
+}
+
+func (c *transmissionClient) setLabels(ctx context.Context, infoHash string, labels []string) error {
+	args := map[string]interface{}{
+		"ids":    []string{infoHash},
+		"labels": labels,
+	}
+	if _, err := c.rpc(ctx, "torrent-set", args); err != nil {
+		return fmt.Errorf("failed to set label: %w", err)
+	}
+	return nil
+}
+
+func (c *transmissionClient) Recheck(ctx context.Context, infoHash string) error {
+	args := map[string]interface{}{"ids": []string{infoHash}}
+	if _, err := c.rpc(ctx, "torrent-verify", args); err != nil {
+		return fmt.Errorf("failed to recheck torrent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerTorrentClient("watchdir", torrentClientFactory{
+		new: func(s AppSettings) (TorrentClient, error) {
+			return newWatchDirClient(s.WatchDir)
+		},
+		key: func(s AppSettings) string {
+			return s.WatchDir
+		},
+	})
+}
+
+// watchDirClient hands torrents to a client through its watch folder
+// The category selects a subdirectory, so each category can be watched with its own download path.
+// The client picks the file up on its own: nothing is known about the torrent afterwards.
+type watchDirClient struct {
+	dir string
+}
+
+func newWatchDirClient(dir string) (*watchDirClient, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("watch directory is not configured")
+	}
+	return &watchDirClient{dir: dir}, nil
+}
+
+func (c *watchDirClient) Name() string { return "watchdir" }
+
+func (c *watchDirClient) Capabilities() ClientCapabilities {
+	return ClientCapabilities{Categories: true}
+}
+
+// categoryDir returns the watch folder of a category
+func (c *watchDirClient) categoryDir(category string) (string, error) {
+	if category == "" {
+		return c.dir, nil
+	}
+	if category == "." || category == ".." || strings.ContainsAny(category, `/\`) {
+		return "", fmt.Errorf("invalid category %q for a watch directory", category)
+	}
+	return filepath.Join(c.dir, category), nil
+}
+
+func (c *watchDirClient) Add(ctx context.Context, torrentPath string, opts AddOptions) error {
+	dir, err := c.categoryDir(opts.Category)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create watch directory: %w", err)
+	}
+
+	src, err := os.Open(torrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to read torrent file: %w", err)
+	}
+	defer src.Close()
+
+	// Write under a name the client ignores, then rename so it never loads a partial file
+	target := filepath.Join(dir, filepath.Base(torrentPath))
+	tmp, err := os.CreateTemp(dir, ".aatm-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write to watch directory: %w", err)
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to copy torrent file: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to copy torrent file: %w", err)
+	}
+	return nil
+}
+
+// pending returns the .torrent files with this info hash the client hasn't picked up yet
+func (c *watchDirClient) pending(infoHash string) []string {
+	dirs := []string{c.dir}
+	if entries, err := os.ReadDir(c.dir); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				dirs = append(dirs, filepath.Join(c.dir, e.Name()))
+			}
+		}
+	}
+	var files []string
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.torrent"))
+		for _, m := range matches {
+			if hash, err := torrentInfoHash(m); err == nil && hash == strings.ToLower(infoHash) {
+				files = append(files, m)
+			}
+		}
+	}
+	return files
+}
+
+// Remove only withdraws a torrent the client hasn't loaded yet
+func (c *watchDirClient) Remove(ctx context.Context, infoHash string, deleteData bool) error {
+	files := c.pending(infoHash)
+	if len(files) == 0 {
+		return fmt.Errorf("torrent was already picked up from the watch directory: %w", ErrNotSupported)
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return fmt.Errorf("failed to remove torrent file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Status reports a torrent still waiting in the watch directory as queued
+func (c *watchDirClient) Status(ctx context.Context, infoHash string) (*TorrentStatus, error) {
+	files := c.pending(infoHash)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("watch directory status: %w", ErrNotSupported)
+	}
+	status := &TorrentStatus{InfoHash: strings.ToLower(infoHash), Name: filepath.Base(files[0]), State: "queued"}
+	if rel, err := filepath.Rel(c.dir, filepath.Dir(files[0])); err == nil && rel != "." {
+		status.Category = rel
+	}
+	return status, nil
+}
+
+func (c *watchDirClient) List(ctx context.Context) ([]TorrentStatus, error) {
+	return nil, fmt.Errorf("watch directory listing: %w", ErrNotSupported)
+}
+
+func (c *watchDirClient) SetCategory(ctx context.Context, infoHash string, category string) error {
+	return fmt.Errorf("watch directory category change: %w", ErrNotSupported)
+}
+
+func (c *watchDirClient) Recheck(ctx context.Context, infoHash string) error {
+	return fmt.Errorf("watch directory recheck: %w", ErrNotSupported)
+}
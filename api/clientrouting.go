@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ClientInstance is a named torrent client connection (seedbox, NAS...)
+type ClientInstance struct {
+	Name string `json:"name"`
+	// Type is a registered client: "qbittorrent", "transmission", "deluge", "rtorrent", "aria2", "watchdir" or "builtin"
+	Type     string `json:"type"`
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"`
+	// Password is also the Deluge password and the aria2 RPC secret
+	Password string `json:"password,omitempty"`
+	// Dir is the folder of the "watchdir" type
+	Dir string `json:"dir,omitempty"`
+	// PathMappings translate the local content directory to the one seen by this client (seedbox, container...)
+	// Without a matching mapping the client saves the torrent to its default directory.
+	PathMappings []ClientPathMapping `json:"pathMappings,omitempty"`
+}
+
+// ClientPathMapping replaces the Local prefix of a path by Remote
+type ClientPathMapping struct {
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// ClientRoute sends the torrents matching all its non-empty criteria to one or more instances
+type ClientRoute struct {
+	// Tracker is the tracker profile the release is uploaded to
+	Tracker string `json:"tracker,omitempty"`
+	// MediaType is "movie", "episode", "season", "ebook" or "game"
+	MediaType string `json:"mediaType,omitempty"`
+	// PathPrefix matches the source path of the release
+	PathPrefix string   `json:"pathPrefix,omitempty"`
+	Clients    []string `json:"clients"`
+}
+
+// ClientRouteInput describes a release for route matching
+type ClientRouteInput struct {
+	Tracker    string `json:"tracker,omitempty"`
+	MediaType  string `json:"mediaType,omitempty"`
+	SourcePath string `json:"sourcePath,omitempty"`
+}
+
+// matches reports whether every criterion set on the route matches the release
+func (r ClientRoute) matches(in ClientRouteInput) bool {
+	if r.Tracker != "" && !strings.EqualFold(r.Tracker, in.Tracker) {
+		return false
+	}
+	if r.MediaType != "" && !strings.EqualFold(r.MediaType, in.MediaType) {
+		return false
+	}
+	if r.PathPrefix != "" {
+		prefix := filepath.Clean(r.PathPrefix)
+		source := filepath.Clean(in.SourcePath)
+		if in.SourcePath == "" || (source != prefix && !strings.HasPrefix(source, prefix+string(filepath.Separator))) {
+			return false
+		}
+	}
+	return true
+}
+
+// settings returns a copy of base where the single-client fields hold this instance
+// The client factories only know AppSettings, so instances reuse them this way.
+func (ci ClientInstance) settings(base AppSettings) AppSettings {
+	s := base
+	s.TorrentClient = ci.Type
+	if ci.Name == ci.Type {
+		// The single-client settings (instances can't be named after a type)
+		return s
+	}
+	switch ci.Type {
+	case "qbittorrent":
+		s.QbitUrl, s.QbitUsername, s.QbitPassword = ci.URL, ci.Username, ci.Password
+	case "transmission":
+		s.TransmissionUrl, s.TransmissionUsername, s.TransmissionPassword = ci.URL, ci.Username, ci.Password
+	case "deluge":
+		s.DelugeUrl, s.DelugePassword = ci.URL, ci.Password
+	case "rtorrent":
+		s.RtorrentUrl, s.RtorrentUsername, s.RtorrentPassword = ci.URL, ci.Username, ci.Password
+	case "aria2":
+		s.Aria2Url, s.Aria2Secret = ci.URL, ci.Password
+	case "watchdir":
+		s.WatchDir = ci.Dir
+	}
+	return s
+}
+
+// clientSavePath returns the save path of the instance for a local directory, empty when it has none
+// The default instance and the built-in seeder share the local filesystem.
+func (ci ClientInstance) clientSavePath(localDir string) string {
+	if ci.Name == ci.Type || ci.Type == "builtin" {
+		return localDir
+	}
+	best, mapped := -1, ""
+	for _, m := range ci.PathMappings {
+		local := filepath.Clean(m.Local)
+		rel, err := filepath.Rel(local, localDir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// The longest matching prefix wins
+		if len(local) > best {
+			best = len(local)
+			mapped = path.Join(m.Remote, filepath.ToSlash(rel))
+		}
+	}
+	return mapped
+}
+
+// clientInstances returns the configured instances, preceded by the client selected
+// in the single-client settings (named after its type) when there is one
+func clientInstances(settings AppSettings) []ClientInstance {
+	var list []ClientInstance
+	if settings.TorrentClient != "" && settings.TorrentClient != "none" {
+		list = append(list, ClientInstance{Name: settings.TorrentClient, Type: settings.TorrentClient})
+	}
+	return append(list, settings.ClientInstances...)
+}
+
+// findClientInstance returns the instance with this name; an empty name is the default instance
+func findClientInstance(settings AppSettings, name string) (ClientInstance, bool) {
+	instances := clientInstances(settings)
+	if name == "" {
+		if len(instances) == 0 {
+			return ClientInstance{}, false
+		}
+		return instances[0], true
+	}
+	for _, ci := range instances {
+		if ci.Name == name {
+			return ci, true
+		}
+	}
+	return ClientInstance{}, false
+}
+
+// resolveClientTargets returns the instances a release goes to
+// The first matching route wins; without match the default instance is used.
+func resolveClientTargets(settings AppSettings, in ClientRouteInput) ([]ClientInstance, error) {
+	for i, route := range settings.ClientRoutes {
+		if !route.matches(in) {
+			continue
+		}
+		targets := make([]ClientInstance, 0, len(route.Clients))
+		for _, name := range route.Clients {
+			ci, ok := findClientInstance(settings, name)
+			if !ok {
+				return nil, fmt.Errorf("client route %d: unknown client instance %q", i+1, name)
+			}
+			targets = append(targets, ci)
+		}
+		return targets, nil
+	}
+	if ci, ok := findClientInstance(settings, ""); ok {
+		return []ClientInstance{ci}, nil
+	}
+	return nil, nil
+}
+
+// validateClientInstances checks the instances and routes of the settings
+func validateClientInstances(s AppSettings, add func(field, format string, args ...interface{})) {
+	names := map[string]bool{}
+	for i, ci := range s.ClientInstances {
+		label := fmt.Sprintf("instance %d", i+1)
+		if ci.Name != "" {
+			label = fmt.Sprintf("instance %q", ci.Name)
+		}
+		_, isType := torrentClientRegistry[ci.Name]
+		switch {
+		case ci.Name == "":
+			add("clientInstances", "%s: name is required", label)
+		case ci.Name == "none" || isType:
+			add("clientInstances", "%s: name must not be a client type", label)
+		case names[ci.Name]:
+			add("clientInstances", "%s: duplicate name", label)
+		}
+		names[ci.Name] = true
+
+		if _, ok := torrentClientRegistry[ci.Type]; !ok {
+			add("clientInstances", "%s: unknown client type %q (expected one of: %s)",
+				label, ci.Type, strings.Join(registeredTorrentClients(), ", "))
+			continue
+		}
+		for _, m := range ci.PathMappings {
+			if !filepath.IsAbs(m.Local) || m.Remote == "" {
+				add("clientInstances", "%s: path mappings need an absolute local path and a remote path", label)
+				break
+			}
+		}
+		switch ci.Type {
+		case "watchdir":
+			if ci.Dir == "" {
+				add("clientInstances", "%s: dir is required", label)
+			} else if err := checkWritableDir(ci.Dir); err != nil {
+				add("clientInstances", "%s: %s: %v", label, ci.Dir, err)
+			}
+		case "rtorrent":
+			if _, err := newRtorrentClient(ci.URL, "", ""); err != nil {
+				add("clientInstances", "%s: %v", label, err)
+			}
+		case "builtin":
+			if !s.EnableSeeder {
+				add("clientInstances", "%s: the built-in seeder is disabled", label)
+			}
+		default:
+			if ci.URL == "" {
+				add("clientInstances", "%s: url is required", label)
+			} else if err := validateHTTPURL(ci.URL); err != nil {
+				add("clientInstances", "%s: %v", label, err)
+			}
+		}
+	}
+
+	for i, route := range s.ClientRoutes {
+		if len(route.Clients) == 0 {
+			add("clientRoutes", "route %d: at least one client instance is required", i+1)
+		}
+		for _, name := range route.Clients {
+			if _, ok := findClientInstance(s, name); !ok || name == "" {
+				add("clientRoutes", "route %d: unknown client instance %q", i+1, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources reported for each settings field
+const (
+	settingSourceDefault = "default"
+	settingSourceDB      = "db"
+	settingSourceFile    = "file"
+	settingSourceEnv     = "env"
+)
+
+// settingsOverride is a value forced by the environment or the config file
+type settingsOverride struct {
+	Source string
+	Value  json.RawMessage
+}
+
+// settingsOverrides holds the AATM_* / config file values, loaded once at startup
+var settingsOverrides = map[string]settingsOverride{}
+
+// settingsField links a json field name to its index in AppSettings
+type settingsField struct {
+	Name  string
+	Index int
+	Kind  reflect.Kind
+}
+
+// appSettingsFields lists the AppSettings fields by their json name
+func appSettingsFields() []settingsField {
+	t := reflect.TypeOf(AppSettings{})
+	fields := make([]settingsField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, settingsField{Name: name, Index: i, Kind: t.Field(i).Type.Kind()})
+	}
+	return fields
+}
+
+// settingEnvName returns the environment variable overriding a field (qbitUrl -> AATM_QBIT_URL)
+// Acronym runs stay whole (clientTLSVerify -> AATM_CLIENT_TLS_VERIFY), as do units ending with a lone capital
+// (seederUploadLimitKiB -> AATM_SEEDER_UPLOAD_LIMIT_KIB).
+func settingEnvName(field string) string {
+	runes := []rune(field)
+	var b strings.Builder
+	b.WriteString("AATM_")
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			last := i == len(runes)-1
+			nextLower := !last && unicode.IsLower(runes[i+1])
+			if ((unicode.IsLower(prev) || unicode.IsDigit(prev)) && !last) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// settingsConfigFile returns the path of the optional settings file
+// AATM_CONFIG_FILE takes precedence over <CONFIG_DIR>/aatm.yaml, aatm.yml or aatm.toml
+func settingsConfigFile() string {
+	if path := os.Getenv("AATM_CONFIG_FILE"); path != "" {
+		return path
+	}
+	for _, name := range []string{"aatm.yaml", "aatm.yml", "aatm.toml"} {
+		path := filepath.Join(dataDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadSettingsOverrides reads the config file and AATM_* variables (env wins over file)
+func LoadSettingsOverrides() error {
+	overrides := map[string]settingsOverride{}
+	fields := appSettingsFields()
+
+	if path := settingsConfigFile(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		values, err := parseSettingsFile(path, data)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		known := map[string]bool{}
+		for _, f := range fields {
+			known[f.Name] = true
+		}
+		for key, val := range values {
+			if !known[key] {
+				logWarn("LoadSettingsOverrides: unknown key %q in %s", key, shortPath(path))
+				continue
+			}
+			raw, err := json.Marshal(val)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s in %s: %w", key, path, err)
+			}
+			overrides[key] = settingsOverride{Source: settingSourceFile, Value: raw}
+		}
+		logInfo("LoadSettingsOverrides: loaded %s", path)
+	}
+
+	for _, f := range fields {
+		envName := settingEnvName(f.Name)
+		val, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		raw, err := envValueToJSON(val, f.Kind)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", envName, err)
+		}
+		overrides[f.Name] = settingsOverride{Source: settingSourceEnv, Value: raw}
+	}
+
+	// Validate every override against the field type up front
+	var probe AppSettings
+	if err := applySettingsOverrides(&probe, overrides); err != nil {
+		return err
+	}
+
+	settingsOverrides = overrides
+	if len(overrides) > 0 {
+		logInfo("LoadSettingsOverrides: %d settings locked by environment/config file", len(overrides))
+	}
+	return nil
+}
+
+// parseSettingsFile decodes a TOML file (.toml) or a YAML one (any other extension)
+func parseSettingsFile(path string, data []byte) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err := toml.Unmarshal(data, &values)
+		return values, err
+	}
+	err := yaml.Unmarshal(data, &values)
+	return values, err
+}
+
+// envValueToJSON converts a raw environment string to JSON for the target field kind
+// Lists accept a JSON array or a comma/newline separated string.
+func envValueToJSON(val string, kind reflect.Kind) (json.RawMessage, error) {
+	switch kind {
+	case reflect.String:
+		return json.Marshal(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(n)
+	case reflect.Slice:
+		trimmed := strings.TrimSpace(val)
+		if strings.HasPrefix(trimmed, "[") {
+			return json.RawMessage(trimmed), nil
+		}
+		items := []string{}
+		for _, item := range strings.FieldsFunc(trimmed, func(r rune) bool { return r == ',' || r == '\n' }) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return json.Marshal(items)
+	default:
+		// Structured fields are given as JSON
+		return json.RawMessage(val), nil
+	}
+}
+
+// applySettingsOverrides writes the override values into settings
+func applySettingsOverrides(settings *AppSettings, overrides map[string]settingsOverride) error {
+	v := reflect.ValueOf(settings).Elem()
+	for _, f := range appSettingsFields() {
+		o, ok := overrides[f.Name]
+		if !ok {
+			continue
+		}
+		target := reflect.New(v.Field(f.Index).Type())
+		if err := json.Unmarshal(o.Value, target.Interface()); err != nil {
+			return fmt.Errorf("invalid %s value for %s: %w", o.Source, f.Name, err)
+		}
+		v.Field(f.Index).Set(target.Elem())
+	}
+	return nil
+}
+
+// keepOverriddenFromStored replaces overridden fields of incoming by their stored values
+// so that saving the form never persists values that come from the environment.
+func keepOverriddenFromStored(incoming *AppSettings, stored AppSettings) {
+	iv := reflect.ValueOf(incoming).Elem()
+	sv := reflect.ValueOf(stored)
+	for _, f := range appSettingsFields() {
+		if _, ok := settingsOverrides[f.Name]; ok {
+			iv.Field(f.Index).Set(sv.Field(f.Index))
+		}
+	}
+}
+
+// getTMDBAPIKey returns the TMDB API key used by the proxy and the connectivity test
+func getTMDBAPIKey() string {
+	if key := os.Getenv("TMDB_API_KEY"); key != "" {
+		return key
+	}
+	return "49d8d37e45764e7c6794ed7dd2d896d4" // Fallback for development
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSettingEnvName(t *testing.T) {
+	for field, want := range map[string]string{
+		"qbitUrl":              "AATM_QBIT_URL",
+		"laCaleUrl":            "AATM_LA_CALE_URL",
+		"aria2Secret":          "AATM_ARIA2_SECRET",
+		"hardlinkDirs":         "AATM_HARDLINK_DIRS",
+		"seederUploadLimitKiB": "AATM_SEEDER_UPLOAD_LIMIT_KIB",
+		"clientTLSVerify":      "AATM_CLIENT_TLS_VERIFY",
+		"tmdbAPI":              "AATM_TMDB_API",
+	} {
+		if got := settingEnvName(field); got != want {
+			t.Errorf("settingEnvName(%q) = %s, want %s", field, got, want)
+		}
+	}
+}
+
+// loadTestOverrides loads the overrides of a config file written in the data dir, restoring the previous ones after the test
+func loadTestOverrides(t *testing.T, name, content string) error {
+	t.Helper()
+	previous := settingsOverrides
+	t.Cleanup(func() { settingsOverrides = previous })
+	t.Setenv("AATM_CONFIG_FILE", "")
+	if name != "" {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return LoadSettingsOverrides()
+}
+
+func TestLoadSettingsOverrides(t *testing.T) {
+	files := map[string]string{
+		"aatm.yaml": "qbitUrl: http://file:8080\nseederPort: 7000\nenableSeeder: true\nhardlinkDirs: [/mnt/a, /mnt/b]\n" +
+			"clientInstances:\n  - {name: nas, type: watchdir, dir: /watch}\nunknownKey: 1\n",
+		"aatm.toml": "qbitUrl = \"http://file:8080\"\nseederPort = 7000\nenableSeeder = true\nhardlinkDirs = [\"/mnt/a\", \"/mnt/b\"]\n" +
+			"unknownKey = 1\n\n[[clientInstances]]\nname = \"nas\"\ntype = \"watchdir\"\ndir = \"/watch\"\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			initTestDB(t)
+			app := &App{}
+			stored := getDefaultSettings()
+			stored.QbitUsername = "stored"
+			if err := app.SaveSettings(stored); err != nil {
+				t.Fatal(err)
+			}
+			// The environment wins over the file
+			t.Setenv("AATM_QBIT_URL", "http://env:8080")
+			t.Setenv("AATM_SEEDER_UPLOAD_LIMIT_KIB", "512")
+			if err := loadTestOverrides(t, name, content); err != nil {
+				t.Fatal(err)
+			}
+
+			settings, sources := app.GetSettingsWithSources()
+			if settings.QbitUrl != "http://env:8080" || settings.SeederPort != 7000 || !settings.EnableSeeder ||
+				settings.SeederUploadLimitKiB != 512 || !reflect.DeepEqual(settings.HardlinkDirs, []string{"/mnt/a", "/mnt/b"}) ||
+				len(settings.ClientInstances) != 1 || settings.ClientInstances[0].Dir != "/watch" {
+				t.Errorf("settings = %+v", settings)
+			}
+			want := map[string]string{"qbitUrl": settingSourceEnv, "seederUploadLimitKiB": settingSourceEnv, "seederPort": settingSourceFile,
+				"hardlinkDirs": settingSourceFile, "qbitUsername": settingSourceDB, "aria2Url": settingSourceDB, "watchDir": settingSourceDefault}
+			for field, source := range want {
+				if sources[field] != source {
+					t.Errorf("source of %s = %s, want %s", field, sources[field], source)
+				}
+			}
+
+			// Saving the form never stores the overridden values
+			settings.QbitUrl = "http://form:8080"
+			settings.QbitUsername = "form"
+			if err := app.SaveSettings(settings); err != nil {
+				t.Fatal(err)
+			}
+			if saved, _ := loadStoredSettings(); saved.QbitUrl != stored.QbitUrl || saved.SeederPort != stored.SeederPort || saved.QbitUsername != "form" {
+				t.Errorf("stored = %s %d %s", saved.QbitUrl, saved.SeederPort, saved.QbitUsername)
+			}
+		})
+	}
+}
+
+func TestLoadSettingsOverridesTypes(t *testing.T) {
+	tests := []struct {
+		name, env, value string
+		ok               bool
+	}{
+		{"bool", "AATM_ENABLE_SEEDER", "yes", false},
+		{"bool", "AATM_ENABLE_SEEDER", "1", true},
+		{"int", "AATM_SEEDER_PORT", "port", false},
+		{"int", "AATM_SEEDER_PORT", " 7000 ", true},
+		{"list", "AATM_HARDLINK_DIRS", "/mnt/a,\n/mnt/b", true},
+		{"json list", "AATM_HARDLINK_DIRS", `["/mnt/a"]`, true},
+		{"json list", "AATM_HARDLINK_DIRS", `["/mnt/a"`, false},
+		{"structured", "AATM_CLIENT_ROUTES", `[{"clients": ["nas"]}]`, true},
+		{"structured", "AATM_CLIENT_ROUTES", `{"clients": "nas"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestDB(t)
+			t.Setenv(tt.env, tt.value)
+			err := loadTestOverrides(t, "", "")
+			if (err == nil) != tt.ok {
+				t.Errorf("%s=%q: err = %v, want ok %v", tt.env, tt.value, err, tt.ok)
+			}
+		})
+	}
+
+	initTestDB(t)
+	t.Setenv("AATM_HARDLINK_DIRS", "/mnt/a,\n /mnt/b ,")
+	if err := loadTestOverrides(t, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := (&App{}).GetSettings().HardlinkDirs; !reflect.DeepEqual(dirs, []string{"/mnt/a", "/mnt/b"}) {
+		t.Errorf("hardlinkDirs = %q", dirs)
+	}
+}
+
+func TestLoadSettingsOverridesInvalidFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"aatm.yaml": "seederPort: [",
+		"aatm.toml": "seederPort = ",
+		"aatm.yml":  "seederPort: nope\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			initTestDB(t)
+			original := settingsOverrides
+			t.Cleanup(func() { settingsOverrides = original })
+			previous := map[string]settingsOverride{"qbitUrl": {Source: settingSourceEnv, Value: []byte(`"http://kept"`)}}
+			settingsOverrides = previous
+			if err := loadTestOverrides(t, name, content); err == nil {
+				t.Error("invalid config file accepted")
+			}
+			if !reflect.DeepEqual(settingsOverrides, previous) {
+				t.Errorf("overrides replaced by an invalid file: %v", settingsOverrides)
+			}
+		})
+	}
+}
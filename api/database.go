@@ -3,6 +3,8 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,6 +14,9 @@ import (
 
 var db *sql.DB
 
+// dataDir is the directory holding aatm.db (and the backups directory)
+var dataDir string
+
 // AppSettings defines the structure of the settings to be saved
 type AppSettings struct {
 	RootPath         string `json:"rootPath"`
@@ -20,7 +25,12 @@ type AppSettings struct {
 	Passkey          string `json:"passkey"`
 	LaCaleEmail      string `json:"laCaleEmail"`
 	LaCalePassword   string `json:"laCalePassword"`
-	// Torrent client selection: "qbittorrent", "transmission", "deluge", "none"
+	// La-Cale site root (mirror, staging or mock) and default anonymous flag of uploads
+	LaCaleUrl       string `json:"laCaleUrl"`
+	LaCaleAnonymous bool   `json:"laCaleAnonymous"`
+	// Additional tracker accounts; La-Cale above is the profile named "lacale"
+	TrackerProfiles []TrackerProfile `json:"trackerProfiles"`
+	// Torrent client selection: "qbittorrent", "transmission", "deluge", "rtorrent", "aria2", "watchdir", "builtin", "none"
 	TorrentClient string `json:"torrentClient"`
 	// qBittorrent settings
 	QbitUrl      string `json:"qbitUrl"`
@@ -33,24 +43,56 @@ type AppSettings struct {
 	// Deluge settings
 	DelugeUrl      string `json:"delugeUrl"`
 	DelugePassword string `json:"delugePassword"`
+	// rTorrent settings: http(s)://host/RPC2, scgi://host:port or scgi:///path/to/socket
+	RtorrentUrl      string `json:"rtorrentUrl"`
+	RtorrentUsername string `json:"rtorrentUsername"`
+	RtorrentPassword string `json:"rtorrentPassword"`
+	// aria2 settings: JSON-RPC endpoint and --rpc-secret
+	Aria2Url    string `json:"aria2Url"`
+	Aria2Secret string `json:"aria2Secret"`
+	// Watch directory of clients without API; torrents go to <watchDir>/<category>
+	WatchDir string `json:"watchDir"`
+	// Additional named clients and the rules choosing where each torrent is added
+	// The client selected above is the default instance, named after its type.
+	ClientInstances []ClientInstance `json:"clientInstances"`
+	ClientRoutes    []ClientRoute    `json:"clientRoutes"`
 	// Display settings
 	ShowProcessed    bool     `json:"showProcessed"`
 	ShowNotProcessed bool     `json:"showNotProcessed"`
 	IsFullAuto       bool     `json:"isFullAuto"`
 	EnableHardlink   bool     `json:"enableHardlink"`
 	HardlinkDirs     []string `json:"hardlinkDirs"`
+	// Automatic database snapshots into <CONFIG_DIR>/backups
+	EnableAutoBackup    bool `json:"enableAutoBackup"`
+	BackupIntervalHours int  `json:"backupIntervalHours"`
+	BackupRetention     int  `json:"backupRetention"`
+	// Logging: level "debug", "info", "warn", "error"; format "text" or "json"
+	LogLevel  string `json:"logLevel"`
+	LogFormat string `json:"logFormat"`
+	// Options used when adding a torrent to the client
+	ClientCategory     string `json:"clientCategory"`
+	ClientTags         string `json:"clientTags"` // comma separated
+	ClientSkipChecking bool   `json:"clientSkipChecking"`
+	// qBittorrent content layout: "Original", "Subfolder" or "NoSubfolder"
+	ClientContentLayout string `json:"clientContentLayout"`
+	// Minutes after which a torrent that never reached seeding is reported
+	SeedingTimeoutMinutes int `json:"seedingTimeoutMinutes"`
+	// Built-in seeder (client "builtin"): listen port and upload limit in KiB/s (0 = unlimited)
+	EnableSeeder         bool `json:"enableSeeder"`
+	SeederPort           int  `json:"seederPort"`
+	SeederUploadLimitKiB int  `json:"seederUploadLimitKiB"`
 }
 
 // InitDB initializes the SQLite database
 func InitDB() {
 	// Use /config directory in container for persistence
-	dataDir := os.Getenv("CONFIG_DIR")
+	dataDir = os.Getenv("CONFIG_DIR")
 	if dataDir == "" {
 		dataDir = "/config"
 	}
 
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Printf("Warning: could not create data dir %s: %v", dataDir, err)
+		logWarn("could not create data dir %s: %v", dataDir, err)
 		// Fallback to local directory
 		dataDir = "."
 	}
@@ -64,7 +106,7 @@ func InitDB() {
 	}
 
 	createTables()
-	log.Printf("Database initialized at %s", dbPath)
+	logInfo("Database initialized at %s", dbPath)
 }
 
 func createTables() {
@@ -77,15 +119,101 @@ func createTables() {
         path TEXT PRIMARY KEY,
         processed_at DATETIME DEFAULT CURRENT_TIMESTAMP
     );
+    CREATE TABLE IF NOT EXISTS releases (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        source_path TEXT NOT NULL,
+        torrent_path TEXT NOT NULL,
+        info_hash TEXT NOT NULL,
+        fingerprint TEXT NOT NULL,
+        total_size INTEGER NOT NULL DEFAULT 0,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS idx_releases_info_hash ON releases (info_hash);
+    CREATE INDEX IF NOT EXISTS idx_releases_fingerprint ON releases (fingerprint);
+    CREATE TABLE IF NOT EXISTS seeder_torrents (
+        info_hash TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        torrent_path TEXT NOT NULL,
+        save_dir TEXT NOT NULL,
+        size INTEGER NOT NULL DEFAULT 0,
+        uploaded INTEGER NOT NULL DEFAULT 0,
+        paused INTEGER NOT NULL DEFAULT 0,
+        added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE TABLE IF NOT EXISTS tag_catalogs (
+        version INTEGER PRIMARY KEY AUTOINCREMENT,
+        tracker TEXT NOT NULL,
+        data TEXT NOT NULL,
+        tag_count INTEGER NOT NULL DEFAULT 0,
+        diff TEXT,
+        synced_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE TABLE IF NOT EXISTS tracker_sessions (
+        profile TEXT PRIMARY KEY,
+        data BLOB NOT NULL,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
     `
 	_, err := db.Exec(query)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	// Columns added after the first release of each table
+	migrations := []struct{ table, column, definition string }{
+		{"releases", "client", "TEXT"},
+		{"releases", "client_added_at", "TEXT"},
+		{"releases", "seed_state", "TEXT"},
+		{"releases", "seed_progress", "REAL NOT NULL DEFAULT 0"},
+		{"releases", "seed_uploaded", "INTEGER NOT NULL DEFAULT 0"},
+		{"releases", "seed_ratio", "REAL NOT NULL DEFAULT 0"},
+		{"releases", "tracker_status", "TEXT"},
+		{"releases", "seed_error", "TEXT"},
+		{"releases", "seeded_at", "TEXT"},
+		{"releases", "seed_checked_at", "TEXT"},
+		{"releases", "seed_timed_out", "INTEGER NOT NULL DEFAULT 0"},
+		{"releases", "clients", "TEXT"},
+	}
+	for _, m := range migrations {
+		if err := addColumnIfMissing(m.table, m.column, m.definition); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// addColumnIfMissing adds a column to an existing table (SQLite has no ADD COLUMN IF NOT EXISTS)
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // SaveSettings saves the application settings to the database
+// Fields locked by AATM_* variables or the config file keep their stored value.
 func (a *App) SaveSettings(settings AppSettings) error {
+	stored, _ := loadStoredSettings()
+	keepOverriddenFromStored(&settings, stored)
 	data, err := json.Marshal(settings)
 	if err != nil {
 		return err
@@ -94,17 +222,64 @@ func (a *App) SaveSettings(settings AppSettings) error {
 	return err
 }
 
-// GetSettings retrieves the application settings from the database
+// GetSettings retrieves the effective application settings (database + overrides)
 func (a *App) GetSettings() AppSettings {
+	settings, _ := a.GetSettingsWithSources()
+	return settings
+}
+
+// GetSettingsWithSources returns the effective settings and the source of each field
+// ("default", "db", "file" or "env")
+func (a *App) GetSettingsWithSources() (AppSettings, map[string]string) {
+	settings, storedKeys := loadStoredSettings()
+	sources := map[string]string{}
+	for _, f := range appSettingsFields() {
+		if o, ok := settingsOverrides[f.Name]; ok {
+			sources[f.Name] = o.Source
+		} else if storedKeys[f.Name] {
+			sources[f.Name] = settingSourceDB
+		} else {
+			sources[f.Name] = settingSourceDefault
+		}
+	}
+	// Overrides are validated by LoadSettingsOverrides at startup
+	applySettingsOverrides(&settings, settingsOverrides)
+	return settings, sources
+}
+
+// loadStoredSettings reads the settings saved in the database, without overrides
+// It also returns the fields that hold a stored (non-empty) value.
+func loadStoredSettings() (AppSettings, map[string]bool) {
+	storedKeys := map[string]bool{}
 	var data string
 	err := db.QueryRow("SELECT data FROM settings WHERE id = 1").Scan(&data)
 	if err != nil {
 		// Return default settings
-		return getDefaultSettings()
+		return getDefaultSettings(), storedKeys
 	}
 	var settings AppSettings
 	json.Unmarshal([]byte(data), &settings)
-	// Fill in defaults for empty values
+
+	var raw map[string]interface{}
+	json.Unmarshal([]byte(data), &raw)
+	for key, val := range raw {
+		switch v := val.(type) {
+		case nil:
+		case string:
+			storedKeys[key] = v != ""
+		case float64:
+			storedKeys[key] = v != 0
+		default:
+			storedKeys[key] = true
+		}
+	}
+
+	fillSettingsDefaults(&settings)
+	return settings, storedKeys
+}
+
+// fillSettingsDefaults replaces the empty values of settings by their defaults
+func fillSettingsDefaults(settings *AppSettings) {
 	defaults := getDefaultSettings()
 	if settings.RootPath == "" {
 		settings.RootPath = defaults.RootPath
@@ -112,6 +287,9 @@ func (a *App) GetSettings() AppSettings {
 	if settings.TorrentClient == "" {
 		settings.TorrentClient = defaults.TorrentClient
 	}
+	if settings.LaCaleUrl == "" {
+		settings.LaCaleUrl = defaults.LaCaleUrl
+	}
 	if settings.QbitUrl == "" {
 		settings.QbitUrl = defaults.QbitUrl
 	}
@@ -130,9 +308,35 @@ func (a *App) GetSettings() AppSettings {
 	if settings.DelugePassword == "" {
 		settings.DelugePassword = defaults.DelugePassword
 	}
+	if settings.RtorrentUrl == "" {
+		settings.RtorrentUrl = defaults.RtorrentUrl
+	}
+	if settings.Aria2Url == "" {
+		settings.Aria2Url = defaults.Aria2Url
+	}
+	if settings.BackupIntervalHours <= 0 {
+		settings.BackupIntervalHours = defaults.BackupIntervalHours
+	}
+	if settings.BackupRetention <= 0 {
+		settings.BackupRetention = defaults.BackupRetention
+	}
+	if settings.LogLevel == "" {
+		settings.LogLevel = defaults.LogLevel
+	}
+	if settings.LogFormat == "" {
+		settings.LogFormat = defaults.LogFormat
+	}
+	if settings.ClientContentLayout == "" {
+		settings.ClientContentLayout = defaults.ClientContentLayout
+	}
+	if settings.SeedingTimeoutMinutes <= 0 {
+		settings.SeedingTimeoutMinutes = defaults.SeedingTimeoutMinutes
+	}
+	if settings.SeederPort <= 0 {
+		settings.SeederPort = defaults.SeederPort
+	}
 	// Les valeurs booléennes ne peuvent pas être testées pour "vide", on utilise les defaults si non définies explicitement
 	// Ces champs seront toujours définis par le frontend, mais on applique les defaults par sécurité
-	return settings
 }
 
 // getDefaultSettings returns the default application settings
@@ -145,20 +349,30 @@ func getDefaultSettings() AppSettings {
 	}
 
 	return AppSettings{
-		RootPath:             defaultRootPath,
-		TorrentTrackers:      "",
-		IsPrivateTorrent:     true,
-		TorrentClient:        "qbittorrent",
-		QbitUrl:              "http://localhost:8081",
-		QbitUsername:         "admin",
-		QbitPassword:         "adminadmin",
-		TransmissionUrl:      "http://localhost:9091",
-		TransmissionUsername: "",
-		TransmissionPassword: "",
-		DelugeUrl:            "http://localhost:8112",
-		DelugePassword:       "deluge",
-		ShowProcessed:        false,
-		ShowNotProcessed:     true,
+		RootPath:              defaultRootPath,
+		TorrentTrackers:       "",
+		IsPrivateTorrent:      true,
+		LaCaleUrl:             laCaleDefaultURL,
+		TorrentClient:         "qbittorrent",
+		QbitUrl:               "http://localhost:8081",
+		QbitUsername:          "admin",
+		QbitPassword:          "adminadmin",
+		TransmissionUrl:       "http://localhost:9091",
+		TransmissionUsername:  "",
+		TransmissionPassword:  "",
+		DelugeUrl:             "http://localhost:8112",
+		DelugePassword:        "deluge",
+		RtorrentUrl:           "scgi://localhost:5000",
+		Aria2Url:              "http://localhost:6800/jsonrpc",
+		ShowProcessed:         false,
+		ShowNotProcessed:      true,
+		BackupIntervalHours:   24,
+		BackupRetention:       7,
+		LogLevel:              "info",
+		LogFormat:             "text",
+		ClientContentLayout:   "Original",
+		SeedingTimeoutMinutes: 30,
+		SeederPort:            6882,
 	}
 }
 
@@ -205,3 +419,132 @@ func (a *App) GetAllProcessedFiles() ([]map[string]string, error) {
 	}
 	return files, nil
 }
+
+// Release is an entry of the release history (one per created torrent)
+type Release struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	SourcePath  string `json:"sourcePath"`
+	TorrentPath string `json:"torrentPath"`
+	InfoHash    string `json:"infoHash"`
+	Fingerprint string `json:"fingerprint"`
+	TotalSize   int64  `json:"totalSize"`
+	CreatedAt   string `json:"createdAt"`
+	// Torrent client tracking, updated by the seeding monitor
+	// Client is the instance followed by the monitor, the first of Clients (every instance that accepted the torrent)
+	Client        string   `json:"client,omitempty"`
+	Clients       []string `json:"clients,omitempty"`
+	ClientAddedAt string   `json:"clientAddedAt,omitempty"`
+	SeedState     string   `json:"seedState,omitempty"`
+	SeedProgress  float64  `json:"seedProgress"`
+	SeedUploaded  int64    `json:"seedUploaded"`
+	SeedRatio     float64  `json:"seedRatio"`
+	TrackerStatus string   `json:"trackerStatus,omitempty"`
+	SeedError     string   `json:"seedError,omitempty"`
+	SeededAt      string   `json:"seededAt,omitempty"`
+	SeedCheckedAt string   `json:"seedCheckedAt,omitempty"`
+	// SeedTimedOut is set when the torrent didn't reach seeding within SeedingTimeoutMinutes
+	SeedTimedOut bool `json:"seedTimedOut"`
+}
+
+const releaseColumns = "id, name, source_path, torrent_path, info_hash, fingerprint, total_size, created_at, " +
+	"COALESCE(client, ''), COALESCE(client_added_at, ''), COALESCE(seed_state, ''), seed_progress, seed_uploaded, seed_ratio, " +
+	"COALESCE(tracker_status, ''), COALESCE(seed_error, ''), COALESCE(seeded_at, ''), COALESCE(seed_checked_at, ''), seed_timed_out, COALESCE(clients, '')"
+
+// recordRelease stores a created torrent in the release history
+// Creating the same torrent again (same infohash or same file) updates its entry, so it doesn't become its own duplicate.
+func recordRelease(r Release) error {
+	if db == nil {
+		return nil
+	}
+	var id int64
+	var infoHash string
+	err := db.QueryRow("SELECT id, info_hash FROM releases WHERE info_hash = ? OR torrent_path = ? ORDER BY info_hash = ? DESC, id DESC LIMIT 1",
+		r.InfoHash, r.TorrentPath, r.InfoHash).Scan(&id, &infoHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = db.Exec(
+			"INSERT INTO releases (name, source_path, torrent_path, info_hash, fingerprint, total_size) VALUES (?, ?, ?, ?, ?, ?)",
+			r.Name, r.SourcePath, r.TorrentPath, r.InfoHash, r.Fingerprint, r.TotalSize,
+		)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up release %s: %w", r.TorrentPath, err)
+	}
+
+	if _, err := db.Exec("UPDATE releases SET name = ?, source_path = ?, torrent_path = ?, info_hash = ?, fingerprint = ?, total_size = ?, "+
+		"created_at = CURRENT_TIMESTAMP WHERE id = ?",
+		r.Name, r.SourcePath, r.TorrentPath, r.InfoHash, r.Fingerprint, r.TotalSize, id); err != nil {
+		return err
+	}
+	if infoHash != r.InfoHash {
+		// A different torrent was written over the file: the client tracking was about the previous one
+		_, err = db.Exec("UPDATE releases SET client = NULL, clients = NULL, client_added_at = NULL, seed_state = NULL, seed_progress = 0, "+
+			"seed_uploaded = 0, seed_ratio = 0, tracker_status = NULL, seed_error = NULL, seeded_at = NULL, seed_checked_at = NULL, "+
+			"seed_timed_out = 0 WHERE id = ?", id)
+	}
+	return err
+}
+
+// replaceReleaseTorrent points the releases of a torrent to the one rewritten by a tracker
+func replaceReleaseTorrent(torrentPath, trackerTorrentPath string) error {
+	if db == nil {
+		return nil
+	}
+	infoHash, err := torrentInfoHash(trackerTorrentPath)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE releases SET torrent_path = ?, info_hash = ? WHERE torrent_path = ?",
+		trackerTorrentPath, infoHash, torrentPath)
+	return err
+}
+
+// queryReleases runs a SELECT on the releases table and scans every row
+func queryReleases(where string, args ...interface{}) ([]Release, error) {
+	query := "SELECT " + releaseColumns + " FROM releases"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []Release{}
+	for rows.Next() {
+		var r Release
+		var clients string
+		if err := rows.Scan(&r.ID, &r.Name, &r.SourcePath, &r.TorrentPath, &r.InfoHash, &r.Fingerprint, &r.TotalSize, &r.CreatedAt,
+			&r.Client, &r.ClientAddedAt, &r.SeedState, &r.SeedProgress, &r.SeedUploaded, &r.SeedRatio,
+			&r.TrackerStatus, &r.SeedError, &r.SeededAt, &r.SeedCheckedAt, &r.SeedTimedOut, &clients); err != nil {
+			return nil, fmt.Errorf("failed to scan release: %w", err)
+		}
+		if clients != "" {
+			if err := json.Unmarshal([]byte(clients), &r.Clients); err != nil {
+				return nil, fmt.Errorf("invalid clients of release %d: %w", r.ID, err)
+			}
+		} else if r.Client != "" {
+			// Recorded before every accepting instance was kept
+			r.Clients = []string{r.Client}
+		}
+		releases = append(releases, r)
+	}
+	return releases, rows.Err()
+}
+
+// GetReleases returns the release history, most recent first
+func (a *App) GetReleases() ([]Release, error) {
+	return queryReleases("")
+}
+
+// findReleasesByFingerprint returns earlier releases sharing the content fingerprint
+func findReleasesByFingerprint(fingerprint string) ([]Release, error) {
+	if db == nil || fingerprint == "" {
+		return []Release{}, nil
+	}
+	return queryReleases("fingerprint = ?", fingerprint)
+}
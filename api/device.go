@@ -14,3 +14,12 @@ func getDeviceID(path string) (uint64, error) {
 	}
 	return uint64(stat.Dev), nil
 }
+
+// getFreeSpace returns the bytes available to unprivileged users on the filesystem containing the path
+func getFreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -10,3 +10,9 @@ import "errors"
 func getDeviceID(path string) (uint64, error) {
 	return 0, errors.New("getDeviceID not supported on this platform")
 }
+
+// getFreeSpace returns the bytes available on the filesystem containing the path
+// On non-Linux systems, this is not supported
+func getFreeSpace(path string) (uint64, error) {
+	return 0, errors.New("getFreeSpace not supported on this platform")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Size of each chunk read when sampling the largest file for the content fingerprint
+const fingerprintSampleSize = 64 * 1024
+
+// DuplicateCheckRequest is the payload of POST /api/duplicates/check
+type DuplicateCheckRequest struct {
+	SourcePath   string `json:"sourcePath"`
+	Title        string `json:"title,omitempty"`
+	CheckTracker bool   `json:"checkTracker"`
+	// Tracker is the tracker profile searched for duplicates ("lacale" when empty)
+	Tracker string `json:"tracker,omitempty"`
+}
+
+// TrackerMatch is a release found on the tracker that looks like the one being processed
+type TrackerMatch struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	InfoHash string `json:"infoHash,omitempty"`
+}
+
+// DuplicateReport lists the earlier releases matching the content being processed
+type DuplicateReport struct {
+	Fingerprint    string         `json:"fingerprint"`
+	Matches        []Release      `json:"matches"`
+	TrackerMatches []TrackerMatch `json:"trackerMatches"`
+	// TrackerCheck is "ok", "skipped", "unavailable" or an error message
+	TrackerCheck string `json:"trackerCheck"`
+}
+
+// ComputeContentFingerprint returns a name-independent fingerprint of the content at path
+// The fingerprint combines the sorted file sizes with a sample hash (start, middle, end)
+// of the largest file, so renamed or moved copies of the same content are detected.
+func ComputeContentFingerprint(path string) (string, int64, error) {
+	var sizes []int64
+	var totalSize int64
+	largestPath := ""
+	var largestSize int64 = -1
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		sizes = append(sizes, info.Size())
+		totalSize += info.Size()
+		if info.Size() > largestSize {
+			largestSize = info.Size()
+			largestPath = p
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	if largestPath == "" {
+		return "", 0, fmt.Errorf("no file found in %s", path)
+	}
+
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	h := sha256.New()
+	for _, s := range sizes {
+		h.Write([]byte(strconv.FormatInt(s, 10)))
+		h.Write([]byte{'\n'})
+	}
+
+	f, err := os.Open(largestPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	offsets := []int64{0}
+	if largestSize > 3*fingerprintSampleSize {
+		offsets = append(offsets, largestSize/2, largestSize-fingerprintSampleSize)
+	}
+	buf := make([]byte, fingerprintSampleSize)
+	for _, off := range offsets {
+		n, err := f.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return "", 0, err
+		}
+		h.Write(buf[:n])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), totalSize, nil
+}
+
+// CheckDuplicates looks for earlier releases of the same content in the history and on the tracker
+func (a *App) CheckDuplicates(ctx context.Context, req DuplicateCheckRequest) (*DuplicateReport, error) {
+	report := &DuplicateReport{
+		Matches:        []Release{},
+		TrackerMatches: []TrackerMatch{},
+		TrackerCheck:   "skipped",
+	}
+
+	fingerprint, _, err := ComputeContentFingerprint(req.SourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint content: %w", err)
+	}
+	report.Fingerprint = fingerprint
+
+	// The check runs before the torrent exists: the history is searched by content, and
+	// recordRelease keys the entries on the infohash so a re-created torrent keeps a single one
+	matches, err := findReleasesByFingerprint(report.Fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query release history: %w", err)
+	}
+	report.Matches = matches
+
+	if req.CheckTracker && req.Title != "" {
+		if req.Tracker == "" {
+			req.Tracker = "lacale"
+		}
+		profile, ok := findTrackerProfile(a.GetSettings(), req.Tracker)
+		if !ok || (profile.Username == "" && profile.APIKey == "") {
+			report.TrackerCheck = "unavailable"
+		} else if tracker, err := newTrackerUploader(profile); err != nil {
+			report.TrackerCheck = err.Error()
+		} else if trackerMatches, err := tracker.Search(ctx, req.Title); err != nil {
+			logWarnCtx(ctx, "CheckDuplicates: %s search failed: %v", req.Tracker, err)
+			report.TrackerCheck = err.Error()
+		} else {
+			report.TrackerMatches = trackerMatches
+			report.TrackerCheck = "ok"
+		}
+	}
+
+	if len(report.Matches) > 0 || len(report.TrackerMatches) > 0 {
+		logWarnCtx(ctx, "CheckDuplicates: %s matches %d earlier release(s) and %d tracker release(s)",
+			shortPath(req.SourcePath), len(report.Matches), len(report.TrackerMatches))
+	}
+	return report, nil
+}
+
+// parseTrackerSearchResults accepts either a bare array or an object wrapping it in "data", "torrents" or "results"
+func parseTrackerSearchResults(body []byte) ([]TrackerMatch, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(body, &items); err != nil {
+		var wrapped map[string]json.RawMessage
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to decode search response: %w", err)
+		}
+		for _, key := range []string{"data", "torrents", "results"} {
+			if raw, ok := wrapped[key]; ok {
+				if err := json.Unmarshal(raw, &items); err == nil {
+					break
+				}
+			}
+		}
+	}
+
+	str := func(item map[string]interface{}, keys ...string) string {
+		for _, k := range keys {
+			switch v := item[k].(type) {
+			case string:
+				if v != "" {
+					return v
+				}
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return ""
+	}
+
+	matches := []TrackerMatch{}
+	for _, item := range items {
+		// JSON:API resources (UNIT3D) keep the fields in "attributes"
+		if attrs, ok := item["attributes"].(map[string]interface{}); ok {
+			for k, v := range attrs {
+				if _, exists := item[k]; !exists {
+					item[k] = v
+				}
+			}
+		}
+		matches = append(matches, TrackerMatch{
+			ID:       str(item, "id", "slug"),
+			Name:     str(item, "name", "title"),
+			InfoHash: strings.ToLower(str(item, "infoHash", "info_hash")),
+		})
+	}
+	return matches, nil
+}
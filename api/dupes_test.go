@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateTorrentKeepsOneRelease(t *testing.T) {
+	initTestDB(t)
+	app := &App{}
+	ctx := context.Background()
+	source := filepath.Join(t.TempDir(), "Movie.2024.mkv")
+	if err := os.WriteFile(source, []byte("movie content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	torrentPath, err := app.CreateTorrent(ctx, source, []string{"http://tracker.test/announce"}, "AATM", true, "Movie.2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := torrentInfoHash(torrentPath)
+	if err := markReleaseAddedToClient(first, []string{"qbittorrent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same torrent created again updates its entry and keeps its client tracking
+	if _, err := app.CreateTorrent(ctx, source, []string{"http://tracker.test/announce"}, "AATM", true, "Movie.2024"); err != nil {
+		t.Fatal(err)
+	}
+	releases, _ := app.GetReleases()
+	if len(releases) != 1 || releases[0].Client != "qbittorrent" {
+		t.Fatalf("releases = %+v, want one entry still added to qbittorrent", releases)
+	}
+
+	// The history reports the earlier release of the content before the next creation
+	report, err := app.CheckDuplicates(ctx, DuplicateCheckRequest{SourcePath: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Matches) != 1 || report.Matches[0].TorrentPath != torrentPath {
+		t.Errorf("matches = %+v, want the earlier release", report.Matches)
+	}
+
+	// Another torrent written over the file replaces the entry and its stale client tracking
+	if _, err := app.CreateTorrent(ctx, source, []string{"http://other.test/announce"}, "AATM", false, "Movie.2024"); err != nil {
+		t.Fatal(err)
+	}
+	releases, _ = app.GetReleases()
+	second, _ := torrentInfoHash(torrentPath)
+	if second == first {
+		t.Fatal("a public torrent has the info hash of the private one")
+	}
+	if len(releases) != 1 || !strings.EqualFold(releases[0].InfoHash, second) || releases[0].Client != "" {
+		t.Errorf("releases = %+v, want one entry for %s without client", releases, second)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// readinessTTL is how long a readiness report is served from cache
+const readinessTTL = 30 * time.Second
+
+// Free space thresholds of the output directories
+const (
+	freeSpaceWarnBytes = 5 << 30
+	freeSpaceFailBytes = 500 << 20
+)
+
+// Statuses of a single check
+const (
+	checkOK      = "ok"
+	checkWarn    = "warn"
+	checkFail    = "fail"
+	checkSkipped = "skipped"
+)
+
+// HealthCheck is the result of one dependency check
+type HealthCheck struct {
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	Critical   bool              `json:"critical"`
+	Message    string            `json:"message,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	DurationMs int64             `json:"durationMs"`
+}
+
+// ReadinessReport is returned by GET /health/ready
+// Status is "ok", "degraded" (a non-critical check failed or warned) or "fail" (a critical check failed).
+type ReadinessReport struct {
+	Status    string        `json:"status"`
+	CheckedAt time.Time     `json:"checkedAt"`
+	Cached    bool          `json:"cached"`
+	Checks    []HealthCheck `json:"checks"`
+}
+
+// readinessCache keeps the last report until it expires
+// The mutex only guards the report: checks run outside of it.
+var readinessCache struct {
+	sync.Mutex
+	report  *ReadinessReport
+	expires time.Time
+}
+
+// readinessRuns makes the probes arriving while the checks run wait for that run instead of starting another
+var readinessRuns singleflight.Group
+
+// runReadiness runs the checks of a report (replaced in tests)
+var runReadiness = (*App).runReadinessChecks
+
+// CheckReadiness returns the cached readiness report, running the checks again when it expired or refresh is set
+func (a *App) CheckReadiness(refresh bool) ReadinessReport {
+	readinessCache.Lock()
+	cached, expires := readinessCache.report, readinessCache.expires
+	readinessCache.Unlock()
+	if !refresh && cached != nil && time.Now().Before(expires) {
+		report := *cached
+		report.Cached = true
+		return report
+	}
+
+	v, _, _ := readinessRuns.Do("ready", func() (interface{}, error) {
+		report := runReadiness(a)
+		readinessCache.Lock()
+		readinessCache.report = &report
+		readinessCache.expires = time.Now().Add(readinessTTL)
+		readinessCache.Unlock()
+		return report, nil
+	})
+	return v.(ReadinessReport)
+}
+
+// readinessCheck is a dependency check of the readiness report
+type readinessCheck struct {
+	name     string
+	critical bool
+	run      func(*HealthCheck) error
+}
+
+// readinessChecks lists the checks of the current settings
+// Every client instance that can receive torrents is checked: the default one and those of the routing rules.
+func (a *App) readinessChecks(settings AppSettings) []readinessCheck {
+	checks := []readinessCheck{
+		{"database", true, checkDatabase},
+		{"mediainfo", true, checkMediainfo},
+		{"disk", true, checkOutputDirs},
+		{"hardlinkDirs", false, func(c *HealthCheck) error { return checkHardlinkDirs(settings, c) }},
+	}
+	instances := readinessClientInstances(settings)
+	if len(instances) == 0 {
+		checks = append(checks, readinessCheck{"torrentClient", false, func(c *HealthCheck) error {
+			c.Status = checkSkipped
+			c.Message = "no torrent client configured"
+			return nil
+		}})
+	}
+	for _, ci := range instances {
+		name := ci.Name
+		check := "torrentClient"
+		if ci.Name != settings.TorrentClient {
+			check += ":" + ci.Name
+		}
+		checks = append(checks, readinessCheck{check, false, func(c *HealthCheck) error {
+			return connectionCheck(a.TestConnection(name, settings), c)
+		}})
+	}
+	return append(checks, readinessCheck{"tmdb", false, func(c *HealthCheck) error { return a.checkTMDB(settings, c) }})
+}
+
+// readinessClientInstances returns the default client instance followed by the ones named in routing rules
+func readinessClientInstances(settings AppSettings) []ClientInstance {
+	var list []ClientInstance
+	seen := map[string]bool{}
+	add := func(name string) {
+		if ci, ok := findClientInstance(settings, name); ok && !seen[ci.Name] {
+			seen[ci.Name] = true
+			list = append(list, ci)
+		}
+	}
+	if settings.TorrentClient != "" && settings.TorrentClient != "none" {
+		add(settings.TorrentClient)
+	}
+	for _, route := range settings.ClientRoutes {
+		for _, name := range route.Clients {
+			add(name)
+		}
+	}
+	return list
+}
+
+// runReadinessChecks runs every dependency check concurrently
+func (a *App) runReadinessChecks() ReadinessReport {
+	return runHealthChecks(a.readinessChecks(a.GetSettings()))
+}
+
+// runHealthChecks runs the checks concurrently and sums their statuses up
+func runHealthChecks(checks []readinessCheck) ReadinessReport {
+	results := make([]HealthCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, name string, critical bool, run func(*HealthCheck) error) {
+			defer wg.Done()
+			start := time.Now()
+			result := HealthCheck{Name: name, Critical: critical, Status: checkOK, Details: map[string]string{}}
+			if err := run(&result); err != nil {
+				result.Status = checkFail
+				result.Message = err.Error()
+			}
+			result.DurationMs = time.Since(start).Milliseconds()
+			results[i] = result
+		}(i, c.name, c.critical, c.run)
+	}
+	wg.Wait()
+
+	report := ReadinessReport{Status: "ok", CheckedAt: time.Now(), Checks: results}
+	for _, c := range results {
+		switch {
+		case c.Status == checkFail && c.Critical:
+			report.Status = "fail"
+		case (c.Status == checkFail || c.Status == checkWarn) && report.Status == "ok":
+			report.Status = "degraded"
+		}
+	}
+	if report.Status != "ok" {
+		for _, c := range results {
+			if c.Status == checkFail || c.Status == checkWarn {
+				logWarn("CheckReadiness: %s %s: %s", c.Name, c.Status, c.Message)
+			}
+		}
+	}
+	return report
+}
+
+// checkDatabase pings the DB and checks that its file can be written
+func checkDatabase(c *HealthCheck) error {
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	dbPath := filepath.Join(dataDir, "aatm.db")
+	c.Details["path"] = dbPath
+	f, err := os.OpenFile(dbPath, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("database file is not writable: %v", err)
+	}
+	f.Close()
+	// SQLite also needs to create its journal next to the DB file
+	if err := checkWritableDir(dataDir); err != nil {
+		return fmt.Errorf("%s: %v", dataDir, err)
+	}
+	return nil
+}
+
+// checkMediainfo looks mediainfo up in PATH and reports its version
+func checkMediainfo(c *HealthCheck) error {
+	path, err := exec.LookPath("mediainfo")
+	if err != nil {
+		return fmt.Errorf("mediainfo not found in PATH")
+	}
+	c.Details["path"] = path
+
+	out, err := exec.Command(path, "--Version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mediainfo --Version failed: %v", err)
+	}
+	// Output looks like "MediaInfo Command line,\nMediaInfoLib - v23.04"
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	version := strings.TrimSpace(lines[len(lines)-1])
+	if i := strings.LastIndex(version, " v"); i >= 0 {
+		version = version[i+2:]
+	}
+	c.Details["version"] = version
+	return nil
+}
+
+// checkOutputDirs reports the free space of the directories the app writes to
+func checkOutputDirs(c *HealthCheck) error {
+	dirs := []string{dataDir}
+	// Torrents and NFOs of read-only sources go to /torrents
+	if fi, err := os.Stat("/torrents"); err == nil && fi.IsDir() {
+		dirs = append(dirs, "/torrents")
+	}
+
+	var problems []string
+	for _, dir := range dirs {
+		free, err := getFreeSpace(dir)
+		if err != nil {
+			c.Details[dir] = "unknown"
+			continue
+		}
+		c.Details[dir] = formatSize(int64(free)) + " free"
+		switch {
+		case free < freeSpaceFailBytes:
+			problems = append(problems, fmt.Sprintf("%s: only %s free", dir, formatSize(int64(free))))
+		case free < freeSpaceWarnBytes && c.Status == checkOK:
+			c.Status = checkWarn
+			c.Message = fmt.Sprintf("%s: low disk space (%s free)", dir, formatSize(int64(free)))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkHardlinkDirs verifies the hardlink directories are writable when hardlinks are enabled
+func checkHardlinkDirs(settings AppSettings, c *HealthCheck) error {
+	if !settings.EnableHardlink {
+		c.Status = checkSkipped
+		c.Message = "hardlinks disabled"
+		return nil
+	}
+	if len(settings.HardlinkDirs) == 0 {
+		return fmt.Errorf("hardlinks enabled but no directory configured")
+	}
+	var problems []string
+	for _, dir := range settings.HardlinkDirs {
+		if err := checkWritableDir(dir); err != nil {
+			c.Details[dir] = err.Error()
+			problems = append(problems, fmt.Sprintf("%s: %v", dir, err))
+			continue
+		}
+		c.Details[dir] = "writable"
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkTMDB checks that the TMDB API answers with the configured key
+func (a *App) checkTMDB(settings AppSettings, c *HealthCheck) error {
+	return connectionCheck(a.TestConnection("tmdb", settings), c)
+}
+
+// connectionCheck copies a connectivity test result into a health check
+func connectionCheck(result ConnectionTestResult, c *HealthCheck) error {
+	c.Details["target"] = result.Target
+	if result.Version != "" {
+		c.Details["version"] = result.Version
+	}
+	if !result.OK {
+		return fmt.Errorf("%s", result.Error)
+	}
+	return nil
+}
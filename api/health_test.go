@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadinessClientInstances(t *testing.T) {
+	settings := getDefaultSettings()
+	settings.ClientInstances = []ClientInstance{
+		{Name: "seedbox", Type: "qbittorrent", URL: "http://seedbox.test"},
+		{Name: "nas", Type: "watchdir", Dir: "/watch"},
+		{Name: "unused", Type: "transmission", URL: "http://unused.test"},
+	}
+	settings.ClientRoutes = []ClientRoute{
+		{MediaType: "episode", Clients: []string{"nas", "seedbox"}},
+		{Tracker: "blutopia", Clients: []string{"seedbox", "missing"}},
+	}
+	var names []string
+	for _, ci := range readinessClientInstances(settings) {
+		names = append(names, ci.Name)
+	}
+	if len(names) != 3 || names[0] != "qbittorrent" || names[1] != "nas" || names[2] != "seedbox" {
+		t.Errorf("instances = %v, want the default one then the routed ones", names)
+	}
+
+	settings.TorrentClient = "none"
+	settings.ClientRoutes = nil
+	if list := readinessClientInstances(settings); len(list) != 0 {
+		t.Errorf("instances without client = %+v", list)
+	}
+}
+
+func TestReadinessChecksRoutedInstances(t *testing.T) {
+	_, defaultSrv := newFakeQBittorrent(t, "")
+	_, seedboxSrv := newFakeQBittorrent(t, "")
+	settings := getDefaultSettings()
+	settings.QbitUrl, settings.QbitUsername, settings.QbitPassword = defaultSrv.URL, "admin", "secret"
+	settings.ClientInstances = []ClientInstance{
+		{Name: "seedbox", Type: "qbittorrent", URL: seedboxSrv.URL, Username: "admin", Password: "secret"},
+		{Name: "nas", Type: "qbittorrent", URL: seedboxSrv.URL, Username: "admin", Password: "wrong"},
+	}
+	settings.ClientRoutes = []ClientRoute{{MediaType: "episode", Clients: []string{"seedbox", "nas"}}}
+
+	var clientChecks []readinessCheck
+	for _, c := range (&App{}).readinessChecks(settings) {
+		if c.name == "torrentClient" || c.name == "torrentClient:seedbox" || c.name == "torrentClient:nas" {
+			clientChecks = append(clientChecks, c)
+		}
+	}
+	if len(clientChecks) != 3 {
+		t.Fatalf("client checks = %d, want the default instance and the two routed ones", len(clientChecks))
+	}
+	report := runHealthChecks(clientChecks)
+	want := map[string]string{"torrentClient": checkOK, "torrentClient:seedbox": checkOK, "torrentClient:nas": checkFail}
+	for _, c := range report.Checks {
+		if c.Status != want[c.Name] || c.Critical {
+			t.Errorf("%s = %s (critical %v, %s), want %s", c.Name, c.Status, c.Critical, c.Message, want[c.Name])
+		}
+	}
+	// A routed client that is down degrades the report without failing it
+	if report.Status != "degraded" {
+		t.Errorf("status = %s, want degraded", report.Status)
+	}
+}
+
+// stubReadiness replaces the readiness checks by run and clears the cache, restoring both after the test
+func stubReadiness(t *testing.T, run func(*App) ReadinessReport) {
+	t.Helper()
+	previous := runReadiness
+	runReadiness = run
+	clear := func() {
+		readinessCache.Lock()
+		readinessCache.report = nil
+		readinessCache.Unlock()
+	}
+	clear()
+	t.Cleanup(func() {
+		runReadiness = previous
+		clear()
+	})
+}
+
+func TestCheckReadinessCache(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	stubReadiness(t, func(*App) ReadinessReport {
+		runs.Add(1)
+		<-release
+		return ReadinessReport{Status: "ok", CheckedAt: time.Now()}
+	})
+	app := &App{}
+
+	// Probes arriving during a run share it
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if report := app.CheckReadiness(false); report.Status != "ok" || report.Cached {
+				t.Errorf("report = %+v", report)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Errorf("runs = %d, want 1 for concurrent probes", n)
+	}
+
+	if report := app.CheckReadiness(false); !report.Cached || runs.Load() != 1 {
+		t.Errorf("report = %+v after %d runs, want the cached one", report, runs.Load())
+	}
+
+	// A slow refresh doesn't hold the probes served from cache
+	slow := make(chan struct{})
+	stubReadiness(t, func(*App) ReadinessReport {
+		runs.Add(1)
+		<-slow
+		return ReadinessReport{Status: "degraded", CheckedAt: time.Now()}
+	})
+	readinessCache.Lock()
+	readinessCache.report = &ReadinessReport{Status: "ok"}
+	readinessCache.expires = time.Now().Add(readinessTTL)
+	readinessCache.Unlock()
+	done := make(chan ReadinessReport)
+	go func() { done <- app.CheckReadiness(true) }()
+	time.Sleep(20 * time.Millisecond)
+	probed := make(chan ReadinessReport)
+	go func() { probed <- app.CheckReadiness(false) }()
+	select {
+	case report := <-probed:
+		if !report.Cached || report.Status != "ok" {
+			t.Errorf("probe during refresh = %+v", report)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("probe blocked by a running refresh")
+	}
+	close(slow)
+	if report := <-done; report.Status != "degraded" || report.Cached {
+		t.Errorf("refresh = %+v", report)
+	}
+	if report := app.CheckReadiness(false); report.Status != "degraded" || !report.Cached {
+		t.Errorf("report after refresh = %+v", report)
+	}
+}
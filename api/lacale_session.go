@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// laCaleTimeout bounds every request to La-Cale; the login runs while holding the session lock of the profile
+const laCaleTimeout = 60 * time.Second
+
+// laCaleSession is the logged-in client of a La-Cale profile, shared by its uploaders
+type laCaleSession struct {
+	mu sync.Mutex
+	// account identifies the URL and credentials the session was opened with
+	account string
+	client  *http.Client
+	// generation counts the logins, so that requests seeing the same expired session log in only once
+	generation int
+}
+
+var laCaleSessions = struct {
+	sync.Mutex
+	byProfile map[string]*laCaleSession
+}{byProfile: map[string]*laCaleSession{}}
+
+func laCaleSessionFor(profile string) *laCaleSession {
+	laCaleSessions.Lock()
+	defer laCaleSessions.Unlock()
+	s, ok := laCaleSessions.byProfile[profile]
+	if !ok {
+		s = &laCaleSession{}
+		laCaleSessions.byProfile[profile] = s
+	}
+	return s
+}
+
+// storedLaCaleSession is the encrypted content of a tracker_sessions row
+type storedLaCaleSession struct {
+	Account string         `json:"account"`
+	Cookies []*http.Cookie `json:"cookies"`
+}
+
+// account identifies the session by URL and credentials; changing them discards the stored session
+func (t *laCaleTracker) account() string {
+	sum := sha256.Sum256([]byte(t.apiURL + "\n" + t.email + "\n" + t.password))
+	return hex.EncodeToString(sum[:])
+}
+
+// session returns the client of the profile, restoring the stored session or logging in first if needed
+func (t *laCaleTracker) session(ctx context.Context) (*http.Client, int, error) {
+	s := laCaleSessionFor(t.name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account := t.account()
+	if s.account != account {
+		s.account, s.client = account, nil
+	}
+	if s.client == nil {
+		s.client = t.loadSession(ctx)
+	}
+	if s.client == nil {
+		client, err := t.login(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("La Cale Login failed: %w", err)
+		}
+		s.client = client
+		s.generation++
+	}
+	return s.client, s.generation, nil
+}
+
+// relogin replaces the expired session of this generation, unless a concurrent request already did
+func (t *laCaleTracker) relogin(ctx context.Context, generation int) (*http.Client, int, error) {
+	s := laCaleSessionFor(t.name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil && s.generation != generation && s.account == t.account() {
+		return s.client, s.generation, nil
+	}
+	s.client = nil
+	client, err := t.login(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("La Cale Login failed: %w", err)
+	}
+	s.account, s.client = t.account(), client
+	s.generation++
+	return s.client, s.generation, nil
+}
+
+// do sends the request built by newReq with the session cookie; on 401/403 it logs in again and retries once
+func (t *laCaleTracker) do(ctx context.Context, newReq func() (*http.Request, error)) (*http.Response, error) {
+	client, generation, err := t.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for retried := false; ; retried = true {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if retried || (resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden) {
+			return resp, nil
+		}
+		resp.Body.Close()
+		logInfoCtx(ctx, "La-Cale session of %s expired (status %d), logging in again", t.name, resp.StatusCode)
+		if client, generation, err = t.relogin(ctx, generation); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// login opens a new session with email and password and stores its cookies
+func (t *laCaleTracker) login(ctx context.Context) (*http.Client, error) {
+	if t.email == "" || t.password == "" {
+		return nil, fmt.Errorf("email and password are required")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Jar: jar, Timeout: laCaleTimeout}
+
+	authBody, _ := json.Marshal(map[string]string{
+		"email":    t.email,
+		"password": t.password,
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", t.apiURL+"/auth/login", bytes.NewBuffer(authBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("login request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("login failed with status %d: %s", resp.StatusCode, string(b))
+	}
+
+	var res LoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode login response: %w", err)
+	}
+	if !res.Success {
+		return nil, fmt.Errorf("login was unsuccessful (success: false)")
+	}
+	logInfoCtx(ctx, "Logged in to La-Cale as %s (profile %s)", t.email, t.name)
+
+	if err := t.saveSession(resp.Cookies()); err != nil {
+		logWarnCtx(ctx, "La-Cale session of %s not saved: %v", t.name, err)
+	}
+	return client, nil
+}
+
+// saveSession stores the encrypted session cookies, so that a restart doesn't log in again
+func (t *laCaleTracker) saveSession(cookies []*http.Cookie) error {
+	now := time.Now()
+	for _, c := range cookies {
+		// Max-Age is relative to the login, the stored session needs the date
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+	}
+	data, err := json.Marshal(storedLaCaleSession{Account: t.account(), Cookies: cookies})
+	if err != nil {
+		return err
+	}
+	sealed, err := encryptSecret(data)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO tracker_sessions (profile, data) VALUES (?, ?)
+		ON CONFLICT(profile) DO UPDATE SET data = excluded.data, updated_at = CURRENT_TIMESTAMP`, t.name, sealed)
+	return err
+}
+
+// loadSession returns a client with the stored cookies of the profile, or nil when there is no usable session
+func (t *laCaleTracker) loadSession(ctx context.Context) *http.Client {
+	var sealed []byte
+	err := db.QueryRow("SELECT data FROM tracker_sessions WHERE profile = ?", t.name).Scan(&sealed)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logWarnCtx(ctx, "La-Cale session of %s not loaded: %v", t.name, err)
+		}
+		return nil
+	}
+	data, err := decryptSecret(sealed)
+	if err != nil {
+		logWarnCtx(ctx, "La-Cale session of %s not loaded: %v", t.name, err)
+		return nil
+	}
+	var stored storedLaCaleSession
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Account != t.account() {
+		return nil
+	}
+
+	now := time.Now()
+	cookies := make([]*http.Cookie, 0, len(stored.Cookies))
+	for _, c := range stored.Cookies {
+		if c.Expires.IsZero() || c.Expires.After(now) {
+			cookies = append(cookies, c)
+		}
+	}
+	if len(cookies) == 0 {
+		return nil
+	}
+	u, err := url.Parse(t.apiURL)
+	if err != nil {
+		return nil
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil
+	}
+	jar.SetCookies(u, cookies)
+	logDebugCtx(ctx, "Restored La-Cale session of %s", t.name)
+	return &http.Client{Jar: jar, Timeout: laCaleTimeout}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLaCaleTracker returns a La-Cale tracker of the fake server, forgetting its in-memory session after the test
+func newTestLaCaleTracker(t *testing.T, srv *httptest.Server, password string) *laCaleTracker {
+	t.Helper()
+	tracker := newLaCaleTracker(TrackerProfile{Name: "lacale-session", Type: "lacale", URL: srv.URL,
+		Username: "user@test", Password: password})
+	t.Cleanup(func() { forgetLaCaleSession(tracker.name) })
+	return tracker
+}
+
+// forgetLaCaleSession drops the in-memory session of a profile, as a restart would
+func forgetLaCaleSession(profile string) {
+	laCaleSessions.Lock()
+	delete(laCaleSessions.byProfile, profile)
+	laCaleSessions.Unlock()
+}
+
+// storedTestSession decrypts the stored session of a profile
+func storedTestSession(t *testing.T, profile string) storedLaCaleSession {
+	t.Helper()
+	var sealed []byte
+	if err := db.QueryRow("SELECT data FROM tracker_sessions WHERE profile = ?", profile).Scan(&sealed); err != nil {
+		t.Fatal(err)
+	}
+	data, err := decryptSecret(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored storedLaCaleSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestLaCaleSessionRestored(t *testing.T) {
+	initTestDB(t)
+	useTestSecretKey(t, "passphrase")
+	fake, srv := newFakeLaCale(t, false)
+	tracker := newTestLaCaleTracker(t, srv, "secret")
+	ctx := context.Background()
+
+	if err := tracker.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored := storedTestSession(t, tracker.name)
+	if stored.Account != tracker.account() || len(stored.Cookies) != 1 {
+		t.Fatalf("stored session = %+v", stored)
+	}
+	// Max-Age is stored as a date, relative to the login
+	if c := stored.Cookies[0]; c.MaxAge != 0 || c.Expires.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("stored cookie = %+v", c)
+	}
+
+	// After a restart the stored session is used without logging in
+	forgetLaCaleSession(tracker.name)
+	if _, err := tracker.Search(ctx, "Movie"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.count("/api/internal/auth/login") != 1 || fake.count("/api/internal/torrents/search") != 1 {
+		t.Errorf("calls = %v", fake.calls)
+	}
+}
+
+func TestLaCaleSessionNotRestored(t *testing.T) {
+	tests := []struct {
+		name string
+		// change alters the stored session or the tracker after the login
+		change func(t *testing.T, tracker *laCaleTracker) *laCaleTracker
+	}{
+		{"other credentials", func(t *testing.T, tracker *laCaleTracker) *laCaleTracker {
+			other := *tracker
+			other.password = "changed"
+			return &other
+		}},
+		{"other URL", func(t *testing.T, tracker *laCaleTracker) *laCaleTracker {
+			other := *tracker
+			other.apiURL = "https://other.test/api/internal"
+			return &other
+		}},
+		{"rotated key", func(t *testing.T, tracker *laCaleTracker) *laCaleTracker {
+			useTestSecretKey(t, "rotated")
+			return tracker
+		}},
+		{"expired cookies", func(t *testing.T, tracker *laCaleTracker) *laCaleTracker {
+			expired := &http.Cookie{Name: "session", Value: "s1", Path: "/", Expires: time.Now().Add(-time.Minute)}
+			if err := tracker.saveSession([]*http.Cookie{expired}); err != nil {
+				t.Fatal(err)
+			}
+			return tracker
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestDB(t)
+			useTestSecretKey(t, "passphrase")
+			_, srv := newFakeLaCale(t, false)
+			tracker := newTestLaCaleTracker(t, srv, "secret")
+			if err := tracker.Login(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if tracker.loadSession(context.Background()) == nil {
+				t.Fatal("stored session not restored")
+			}
+			if client := tt.change(t, tracker).loadSession(context.Background()); client != nil {
+				t.Error("stored session restored")
+			}
+		})
+	}
+}
+
+func TestLaCaleSessionRotatedKeyLogsIn(t *testing.T) {
+	initTestDB(t)
+	useTestSecretKey(t, "passphrase")
+	fake, srv := newFakeLaCale(t, false)
+	tracker := newTestLaCaleTracker(t, srv, "secret")
+	ctx := context.Background()
+	if err := tracker.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session sealed with the old key is replaced by a new login
+	useTestSecretKey(t, "rotated")
+	forgetLaCaleSession(tracker.name)
+	if _, err := tracker.Search(ctx, "Movie"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count("/api/internal/auth/login"); n != 2 {
+		t.Errorf("logins = %d, want 2", n)
+	}
+	if tracker.loadSession(ctx) == nil {
+		t.Error("the new session was not stored with the new key")
+	}
+}
+
+func TestLaCaleSessionExpired(t *testing.T) {
+	initTestDB(t)
+	useTestSecretKey(t, "passphrase")
+	fake, srv := newFakeLaCale(t, false)
+	tracker := newTestLaCaleTracker(t, srv, "secret")
+	ctx := context.Background()
+	if _, err := tracker.Search(ctx, "Movie"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server expires the session: the 403 logs in again and the request is retried once
+	fake.mu.Lock()
+	fake.session = "expired"
+	fake.mu.Unlock()
+	if _, err := tracker.Search(ctx, "Movie"); err != nil {
+		t.Fatalf("Search after the expiry: %v", err)
+	}
+	if n := fake.count("/api/internal/auth/login"); n != 2 {
+		t.Errorf("logins = %d, want 2", n)
+	}
+	if fake.count("403 /api/internal/torrents/search") != 1 || fake.count("/api/internal/torrents/search") != 2 {
+		t.Errorf("calls = %v", fake.calls)
+	}
+	if stored := storedTestSession(t, tracker.name); stored.Cookies[0].Value != "s2" {
+		t.Errorf("stored cookie = %s, want the new session", stored.Cookies[0].Value)
+	}
+
+	// A login that fails ends the request, without a second retry
+	tracker.password = "changed"
+	fake.mu.Lock()
+	fake.session = "expired"
+	fake.mu.Unlock()
+	if _, err := tracker.Search(ctx, "Movie"); err == nil {
+		t.Error("Search succeeded with a failed login")
+	}
+}
+
+func TestLaCaleSessionReloginOnce(t *testing.T) {
+	initTestDB(t)
+	useTestSecretKey(t, "passphrase")
+	fake, srv := newFakeLaCale(t, false)
+	tracker := newTestLaCaleTracker(t, srv, "secret")
+	ctx := context.Background()
+
+	_, generation, err := tracker.session(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, renewed, err := tracker.relogin(ctx, generation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed != generation+1 || fake.count("/api/internal/auth/login") != 2 {
+		t.Fatalf("generation = %d after relogin of %d", renewed, generation)
+	}
+	// A request that saw the same expired session reuses the new one
+	again, current, err := tracker.relogin(ctx, generation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != client || current != renewed || fake.count("/api/internal/auth/login") != 2 {
+		t.Errorf("second relogin of generation %d logged in again (generation %d)", generation, current)
+	}
+
+	// Concurrent requests hitting the expired session log in once
+	fake.mu.Lock()
+	fake.session = "expired"
+	fake.mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tracker.Search(ctx, "Movie"); err != nil {
+				t.Errorf("Search: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fake.count("/api/internal/auth/login"); n != 3 {
+		t.Errorf("logins = %d, want a single one for the concurrent requests", n)
+	}
+}
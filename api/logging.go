@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// logRingSize is the number of recent log entries kept for GET /api/logs
+const logRingSize = 500
+
+// logLevel is shared by every handler so the level can change at runtime
+var logLevel = new(slog.LevelVar)
+
+// recentLogs keeps the last log entries in memory
+var recentLogs = &logRing{entries: make([]LogEntry, logRingSize)}
+
+// logSecrets holds the secret values (passwords, passkey...) to scrub from log messages
+var logSecrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// Patterns of credentials that may appear in URLs or form dumps
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:passkey|password|api_key|apikey|api_token|token)=)[^&\s"]+`),
+	regexp.MustCompile(`(?i)(/)[0-9a-f]{32}(/announce)`),
+}
+
+// LogEntry is a log record as returned by GET /api/logs
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// logRing is a fixed-size circular buffer of log entries
+type logRing struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func (r *logRing) add(e LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns up to limit entries at or above minLevel, oldest first
+func (r *logRing) list(minLevel slog.Level, limit int) []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ordered := r.entries[:r.next]
+	if r.full {
+		ordered = append(append([]LogEntry{}, r.entries[r.next:]...), r.entries[:r.next]...)
+	}
+	result := []LogEntry{}
+	for _, e := range ordered {
+		var lvl slog.Level
+		if lvl.UnmarshalText([]byte(e.Level)) == nil && lvl >= minLevel {
+			result = append(result, e)
+		}
+	}
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// GetRecentLogs returns the most recent log entries at or above the given level
+func (a *App) GetRecentLogs(level string, limit int) ([]LogEntry, error) {
+	minLevel := slog.LevelDebug
+	if level != "" {
+		if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid level %q", level)
+		}
+	}
+	return recentLogs.list(minLevel, limit), nil
+}
+
+// redactSecrets scrubs known secret values and credential patterns from s
+func redactSecrets(s string) string {
+	logSecrets.RLock()
+	for _, v := range logSecrets.values {
+		s = redactToken(s, v)
+	}
+	logSecrets.RUnlock()
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, "${1}[REDACTED]${2}")
+	}
+	return s
+}
+
+// redactToken replaces the whole-word occurrences of secret in s
+// A secret that is part of a longer word (a password "torrent" in "rtorrent") is left alone.
+func redactToken(s, secret string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, secret)
+		if i < 0 {
+			break
+		}
+		end := i + len(secret)
+		// Only a word character of the secret can run into the text around it
+		if (isWordByteAt(secret, 0) && isWordByteAt(s, i-1)) || (isWordByteAt(secret, len(secret)-1) && isWordByteAt(s, end)) {
+			b.WriteString(s[:i+1])
+			s = s[i+1:]
+			continue
+		}
+		b.WriteString(s[:i])
+		b.WriteString("[REDACTED]")
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// isWordByteAt reports whether s has a letter, digit or underscore at i
+func isWordByteAt(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// redactAttr hides attributes named like credentials and scrubs string values
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if isSecretField(a.Key) {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	if a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, redactSecrets(a.Value.String()))
+	}
+	return a
+}
+
+// appLogHandler adds the request ID, redacts secrets and feeds the ring buffer
+type appLogHandler struct {
+	base  slog.Handler
+	attrs []slog.Attr
+}
+
+func (h *appLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.base.Enabled(ctx, level)
+}
+
+func (h *appLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	rec := slog.NewRecord(r.Time, r.Level, redactSecrets(r.Message), r.PC)
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		rec.AddAttrs(slog.String("request_id", reqID))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.AddAttrs(a)
+		return true
+	})
+
+	entry := LogEntry{Time: rec.Time, Level: rec.Level.String(), Message: rec.Message}
+	collect := func(a slog.Attr) bool {
+		if entry.Attrs == nil {
+			entry.Attrs = map[string]string{}
+		}
+		entry.Attrs[a.Key] = redactAttr(nil, a).Value.String()
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	rec.Attrs(collect)
+	recentLogs.add(entry)
+
+	return h.base.Handle(ctx, rec)
+}
+
+func (h *appLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &appLogHandler{
+		base:  h.base.WithAttrs(attrs),
+		attrs: append(append([]slog.Attr{}, h.attrs...), attrs...),
+	}
+}
+
+func (h *appLogHandler) WithGroup(name string) slog.Handler {
+	return &appLogHandler{base: h.base.WithGroup(name), attrs: h.attrs}
+}
+
+// configureLogging applies the level/format settings and refreshes the secrets to redact
+func configureLogging(settings AppSettings) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(settings.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	logLevel.Set(level)
+
+	opts := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactAttr}
+	var base slog.Handler
+	if strings.EqualFold(settings.LogFormat, "json") {
+		base = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		base = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(&appLogHandler{base: base}))
+
+	secrets := []string{}
+	values := []string{settings.Passkey, settings.LaCalePassword, settings.QbitPassword,
+		settings.TransmissionPassword, settings.DelugePassword, settings.RtorrentPassword, settings.Aria2Secret}
+	for _, ci := range settings.ClientInstances {
+		values = append(values, ci.Password)
+	}
+	for _, p := range settings.TrackerProfiles {
+		values = append(values, p.Password, p.APIKey, p.Passkey)
+	}
+	// The built-in passwords ("deluge", "adminadmin") are no secret and would redact client names
+	defaults := getDefaultSettings()
+	for _, v := range values {
+		// Very short values would redact unrelated text
+		if len(v) >= 4 && v != defaults.QbitPassword && v != defaults.DelugePassword {
+			secrets = append(secrets, v)
+		}
+	}
+	logSecrets.Lock()
+	logSecrets.values = secrets
+	logSecrets.Unlock()
+}
+
+// requestLogger logs every HTTP request with its chi request ID, status and duration
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		// Static assets and health probes would drown the useful lines
+		level := slog.LevelInfo
+		if strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/health") {
+			level = slog.LevelDebug
+		}
+		slog.LogAttrs(r.Context(), level, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", ww.Status()),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// ============ LOGGING HELPERS ============
+
+func logf(ctx context.Context, level slog.Level, format string, args ...interface{}) {
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+// logDebug logs a debug-level message
+func logDebug(format string, args ...interface{}) {
+	logf(context.Background(), slog.LevelDebug, format, args...)
+}
+
+// logInfo logs an info-level message
+func logInfo(format string, args ...interface{}) {
+	logf(context.Background(), slog.LevelInfo, format, args...)
+}
+
+// logWarn logs a warning-level message
+func logWarn(format string, args ...interface{}) {
+	logf(context.Background(), slog.LevelWarn, format, args...)
+}
+
+// logError logs an error-level message
+func logError(format string, args ...interface{}) {
+	logf(context.Background(), slog.LevelError, format, args...)
+}
+
+// logDebugCtx logs a debug-level message tagged with the request ID from ctx
+func logDebugCtx(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, slog.LevelDebug, format, args...)
+}
+
+// logInfoCtx logs an info-level message tagged with the request ID from ctx
+func logInfoCtx(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, slog.LevelInfo, format, args...)
+}
+
+// logWarnCtx logs a warning-level message tagged with the request ID from ctx
+func logWarnCtx(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, slog.LevelWarn, format, args...)
+}
+
+// logErrorCtx logs an error-level message tagged with the request ID from ctx
+func logErrorCtx(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, slog.LevelError, format, args...)
+}
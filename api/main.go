@@ -3,17 +3,20 @@ package main
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //go:embed static/*
@@ -23,13 +26,38 @@ func main() {
 	// Initialize database
 	InitDB()
 
+	// Environment / config file overrides of the stored settings
+	if err := LoadSettingsOverrides(); err != nil {
+		log.Fatalf("Invalid settings override: %v", err)
+	}
+
 	// Create app instance
 	app := NewApp()
+	configureLogging(app.GetSettings())
+
+	// "aatm sync-tags [--dry-run]" syncs the La-Cale tag catalog and exits
+	if len(os.Args) > 1 && os.Args[1] == "sync-tags" {
+		os.Exit(runTagSync(app, len(os.Args) > 2 && os.Args[2] == "--dry-run"))
+	}
+	app.StartBackupScheduler()
+	app.StartSeedingMonitor()
+	if _, err := reloadTagCatalog(); err != nil {
+		logError("Tag catalog: %v", err)
+	}
+	app.StartTagCatalogWatcher()
+	if _, err := reloadTagRules(); err != nil {
+		logError("Tag rules: %v, using the embedded rules", err)
+	}
+	if err := app.ConfigureSeeder(app.GetSettings()); err != nil {
+		logError("Seeder: %v", err)
+	}
 
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger)
+	r.Use(metricsMiddleware)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"http://localhost:*", "http://127.0.0.1:*"},
@@ -62,15 +90,24 @@ func main() {
 		http.StripPrefix("/static/", fileServer).ServeHTTP(w, r)
 	})
 
+	r.Handle("/metrics", promhttp.Handler())
+
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 	})
 
+	// Readiness: per-dependency checks, cached for a few seconds (?refresh=true to bypass)
+	r.Get("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		report := app.CheckReadiness(r.URL.Query().Get("refresh") == "true")
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == "fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+
 	// TMDB Proxy - keeps API key secure on backend
-	tmdbAPIKey := os.Getenv("TMDB_API_KEY")
-	if tmdbAPIKey == "" {
-		tmdbAPIKey = "49d8d37e45764e7c6794ed7dd2d896d4" // Fallback for development
-	}
+	tmdbAPIKey := getTMDBAPIKey()
 
 	r.Get("/api/tmdb/search/{type}", func(w http.ResponseWriter, r *http.Request) {
 		mediaType := chi.URLParam(r, "type")
@@ -211,7 +248,7 @@ func main() {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		torrentPath, err := app.CreateTorrent(req.SourcePath, req.Trackers, req.Comment, req.IsPrivate, req.TorrentName)
+		torrentPath, err := app.CreateTorrent(r.Context(), req.SourcePath, req.Trackers, req.Comment, req.IsPrivate, req.TorrentName)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -220,6 +257,47 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{"torrentPath": torrentPath})
 	})
 
+	// Duplicate detection (release history + tracker search)
+	r.Post("/api/duplicates/check", func(w http.ResponseWriter, r *http.Request) {
+		var req DuplicateCheckRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.SourcePath == "" {
+			http.Error(w, "sourcePath required", http.StatusBadRequest)
+			return
+		}
+		report, err := app.CheckDuplicates(r.Context(), req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+
+	r.Get("/api/releases", func(w http.ResponseWriter, r *http.Request) {
+		releases, err := app.GetReleases()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(releases)
+	})
+
+	// Releases added to the client that didn't reach seeding in time, errored or disappeared
+	r.Get("/api/releases/seeding-issues", func(w http.ResponseWriter, r *http.Request) {
+		releases, err := app.GetSeedingIssues()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(releases)
+	})
+
 	// NFO operations
 	r.Post("/api/nfo/save", func(w http.ResponseWriter, r *http.Request) {
 		var req SaveNfoRequest
@@ -276,7 +354,7 @@ func main() {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err := app.UploadToQBittorrent(req.TorrentPath, req.QbitUrl, req.Username, req.Password)
+		err := app.UploadToQBittorrent(r.Context(), req.TorrentPath, req.QbitUrl, req.Username, req.Password)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -291,7 +369,7 @@ func main() {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err := app.RemoveFromQBittorrent(req.TorrentPath, req.QbitUrl, req.Username, req.Password)
+		err := app.RemoveFromQBittorrent(r.Context(), req.TorrentPath, req.QbitUrl, req.Username, req.Password)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -304,19 +382,36 @@ func main() {
 	r.Post("/api/torrent-client/upload", func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			TorrentPath string `json:"torrentPath"`
+			// File or directory the torrent was made from (source or hardlink), used as save path
+			ContentPath string `json:"contentPath"`
+			// Tracker, media type and source path select the client instances (see clientRoutes)
+			ClientRouteInput
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if req.SourcePath == "" {
+			req.SourcePath = req.ContentPath
+		}
 		settings := app.GetSettings()
-		err := app.UploadToTorrentClient(req.TorrentPath, settings)
+		results, err := app.UploadToTorrentClient(r.Context(), req.TorrentPath, req.ContentPath, req.ClientRouteInput, settings)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		clients := make([]string, 0, len(results))
+		for _, res := range results {
+			if res.Error == "" {
+				clients = append(clients, res.Client)
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "uploaded", "client": settings.TorrentClient})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "uploaded",
+			"client":  strings.Join(clients, ", "),
+			"results": results,
+		})
 	})
 
 	r.Post("/api/torrent-client/remove", func(w http.ResponseWriter, r *http.Request) {
@@ -328,7 +423,7 @@ func main() {
 			return
 		}
 		settings := app.GetSettings()
-		err := app.RemoveFromTorrentClient(req.TorrentPath, settings)
+		err := app.RemoveFromTorrentClient(r.Context(), req.TorrentPath, settings)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -337,6 +432,134 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{"status": "removed", "client": settings.TorrentClient})
 	})
 
+	r.Get("/api/torrent-client", func(w http.ResponseWriter, r *http.Request) {
+		info, err := app.GetTorrentClientInfo()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+
+	// torrentClientOrError returns the instance named by ?client= (default instance otherwise)
+	// and writes an error when it isn't configured
+	torrentClientOrError := func(w http.ResponseWriter, r *http.Request) TorrentClient {
+		client, err := app.GetClientInstance(app.GetSettings(), r.URL.Query().Get("client"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		if client == nil {
+			http.Error(w, "no torrent client configured", http.StatusBadRequest)
+			return nil
+		}
+		return client
+	}
+
+	// torrentClientError maps a client error to an HTTP status
+	torrentClientError := func(w http.ResponseWriter, err error) {
+		switch {
+		case errors.Is(err, ErrTorrentNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrNotSupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	}
+
+	r.Get("/api/torrent-client/torrents", func(w http.ResponseWriter, r *http.Request) {
+		client := torrentClientOrError(w, r)
+		if client == nil {
+			return
+		}
+		list, err := client.List(r.Context())
+		if err != nil {
+			torrentClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	})
+
+	r.Get("/api/torrent-client/torrents/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		client := torrentClientOrError(w, r)
+		if client == nil {
+			return
+		}
+		status, err := client.Status(r.Context(), strings.ToLower(chi.URLParam(r, "hash")))
+		if err != nil {
+			torrentClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+
+	r.Post("/api/torrent-client/torrents/{hash}/recheck", func(w http.ResponseWriter, r *http.Request) {
+		client := torrentClientOrError(w, r)
+		if client == nil {
+			return
+		}
+		if err := client.Recheck(r.Context(), strings.ToLower(chi.URLParam(r, "hash"))); err != nil {
+			torrentClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "rechecking"})
+	})
+
+	r.Post("/api/torrent-client/torrents/{hash}/category", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Category string `json:"category"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		client := torrentClientOrError(w, r)
+		if client == nil {
+			return
+		}
+		if err := client.SetCategory(r.Context(), strings.ToLower(chi.URLParam(r, "hash")), req.Category); err != nil {
+			torrentClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "updated", "category": req.Category})
+	})
+
+	// Built-in seeder
+	r.Get("/api/seeder", func(w http.ResponseWriter, r *http.Request) {
+		info, err := app.GetSeederInfo()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+
+	// seederAction runs a start/stop/remove action on a torrent of the built-in seeder
+	seederAction := func(action func(string) error, status string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := action(strings.ToLower(chi.URLParam(r, "hash"))); err != nil {
+				if errors.Is(err, ErrTorrentNotFound) {
+					http.Error(w, err.Error(), http.StatusNotFound)
+				} else {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"status": status})
+		}
+	}
+	r.Post("/api/seeder/torrents/{hash}/start", seederAction(app.StartSeederTorrent, "started"))
+	r.Post("/api/seeder/torrents/{hash}/stop", seederAction(app.StopSeederTorrent, "stopped"))
+	r.Delete("/api/seeder/torrents/{hash}", seederAction(app.RemoveSeederTorrent, "removed"))
+
 	// Hardlink creation
 	r.Post("/api/hardlink/create", func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
@@ -357,7 +580,7 @@ func main() {
 		}
 
 		// Create the hardlink
-		hardlinkPath, err := app.CreateHardlink(req.SourcePath, destDir, req.TorrentName)
+		hardlinkPath, err := app.CreateHardlink(r.Context(), req.SourcePath, destDir, req.TorrentName)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -391,6 +614,52 @@ func main() {
 		json.NewEncoder(w).Encode(map[string][]string{"tags": tags})
 	})
 
+	// Tags selected by the tag rules for a release, with the reason of each selection
+	r.Post("/api/lacale/explain-tags", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			MediaType   string      `json:"mediaType"`
+			ReleaseInfo ReleaseInfo `json:"releaseInfo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		explanation, err := app.ExplainLaCaleTags(req.MediaType, req.ReleaseInfo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(explanation)
+	})
+
+	// Check tag ids against the category and its required and single-choice characteristics, before an upload
+	r.Post("/api/lacale/validate-tags", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			MediaType  string   `json:"mediaType"`
+			CategoryID string   `json:"categoryId"`
+			Tags       []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		errs, err := validateLaCaleTags(req.MediaType, req.CategoryID, req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"valid":  len(errs) == 0,
+			"errors": errs,
+		})
+	})
+
 	// Get all available tags for a media type, organized by category
 	r.Post("/api/lacale/all-tags", func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
@@ -415,38 +684,166 @@ func main() {
 		})
 	})
 
+	// Tag catalog synced from La-Cale (the embedded copy is used until the first sync)
+	r.Post("/api/lacale/tags/sync", func(w http.ResponseWriter, r *http.Request) {
+		report, err := app.SyncLaCaleTags(r.Context(), r.URL.Query().Get("dryRun") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+
+	r.Get("/api/lacale/tags/versions", func(w http.ResponseWriter, r *http.Request) {
+		versions, err := app.GetTagCatalogVersions()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		current := "embedded"
+		if catalog, err := currentTagCatalog(); err == nil {
+			current = catalog.Source
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"current":  current,
+			"versions": versions,
+		})
+	})
+
+	r.Post("/api/lacale/tags/reload", func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := reloadTagCatalog()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rulesError := ""
+		if _, err := reloadTagRules(); err != nil {
+			rulesError = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"source":     catalog.Source,
+			"tagCount":   catalog.TagCount,
+			"rulesError": rulesError,
+		})
+	})
+
+	// Lookup in the tag catalog by id, name, characteristic slug or media type
+	r.Get("/api/lacale/tags/lookup", func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := currentTagCatalog()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lookup, err := catalog.Lookup(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lookup)
+	})
+
 	r.Post("/api/lacale/upload", func(w http.ResponseWriter, r *http.Request) {
 		var req LaCaleUploadRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err := app.UploadToLaCale(
-			req.TorrentPath,
-			req.NfoPath,
-			req.Title,
-			req.Description,
-			req.TmdbId,
-			req.MediaType,
-			req.ReleaseInfo,
-			req.Passkey,
-			req.Email,
-			req.Password,
-			req.CustomTags,
-		)
+		// Credentials sent by the form take precedence over the saved ones
+		profile, _ := findTrackerProfile(app.GetSettings(), "lacale")
+		if req.Email != "" && req.Password != "" {
+			profile.Username, profile.Password = req.Email, req.Password
+		}
+		if req.Passkey != "" {
+			profile.Passkey = req.Passkey
+		}
+		tracker, err := newTrackerUploader(profile)
+		var result TrackerUploadResult
+		if err == nil {
+			result, err = app.UploadToTracker(r.Context(), tracker, req.TrackerRelease)
+		}
+		if writeTagValidationErrors(w, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "uploaded"})
+		json.NewEncoder(w).Encode(struct {
+			Status  string `json:"status"`
+			Tracker string `json:"tracker"`
+			TrackerUploadResult
+		}{"uploaded", tracker.Name(), result})
+	})
+
+	// Tracker profiles (La-Cale and the ones of settings.trackerProfiles)
+	r.Get("/api/trackers", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"types":    registeredTrackers(),
+			"profiles": app.GetTrackerProfiles(),
+		})
+	})
+
+	r.Post("/api/trackers/{name}/upload", func(w http.ResponseWriter, r *http.Request) {
+		var req TrackerRelease
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tracker, err := app.GetTrackerUploader(app.GetSettings(), chi.URLParam(r, "name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := app.UploadToTracker(r.Context(), tracker, req)
+		if writeTagValidationErrors(w, err) {
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Status  string `json:"status"`
+			Tracker string `json:"tracker"`
+			TrackerUploadResult
+		}{"uploaded", tracker.Name(), result})
+	})
+
+	r.Get("/api/trackers/{name}/search", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			http.Error(w, "q is required", http.StatusBadRequest)
+			return
+		}
+		tracker, err := app.GetTrackerUploader(app.GetSettings(), chi.URLParam(r, "name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		matches, err := tracker.Search(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(matches)
 	})
 
 	// Settings
 	r.Get("/api/settings", func(w http.ResponseWriter, r *http.Request) {
-		settings := app.GetSettings()
+		settings, sources := app.GetSettingsWithSources()
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(settings)
+		json.NewEncoder(w).Encode(struct {
+			AppSettings
+			Sources map[string]string `json:"sources"`
+		}{settings, sources})
 	})
 
 	r.Post("/api/settings", func(w http.ResponseWriter, r *http.Request) {
@@ -455,14 +852,110 @@ func main() {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		errs, err := validateSettingsToSave(settings)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(errs) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+			return
+		}
 		if err := app.SaveSettings(settings); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		configureLogging(app.GetSettings())
+		if err := app.ConfigureSeeder(app.GetSettings()); err != nil {
+			logErrorCtx(r.Context(), "Seeder: %v", err)
+			http.Error(w, fmt.Sprintf("settings saved but the seeder failed to start: %v", err), http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "saved"})
 	})
 
+	// Connectivity test; the body may carry unsaved settings from the form, used as they would be saved
+	r.Post("/api/settings/test/{target}", func(w http.ResponseWriter, r *http.Request) {
+		settings := app.GetSettings()
+		var form AppSettings
+		if err := json.NewDecoder(r.Body).Decode(&form); err == nil {
+			if settings, err = formSettings(form); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else if err != io.EOF {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result := app.TestConnection(chi.URLParam(r, "target"), settings)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	// Recent logs (in-memory ring buffer, secrets redacted)
+	r.Get("/api/logs", func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		entries, err := app.GetRecentLogs(r.URL.Query().Get("level"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	})
+
+	// Backup / restore
+	r.Get("/api/backup", func(w http.ResponseWriter, r *http.Request) {
+		archive, err := app.ExportBackup()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		filename := "aatm-backup-" + archive.CreatedAt.Format("20060102-150405") + ".json"
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		json.NewEncoder(w).Encode(archive)
+	})
+
+	r.Post("/api/restore", func(w http.ResponseWriter, r *http.Request) {
+		var archive BackupArchive
+		if err := json.NewDecoder(r.Body).Decode(&archive); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dryRun := r.URL.Query().Get("dryRun") == "true"
+		report, err := app.RestoreBackup(archive, dryRun)
+		if err != nil && report != nil {
+			// Invalid settings: the report lists them
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+
+	r.Post("/api/backup/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		path, err := app.SnapshotDatabase()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := pruneSnapshots(app.GetSettings().BackupRetention); err != nil {
+			logWarn("snapshot: pruning failed: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "created", "path": path})
+	})
+
 	// Processed files
 	r.Post("/api/processed/mark", func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
@@ -551,15 +1044,8 @@ type QBittorrentRequest struct {
 }
 
 type LaCaleUploadRequest struct {
-	TorrentPath string      `json:"torrentPath"`
-	NfoPath     string      `json:"nfoPath"`
-	Title       string      `json:"title"`
-	Description string      `json:"description"`
-	TmdbId      string      `json:"tmdbId"`
-	MediaType   string      `json:"mediaType"`
-	ReleaseInfo ReleaseInfo `json:"releaseInfo"`
-	Passkey     string      `json:"passkey"`
-	Email       string      `json:"email"`
-	Password    string      `json:"password"`
-	CustomTags  []string    `json:"customTags,omitempty"` // Optional: override auto-detected tags
+	TrackerRelease
+	Passkey  string `json:"passkey"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
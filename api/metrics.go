@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aatm_http_requests_total",
+		Help: "HTTP requests by chi route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aatm_http_request_duration_seconds",
+		Help:    "HTTP request latency by chi route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	torrentCreateDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aatm_torrent_create_duration_seconds",
+		Help:    "Time spent hashing and writing a .torrent file.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	})
+
+	torrentBytesHashed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "aatm_torrent_bytes_hashed_total",
+		Help: "Bytes of content hashed while creating torrents.",
+	})
+
+	trackerUploadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aatm_tracker_uploads_total",
+		Help: "Release uploads to trackers by tracker and result (success/failure).",
+	}, []string{"tracker", "result"})
+
+	clientUploadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aatm_torrent_client_uploads_total",
+		Help: "Torrents added to torrent clients by client and result (success/failure).",
+	}, []string{"client", "result"})
+
+	mediainfoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aatm_mediainfo_duration_seconds",
+		Help:    "Duration of mediainfo invocations by output format.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"format"})
+
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aatm_queue_depth",
+		Help: "Long-running operations currently in progress by operation.",
+	}, []string{"operation"})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "aatm_db_size_bytes",
+		Help: "Size of the aatm.db SQLite database file.",
+	}, func() float64 {
+		fi, err := os.Stat(filepath.Join(dataDir, "aatm.db"))
+		if err != nil {
+			return 0
+		}
+		return float64(fi.Size())
+	})
+)
+
+// resultLabel maps an error to the "result" label value
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// trackInProgress increments the queue depth of an operation and returns the matching decrement
+func trackInProgress(operation string) func() {
+	g := queueDepth.WithLabelValues(operation)
+	g.Inc()
+	return g.Dec
+}
+
+// metricsMiddleware records request count and latency per chi route pattern
+// The pattern is only known once routing is done, so it is read after the handler ran.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
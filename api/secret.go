@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// secretKeyFile holds the key encrypting the secrets stored in the database (tracker sessions)
+const secretKeyFile = "secret.key"
+
+var secretKey struct {
+	once sync.Once
+	key  []byte
+	err  error
+}
+
+// loadSecretKey returns the AES-256 key derived from AATM_SECRET_KEY, or read from the key file
+// of the data directory, which is created on first use
+func loadSecretKey() ([]byte, error) {
+	secretKey.once.Do(func() {
+		if v := os.Getenv("AATM_SECRET_KEY"); v != "" {
+			sum := sha256.Sum256([]byte(v))
+			secretKey.key = sum[:]
+			return
+		}
+		path := filepath.Join(dataDir, secretKeyFile)
+		key, err := os.ReadFile(path)
+		if err == nil {
+			if len(key) != 32 {
+				secretKey.err = fmt.Errorf("invalid secret key in %s (%d bytes, expected 32)", path, len(key))
+				return
+			}
+			secretKey.key = key
+			return
+		}
+		if !os.IsNotExist(err) {
+			secretKey.err = fmt.Errorf("failed to read secret key: %w", err)
+			return
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			secretKey.err = fmt.Errorf("failed to generate secret key: %w", err)
+			return
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			secretKey.err = fmt.Errorf("failed to save secret key: %w", err)
+			return
+		}
+		logInfo("Generated secret key %s", path)
+		secretKey.key = key
+	})
+	return secretKey.key, secretKey.err
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	key, err := loadSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret seals data with AES-GCM; the random nonce is prepended to the result
+func encryptSecret(data []byte) ([]byte, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptSecret opens data sealed by encryptSecret
+func decryptSecret(data []byte) ([]byte, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (secret key changed?): %w", err)
+	}
+	return plain, nil
+}
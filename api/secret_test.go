@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useTestSecretKey sets AATM_SECRET_KEY ("" reads the key file) and reloads the key, again after the test
+func useTestSecretKey(t *testing.T, value string) {
+	t.Helper()
+	reset := func() {
+		secretKey.once = sync.Once{}
+		secretKey.key, secretKey.err = nil, nil
+	}
+	t.Setenv("AATM_SECRET_KEY", value)
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	useTestSecretKey(t, "passphrase")
+	plain := []byte(`{"cookies": "s1"}`)
+	first, err := encryptSecret(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := encryptSecret(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The random nonce makes every encryption different
+	if bytes.Equal(first, second) || bytes.Contains(first, []byte("s1")) {
+		t.Errorf("sealed data = %x", first)
+	}
+	for _, sealed := range [][]byte{first, second} {
+		if got, err := decryptSecret(sealed); err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decryptSecret = %q, %v", got, err)
+		}
+	}
+
+	tampered := append([]byte{}, first...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := decryptSecret(tampered); err == nil {
+		t.Error("tampered data decrypted")
+	}
+	if _, err := decryptSecret([]byte("short")); err == nil {
+		t.Error("data shorter than the nonce decrypted")
+	}
+
+	// Data sealed with another key can't be opened
+	useTestSecretKey(t, "rotated")
+	if _, err := decryptSecret(first); err == nil || !strings.Contains(err.Error(), "secret key changed") {
+		t.Errorf("decrypt with a rotated key: err = %v", err)
+	}
+}
+
+func TestSecretKeyFile(t *testing.T) {
+	initTestDB(t)
+	useTestSecretKey(t, "")
+	sealed, err := encryptSecret([]byte("session"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dataDir, secretKeyFile)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("key file not created: %v", err)
+	}
+	if fi.Size() != 32 || fi.Mode().Perm() != 0600 {
+		t.Errorf("key file: %d bytes, mode %v", fi.Size(), fi.Mode().Perm())
+	}
+
+	// The key file is read back after a restart
+	useTestSecretKey(t, "")
+	if got, err := decryptSecret(sealed); err != nil || string(got) != "session" {
+		t.Errorf("decrypt after reload = %q, %v", got, err)
+	}
+
+	// AATM_SECRET_KEY takes precedence over the file
+	useTestSecretKey(t, "passphrase")
+	if _, err := decryptSecret(sealed); err == nil {
+		t.Error("the key file was used although AATM_SECRET_KEY is set")
+	}
+
+	if err := os.WriteFile(path, []byte("too short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	useTestSecretKey(t, "")
+	if _, err := encryptSecret([]byte("session")); err == nil || !strings.Contains(err.Error(), "invalid secret key") {
+		t.Errorf("encrypt with a truncated key file: err = %v", err)
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"golang.org/x/time/rate"
+)
+
+// seederSaveInterval is the delay between two saves of the upload counters
+const seederSaveInterval = 5 * time.Minute
+
+// configureSeederClient is applied last to the client config (tests turn off DHT and trackers)
+var configureSeederClient = func(cfg *torrent.ClientConfig) {}
+
+// seederTorrent is a row of the seeder_torrents table
+type seederTorrent struct {
+	InfoHash    string `json:"infoHash"`
+	Name        string `json:"name"`
+	TorrentPath string `json:"torrentPath"`
+	// SaveDir contains the content (the parent of the source or hardlink)
+	SaveDir  string `json:"saveDir"`
+	Size     int64  `json:"size"`
+	Uploaded int64  `json:"uploaded"`
+	Paused   bool   `json:"paused"`
+	AddedAt  string `json:"addedAt"`
+}
+
+// SeederTorrent is the state of a torrent of the built-in seeder
+type SeederTorrent struct {
+	TorrentStatus
+	TorrentPath string `json:"torrentPath"`
+	Paused      bool   `json:"paused"`
+	Peers       int    `json:"peers"`
+	Seeders     int    `json:"seeders"`
+	AddedAt     string `json:"addedAt"`
+}
+
+// SeederInfo is the answer of GET /api/seeder
+type SeederInfo struct {
+	Enabled        bool            `json:"enabled"`
+	Running        bool            `json:"running"`
+	ListenPort     int             `json:"listenPort"`
+	UploadLimitKiB int             `json:"uploadLimitKiB"`
+	Torrents       []SeederTorrent `json:"torrents"`
+}
+
+// seedingEngine is the built-in BitTorrent client, seeding torrents from their source paths
+// It never downloads: missing or modified data is reported, not fetched.
+type seedingEngine struct {
+	client     *torrent.Client
+	completion storage.PieceCompletion
+	limiter    *rate.Limiter
+	port       int
+	stop       chan struct{}
+
+	mu sync.Mutex
+	// checking holds the torrents whose data is being verified on request
+	checking map[string]bool
+	// sessionBase is the upload counter of each torrent at the last save
+	sessionBase map[string]int64
+}
+
+// seeder is the running engine, nil when the built-in seeder is disabled
+var seeder struct {
+	sync.Mutex
+	engine *seedingEngine
+}
+
+// currentSeeder returns the running engine
+func currentSeeder() (*seedingEngine, error) {
+	seeder.Lock()
+	defer seeder.Unlock()
+	if seeder.engine == nil {
+		return nil, fmt.Errorf("built-in seeder is disabled")
+	}
+	return seeder.engine, nil
+}
+
+// uploadLimiter converts a KiB/s limit to a rate limiter (0 is unlimited)
+func uploadLimiter(limitKiB int) *rate.Limiter {
+	if limitKiB <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	bytesPerSec := limitKiB * 1024
+	// The burst must hold at least one 16 KiB block
+	burst := bytesPerSec
+	if burst < 64*1024 {
+		burst = 64 * 1024
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), burst)
+}
+
+// uploadLimitKiB returns the current upload limit in KiB/s (0 is unlimited)
+func (e *seedingEngine) uploadLimitKiB() int {
+	limit := e.limiter.Limit()
+	if limit == rate.Inf {
+		return 0
+	}
+	return int(limit) / 1024
+}
+
+// ConfigureSeeder starts, restarts or stops the built-in seeder to match the settings
+func (a *App) ConfigureSeeder(settings AppSettings) error {
+	seeder.Lock()
+	defer seeder.Unlock()
+
+	current := seeder.engine
+	if current != nil && settings.EnableSeeder && current.port == settings.SeederPort {
+		// Only the rate limit changed: no need to restart the client
+		limit := uploadLimiter(settings.SeederUploadLimitKiB)
+		current.limiter.SetLimit(limit.Limit())
+		current.limiter.SetBurst(limit.Burst())
+		return nil
+	}
+	if current != nil {
+		current.close()
+		seeder.engine = nil
+		logInfo("Seeder: stopped")
+	}
+	if !settings.EnableSeeder {
+		return nil
+	}
+
+	engine, err := newSeedingEngine(settings.SeederPort, settings.SeederUploadLimitKiB)
+	if err != nil {
+		return err
+	}
+	seeder.engine = engine
+	logInfo("Seeder: listening on port %d", settings.SeederPort)
+	engine.resume()
+	return nil
+}
+
+func newSeedingEngine(port int, uploadLimitKiB int) (*seedingEngine, error) {
+	stateDir := filepath.Join(dataDir, "seeder")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create seeder directory: %w", err)
+	}
+	// Piece completion survives restarts, so the data is only hashed once
+	completion, err := storage.NewBoltPieceCompletion(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open seeder state: %w", err)
+	}
+
+	e := &seedingEngine{
+		completion:  completion,
+		limiter:     uploadLimiter(uploadLimitKiB),
+		port:        port,
+		stop:        make(chan struct{}),
+		checking:    map[string]bool{},
+		sessionBase: map[string]int64{},
+	}
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.Seed = true
+	cfg.ListenPort = port
+	cfg.DataDir = stateDir
+	cfg.DefaultStorage = e.storageFor(stateDir)
+	cfg.UploadRateLimiter = e.limiter
+	// The port is published by Docker, UPnP would only add noise
+	cfg.NoDefaultPortForwarding = true
+	configureSeederClient(cfg)
+
+	client, err := torrent.NewClient(cfg)
+	if err != nil {
+		completion.Close()
+		return nil, fmt.Errorf("failed to start seeder on port %d: %w", port, err)
+	}
+	e.client = client
+	go e.saveLoop()
+	return e, nil
+}
+
+// storageFor returns a file storage rooted at dir sharing the engine's piece completion
+func (e *seedingEngine) storageFor(dir string) storage.ClientImpl {
+	return storage.NewFileOpts(storage.NewFileClientOpts{ClientBaseDir: dir, PieceCompletion: e.completion})
+}
+
+// close saves the upload counters and shuts the client down
+func (e *seedingEngine) close() {
+	close(e.stop)
+	e.saveUploaded()
+	for _, err := range e.client.Close() {
+		logWarn("Seeder: close: %v", err)
+	}
+}
+
+// resume starts the torrents of the seeder_torrents table that aren't paused
+func (e *seedingEngine) resume() {
+	rows, err := querySeederTorrents("paused = 0")
+	if err != nil {
+		logError("Seeder: failed to load torrents: %v", err)
+		return
+	}
+	for _, row := range rows {
+		if err := e.start(row); err != nil {
+			logWarn("Seeder: failed to start %s: %v", row.Name, err)
+		}
+	}
+	if len(rows) > 0 {
+		logInfo("Seeder: resumed %d torrents", len(rows))
+	}
+}
+
+// start adds a torrent to the client, seeding it from its save directory
+func (e *seedingEngine) start(row seederTorrent) error {
+	mi, err := metainfo.LoadFromFile(row.TorrentPath)
+	if err != nil {
+		return fmt.Errorf("failed to load torrent file: %w", err)
+	}
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(mi)
+	if err != nil {
+		return err
+	}
+	spec.Storage = e.storageFor(row.SaveDir)
+	// Never download into the source directory
+	spec.DisallowDataDownload = true
+	t, isNew, err := e.client.AddTorrentSpec(spec)
+	if err != nil {
+		return err
+	}
+	// A torrent already running keeps its base, or the bytes sent since the last save would be lost
+	if isNew {
+		e.mu.Lock()
+		e.sessionBase[row.InfoHash] = uploadedBytes(t)
+		e.mu.Unlock()
+	}
+	return nil
+}
+
+// drop removes a torrent from the client, keeping its upload counter
+func (e *seedingEngine) drop(infoHash string) {
+	t, ok := e.torrent(infoHash)
+	if !ok {
+		return
+	}
+	e.saveTorrentUploaded(infoHash, t)
+	t.Drop()
+	e.mu.Lock()
+	delete(e.sessionBase, infoHash)
+	e.mu.Unlock()
+}
+
+// torrent returns the running torrent with this info hash
+func (e *seedingEngine) torrent(infoHash string) (*torrent.Torrent, bool) {
+	var h metainfo.Hash
+	if err := h.FromHexString(infoHash); err != nil {
+		return nil, false
+	}
+	return e.client.Torrent(h)
+}
+
+// saveLoop periodically saves the upload counters
+func (e *seedingEngine) saveLoop() {
+	ticker := time.NewTicker(seederSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.saveUploaded()
+		}
+	}
+}
+
+// saveUploaded adds the bytes uploaded since the last save to every running torrent
+func (e *seedingEngine) saveUploaded() {
+	for _, t := range e.client.Torrents() {
+		e.saveTorrentUploaded(strings.ToLower(t.InfoHash().HexString()), t)
+	}
+}
+
+func (e *seedingEngine) saveTorrentUploaded(infoHash string, t *torrent.Torrent) {
+	written := uploadedBytes(t)
+	e.mu.Lock()
+	delta := written - e.sessionBase[infoHash]
+	e.sessionBase[infoHash] = written
+	e.mu.Unlock()
+	if delta <= 0 {
+		return
+	}
+	if _, err := db.Exec("UPDATE seeder_torrents SET uploaded = uploaded + ? WHERE info_hash = ?", delta, infoHash); err != nil {
+		logWarn("Seeder: failed to save upload counter of %s: %v", infoHash, err)
+	}
+}
+
+// status builds the state of a torrent from its row and the running client
+func (e *seedingEngine) status(row seederTorrent) SeederTorrent {
+	st := SeederTorrent{
+		TorrentStatus: TorrentStatus{
+			InfoHash: row.InfoHash,
+			Name:     row.Name,
+			State:    "paused",
+			Size:     row.Size,
+			Uploaded: row.Uploaded,
+			SavePath: row.SaveDir,
+		},
+		TorrentPath: row.TorrentPath,
+		Paused:      row.Paused,
+		AddedAt:     row.AddedAt,
+	}
+	t, ok := e.torrent(row.InfoHash)
+	if row.Paused || !ok {
+		st.Ratio = ratioOf(st.Uploaded, st.Size)
+		return st
+	}
+
+	stats := t.Stats()
+	e.mu.Lock()
+	st.Uploaded += stats.BytesWrittenData.Int64() - e.sessionBase[row.InfoHash]
+	checking := e.checking[row.InfoHash]
+	e.mu.Unlock()
+	st.Ratio = ratioOf(st.Uploaded, st.Size)
+	st.Peers = stats.ActivePeers
+	st.Seeders = stats.ConnectedSeeders
+
+	if t.Info() == nil {
+		st.State = "checking"
+		return st
+	}
+	if length := t.Length(); length > 0 {
+		st.Progress = float64(t.BytesCompleted()) / float64(length)
+	}
+	hashing := checking
+	for _, run := range t.PieceStateRuns() {
+		if run.Hashing || run.QueuedForHash {
+			hashing = true
+			break
+		}
+	}
+	switch {
+	case hashing:
+		st.State = "checking"
+	case t.BytesCompleted() == t.Length():
+		st.State = "seeding"
+	default:
+		// Downloading is disabled: incomplete data stays incomplete
+		st.State = "error"
+		st.Error = fmt.Sprintf("only %.1f%% of the data matches the torrent (missing or modified files in %s)",
+			st.Progress*100, row.SaveDir)
+	}
+	return st
+}
+
+// uploadedBytes returns the piece data sent by a torrent since it was added to the client
+func uploadedBytes(t *torrent.Torrent) int64 {
+	stats := t.Stats()
+	return stats.BytesWrittenData.Int64()
+}
+
+// ratioOf returns uploaded/size, 0 for an empty torrent
+func ratioOf(uploaded, size int64) float64 {
+	if size <= 0 {
+		return 0
+	}
+	return float64(uploaded) / float64(size)
+}
+
+// querySeederTorrents returns the seeder_torrents rows matching an optional WHERE clause
+func querySeederTorrents(where string, args ...interface{}) ([]seederTorrent, error) {
+	query := "SELECT info_hash, name, torrent_path, save_dir, size, uploaded, paused, added_at FROM seeder_torrents"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY added_at DESC"
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []seederTorrent
+	for rows.Next() {
+		var r seederTorrent
+		if err := rows.Scan(&r.InfoHash, &r.Name, &r.TorrentPath, &r.SaveDir, &r.Size, &r.Uploaded, &r.Paused, &r.AddedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// seederTorrentRow returns the row of a torrent, or ErrTorrentNotFound
+func seederTorrentRow(infoHash string) (seederTorrent, error) {
+	rows, err := querySeederTorrents("info_hash = ?", strings.ToLower(infoHash))
+	if err != nil {
+		return seederTorrent{}, err
+	}
+	if len(rows) == 0 {
+		return seederTorrent{}, ErrTorrentNotFound
+	}
+	return rows[0], nil
+}
+
+// GetSeederInfo reports the built-in seeder and the state of its torrents
+func (a *App) GetSeederInfo() (SeederInfo, error) {
+	settings := a.GetSettings()
+	info := SeederInfo{
+		Enabled:        settings.EnableSeeder,
+		ListenPort:     settings.SeederPort,
+		UploadLimitKiB: settings.SeederUploadLimitKiB,
+		Torrents:       []SeederTorrent{},
+	}
+	e, err := currentSeeder()
+	if err != nil {
+		return info, nil
+	}
+	info.Running = true
+	rows, err := querySeederTorrents("")
+	if err != nil {
+		return info, err
+	}
+	for _, row := range rows {
+		info.Torrents = append(info.Torrents, e.status(row))
+	}
+	return info, nil
+}
+
+// StartSeederTorrent resumes a stopped torrent of the built-in seeder
+func (a *App) StartSeederTorrent(infoHash string) error {
+	e, err := currentSeeder()
+	if err != nil {
+		return err
+	}
+	row, err := seederTorrentRow(infoHash)
+	if err != nil {
+		return err
+	}
+	if err := e.start(row); err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE seeder_torrents SET paused = 0 WHERE info_hash = ?", row.InfoHash)
+	return err
+}
+
+// StopSeederTorrent stops seeding a torrent, keeping it in the list
+func (a *App) StopSeederTorrent(infoHash string) error {
+	e, err := currentSeeder()
+	if err != nil {
+		return err
+	}
+	row, err := seederTorrentRow(infoHash)
+	if err != nil {
+		return err
+	}
+	e.drop(row.InfoHash)
+	_, err = db.Exec("UPDATE seeder_torrents SET paused = 1 WHERE info_hash = ?", row.InfoHash)
+	return err
+}
+
+// RemoveSeederTorrent stops seeding a torrent and forgets it; the data is never deleted
+func (a *App) RemoveSeederTorrent(infoHash string) error {
+	return removeSeederTorrent(infoHash)
+}
+
+func removeSeederTorrent(infoHash string) error {
+	e, err := currentSeeder()
+	if err != nil {
+		return err
+	}
+	row, err := seederTorrentRow(infoHash)
+	if err != nil {
+		return err
+	}
+	e.drop(row.InfoHash)
+	_, err = db.Exec("DELETE FROM seeder_torrents WHERE info_hash = ?", row.InfoHash)
+	return err
+}
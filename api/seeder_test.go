@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// startTestSeeder runs the built-in seeder on a random port in the test data dir, stopping it after the test
+func startTestSeeder(t *testing.T) *seedingEngine {
+	t.Helper()
+	previous := configureSeederClient
+	configureSeederClient = func(cfg *torrent.ClientConfig) {
+		cfg.NoDHT = true
+		cfg.DisableTrackers = true
+	}
+	t.Cleanup(func() { configureSeederClient = previous })
+	settings := getDefaultSettings()
+	settings.EnableSeeder = true
+	settings.SeederPort = 0
+	app := &App{}
+	if err := app.ConfigureSeeder(settings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		settings.EnableSeeder = false
+		app.ConfigureSeeder(settings)
+	})
+	e, err := currentSeeder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// waitSeederState polls the status of a built-in seeder torrent until it reaches state
+func waitSeederState(t *testing.T, hash, state string) *TorrentStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := builtinClient{}.Status(context.Background(), hash)
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		if status.State == state {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s (%s), want %s", status.State, status.Error, state)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSeederUploadCounters(t *testing.T) {
+	initTestDB(t)
+	e := startTestSeeder(t)
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	row := seederTorrent{InfoHash: hash, Name: "Release.mkv", TorrentPath: torrentPath, SaveDir: filepath.Dir(torrentPath), Size: 17, Uploaded: 100}
+	if _, err := db.Exec(`INSERT INTO seeder_torrents (info_hash, name, torrent_path, save_dir, size, uploaded) VALUES (?, ?, ?, ?, ?, ?)`,
+		row.InfoHash, row.Name, row.TorrentPath, row.SaveDir, row.Size, row.Uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.start(row); err != nil {
+		t.Fatal(err)
+	}
+	stored := func() int64 {
+		t.Helper()
+		r, err := seederTorrentRow(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Uploaded
+	}
+
+	// Pretend 30 bytes were sent since the torrent was added
+	e.mu.Lock()
+	e.sessionBase[hash] -= 30
+	e.mu.Unlock()
+	if st := e.status(row); st.Uploaded != 130 {
+		t.Errorf("uploaded before save = %d, want 130", st.Uploaded)
+	}
+	// Starting the running torrent again keeps the bytes not saved yet
+	if err := e.start(row); err != nil {
+		t.Fatal(err)
+	}
+	e.saveUploaded()
+	if got := stored(); got != 130 {
+		t.Errorf("stored after save = %d, want 130", got)
+	}
+	e.saveUploaded()
+	if got := stored(); got != 130 {
+		t.Errorf("stored after a second save = %d, want 130", got)
+	}
+
+	// Stopping the torrent saves what it sent since the last save
+	e.mu.Lock()
+	e.sessionBase[hash] -= 20
+	e.mu.Unlock()
+	e.drop(hash)
+	if got := stored(); got != 150 {
+		t.Errorf("stored after drop = %d, want 150", got)
+	}
+	if r, _ := seederTorrentRow(hash); e.status(r).Uploaded != 150 {
+		t.Errorf("uploaded of a stopped torrent = %d, want 150", e.status(r).Uploaded)
+	}
+}
+
+func TestBuiltinClientAddRemove(t *testing.T) {
+	initTestDB(t)
+	startTestSeeder(t)
+	ctx := context.Background()
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	saveDir := filepath.Dir(torrentPath)
+	client := builtinClient{}
+
+	if err := client.Add(ctx, torrentPath, AddOptions{}); err == nil {
+		t.Error("Add without save path succeeded")
+	}
+	if err := client.Add(ctx, torrentPath, AddOptions{SavePath: saveDir}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	status := waitSeederState(t, hash, "seeding")
+	if status.Name != "Release.mkv" || status.Size != 17 || status.SavePath != saveDir || status.Progress != 1 {
+		t.Errorf("Status = %+v", *status)
+	}
+
+	// Adding the torrent again keeps its upload counter
+	if _, err := db.Exec("UPDATE seeder_torrents SET uploaded = 500 WHERE info_hash = ?", hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Add(ctx, torrentPath, AddOptions{SavePath: saveDir}); err != nil {
+		t.Fatalf("second Add: %v", err)
+	}
+	list, err := client.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].InfoHash != hash || list[0].Uploaded != 500 {
+		t.Errorf("List = %+v", list)
+	}
+
+	// Data that doesn't match the torrent is reported, never downloaded
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "Release.mkv"), []byte("something else entirely"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Add(ctx, torrentPath, AddOptions{SavePath: other}); err != nil {
+		t.Fatalf("Add to another directory: %v", err)
+	}
+	if status := waitSeederState(t, hash, "error"); status.SavePath != other {
+		t.Errorf("Status after the move = %+v", *status)
+	}
+
+	if err := client.Remove(ctx, hash, true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Remove with data: err = %v, want ErrNotSupported", err)
+	}
+	if err := client.Remove(ctx, hash, false); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := client.Status(ctx, hash); !errors.Is(err, ErrTorrentNotFound) {
+		t.Errorf("Status after Remove: err = %v, want ErrTorrentNotFound", err)
+	}
+	for _, f := range []string{filepath.Join(saveDir, "Release.mkv"), filepath.Join(other, "Release.mkv")} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("source data removed: %v", err)
+		}
+	}
+}
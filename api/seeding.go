@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// seedingPollInterval is the delay between two checks of the torrents added to the client
+const seedingPollInterval = time.Minute
+
+// seedingTrackDuration is how long seeding stats keep being refreshed after a torrent was added
+const seedingTrackDuration = 7 * 24 * time.Hour
+
+// markReleaseAddedToClient records the instances that accepted the releases with this infohash
+// and starts the seeding tracking on the first one.
+func markReleaseAddedToClient(infoHash string, clients []string) error {
+	if db == nil || len(clients) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(clients)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE releases SET client = ?, clients = ?, client_added_at = ?, seed_state = 'added',
+		seed_progress = 0, seed_error = NULL, seeded_at = NULL, seed_timed_out = 0 WHERE info_hash = ?`,
+		clients[0], string(data), time.Now().UTC().Format(time.RFC3339), infoHash)
+	return err
+}
+
+// markReleaseRemovedFromClient stops the seeding tracking of the releases with this infohash
+func markReleaseRemovedFromClient(infoHash string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec("UPDATE releases SET client = NULL, clients = NULL, seed_state = 'removed' WHERE info_hash = ?", infoHash)
+	return err
+}
+
+// GetSeedingIssues returns the tracked releases that timed out, errored or vanished from the client
+func (a *App) GetSeedingIssues() ([]Release, error) {
+	return queryReleases("client IS NOT NULL AND (seed_timed_out = 1 OR seed_state IN ('error', 'missing'))")
+}
+
+// StartSeedingMonitor periodically records the client state of the recently added releases
+func (a *App) StartSeedingMonitor() {
+	go func() {
+		ticker := time.NewTicker(seedingPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), seedingPollInterval)
+			a.pollSeedingStatus(ctx)
+			cancel()
+		}
+	}()
+}
+
+// pollSeedingStatus queries the client instance of each tracked release
+func (a *App) pollSeedingStatus(ctx context.Context) {
+	settings := a.GetSettings()
+	since := time.Now().Add(-seedingTrackDuration).UTC().Format(time.RFC3339)
+	releases, err := queryReleases("client IS NOT NULL AND client_added_at >= ?", since)
+	if err != nil {
+		logError("SeedingMonitor: failed to query releases: %v", err)
+		return
+	}
+	byClient := map[string][]Release{}
+	for _, r := range releases {
+		byClient[r.Client] = append(byClient[r.Client], r)
+	}
+	for name, list := range byClient {
+		client, err := a.GetClientInstance(settings, name)
+		if err != nil || client == nil {
+			// The instance was removed from the settings
+			continue
+		}
+		a.pollClientSeedingStatus(ctx, settings, name, client, list)
+	}
+}
+
+// pollClientSeedingStatus records the state of the releases tracked on one client instance
+func (a *App) pollClientSeedingStatus(ctx context.Context, settings AppSettings, name string, client TorrentClient, releases []Release) {
+	timeout := time.Duration(settings.SeedingTimeoutMinutes) * time.Minute
+
+	for _, r := range releases {
+		status, err := client.Status(ctx, r.InfoHash)
+		if errors.Is(err, ErrTorrentNotFound) {
+			status = &TorrentStatus{State: "missing", Progress: r.SeedProgress, Uploaded: r.SeedUploaded, Ratio: r.SeedRatio}
+		} else if errors.Is(err, ErrNotSupported) {
+			// Watch directories can't report the torrent state
+			continue
+		} else if err != nil {
+			// The client is unreachable: retry every release on the next tick
+			logWarn("SeedingMonitor: %s status failed: %v", name, err)
+			return
+		}
+
+		if status.State != r.SeedState {
+			logInfo("SeedingMonitor: %s is now %s", r.Name, status.State)
+		}
+		_, err = db.Exec(`UPDATE releases SET seed_state = ?, seed_progress = ?, seed_uploaded = ?, seed_ratio = ?,
+			tracker_status = ?, seed_error = ?, seed_checked_at = ?,
+			seeded_at = CASE WHEN ? = 'seeding' AND seeded_at IS NULL THEN ? ELSE seeded_at END
+			WHERE id = ?`,
+			status.State, status.Progress, status.Uploaded, status.Ratio,
+			status.TrackerStatus, status.Error, time.Now().UTC().Format(time.RFC3339),
+			status.State, time.Now().UTC().Format(time.RFC3339), r.ID)
+		if err != nil {
+			logError("SeedingMonitor: failed to update release %d: %v", r.ID, err)
+			continue
+		}
+
+		if r.SeededAt != "" || status.State == "seeding" || r.SeedTimedOut || timeout <= 0 {
+			continue
+		}
+		addedAt, err := time.Parse(time.RFC3339, r.ClientAddedAt)
+		if err != nil || time.Since(addedAt) < timeout {
+			continue
+		}
+		if _, err := db.Exec("UPDATE releases SET seed_timed_out = 1 WHERE id = ?", r.ID); err != nil {
+			logError("SeedingMonitor: failed to flag release %d: %v", r.ID, err)
+			continue
+		}
+		logWarn("SeedingMonitor: %s did not start seeding within %d minutes (state: %s, progress: %.0f%%, tracker: %s, error: %s)",
+			r.Name, settings.SeedingTimeoutMinutes, status.State, status.Progress*100, status.TrackerStatus, status.Error)
+	}
+}
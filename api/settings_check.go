@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/version"
+)
+
+// connectionTestTimeout bounds every request made by a connectivity test
+const connectionTestTimeout = 10 * time.Second
+
+// SettingsError describes why a settings field was rejected
+type SettingsError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidateSettings checks the settings before they are saved
+func ValidateSettings(s AppSettings) []SettingsError {
+	errs := []SettingsError{}
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, SettingsError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Accepted values are the registered clients plus "none"
+	if _, ok := torrentClientRegistry[s.TorrentClient]; !ok && s.TorrentClient != "none" {
+		known := append(registeredTorrentClients(), "none")
+		add("torrentClient", "unknown torrent client %q (expected one of: %s)", s.TorrentClient, strings.Join(known, ", "))
+	}
+
+	// URLs are checked when set, and required for the selected client
+	urlFields := []struct {
+		field  string
+		value  string
+		client string
+	}{
+		{"qbitUrl", s.QbitUrl, "qbittorrent"},
+		{"transmissionUrl", s.TransmissionUrl, "transmission"},
+		{"delugeUrl", s.DelugeUrl, "deluge"},
+		{"aria2Url", s.Aria2Url, "aria2"},
+		{"laCaleUrl", s.LaCaleUrl, ""},
+	}
+	for _, f := range urlFields {
+		if f.value == "" {
+			if s.TorrentClient == f.client {
+				add(f.field, "URL is required when %s is the selected client", f.client)
+			}
+			continue
+		}
+		if err := validateHTTPURL(f.value); err != nil {
+			add(f.field, "%v", err)
+		}
+	}
+
+	if s.RtorrentUrl == "" {
+		if s.TorrentClient == "rtorrent" {
+			add("rtorrentUrl", "URL is required when rtorrent is the selected client")
+		}
+	} else if _, err := newRtorrentClient(s.RtorrentUrl, "", ""); err != nil {
+		add("rtorrentUrl", "%v", err)
+	}
+
+	if s.TorrentClient == "watchdir" {
+		if s.WatchDir == "" {
+			add("watchDir", "directory is required when watchdir is the selected client")
+		} else if err := checkWritableDir(s.WatchDir); err != nil {
+			add("watchDir", "%v", err)
+		}
+	}
+
+	if s.TorrentClient == "builtin" && !s.EnableSeeder {
+		add("enableSeeder", "the built-in seeder must be enabled when it is the selected client")
+	}
+	if s.EnableSeeder && (s.SeederPort < 1 || s.SeederPort > 65535) {
+		add("seederPort", "must be between 1 and 65535")
+	}
+	if s.SeederUploadLimitKiB < 0 {
+		add("seederUploadLimitKiB", "must be positive (0 for unlimited)")
+	}
+
+	validateClientInstances(s, add)
+	validateTrackerProfiles(s, add)
+
+	if s.EnableHardlink {
+		if len(s.HardlinkDirs) == 0 {
+			add("hardlinkDirs", "at least one directory is required when hardlinks are enabled")
+		}
+		for _, dir := range s.HardlinkDirs {
+			if err := checkWritableDir(dir); err != nil {
+				add("hardlinkDirs", "%s: %v", dir, err)
+			}
+		}
+	}
+
+	switch strings.ToLower(s.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("logLevel", "unknown log level %q (expected debug, info, warn or error)", s.LogLevel)
+	}
+	// configureLogging compares the format case-insensitively, like the level
+	switch strings.ToLower(s.LogFormat) {
+	case "text", "json":
+	default:
+		add("logFormat", "unknown log format %q (expected text or json)", s.LogFormat)
+	}
+
+	switch s.ClientContentLayout {
+	case "Original", "Subfolder", "NoSubfolder":
+	default:
+		add("clientContentLayout", "unknown content layout %q (expected Original, Subfolder or NoSubfolder)", s.ClientContentLayout)
+	}
+
+	if s.BackupIntervalHours < 0 {
+		add("backupIntervalHours", "must be positive")
+	}
+	if s.BackupRetention < 0 {
+		add("backupRetention", "must be positive")
+	}
+	if s.SeedingTimeoutMinutes < 0 {
+		add("seedingTimeoutMinutes", "must be positive")
+	}
+
+	return errs
+}
+
+// formSettings returns the settings of the form as they will be used once saved
+// Empty values fall back to their defaults, as loadStoredSettings reads them, and the environment and file overrides apply.
+func formSettings(s AppSettings) (AppSettings, error) {
+	fillSettingsDefaults(&s)
+	if err := applySettingsOverrides(&s, settingsOverrides); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// validateSettingsToSave checks settings as they will be used once saved
+func validateSettingsToSave(s AppSettings) ([]SettingsError, error) {
+	s, err := formSettings(s)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateSettings(s), nil
+}
+
+// validateHTTPURL accepts absolute http(s) URLs with a host
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// checkWritableDir verifies that dir exists, is a directory and accepts new files
+func checkWritableDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("directory does not exist")
+		}
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	f, err := os.CreateTemp(dir, ".aatm-write-test-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %v", err)
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+	return nil
+}
+
+// ConnectionTestResult is the outcome of POST /api/settings/test/{target}
+type ConnectionTestResult struct {
+	Target       string            `json:"target"`
+	OK           bool              `json:"ok"`
+	Version      string            `json:"version,omitempty"`
+	Capabilities map[string]string `json:"capabilities,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	DurationMs   int64             `json:"durationMs"`
+}
+
+// TestConnection logs in to the given service with the provided settings and reports its version
+// target is one of "qbittorrent", "transmission", "deluge", "rtorrent", "aria2", "watchdir", "builtin", "lacale", "tmdb"
+// or the name of a client instance or tracker profile.
+func (a *App) TestConnection(target string, settings AppSettings) ConnectionTestResult {
+	start := time.Now()
+	result := ConnectionTestResult{Target: target, Capabilities: map[string]string{}}
+
+	var err error
+	switch target {
+	case "qbittorrent", "transmission", "deluge", "rtorrent", "aria2":
+		err = testTorrentClient(settings, target, &result)
+	case "watchdir":
+		err = testWatchDir(settings, &result)
+	case "builtin":
+		err = testBuiltinSeeder(settings, &result)
+	case "lacale":
+		err = testTracker(settings, target, &result)
+	case "tmdb":
+		err = testTMDB(&result)
+	default:
+		// A named client instance is tested with the test of its type
+		if ci, ok := findClientInstance(settings, target); ok && ci.Name != ci.Type {
+			result = a.TestConnection(ci.Type, ci.settings(settings))
+			result.Target = target
+			return result
+		}
+		if _, ok := findTrackerProfile(settings, target); ok {
+			err = testTracker(settings, target, &result)
+			break
+		}
+		err = fmt.Errorf("unknown target %q", target)
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		logWarn("TestConnection: %s failed: %v", target, err)
+		return result
+	}
+	result.OK = true
+	logInfo("TestConnection: %s ok (version %s)", target, result.Version)
+	return result
+}
+
+// testTorrentClient logs in to a torrent client with the implementation used for uploads
+func testTorrentClient(s AppSettings, name string, result *ConnectionTestResult) error {
+	factory, ok := torrentClientRegistry[name]
+	if !ok {
+		return fmt.Errorf("unknown torrent client %q", name)
+	}
+	client, err := factory.new(s)
+	if err != nil {
+		return err
+	}
+	versioner, ok := client.(clientVersioner)
+	if !ok {
+		return fmt.Errorf("%s can't be tested", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTestTimeout)
+	defer cancel()
+	version, details, err := versioner.Version(ctx)
+	for k, v := range details {
+		result.Capabilities[k] = v
+	}
+	if err != nil {
+		return err
+	}
+	result.Version = version
+	return nil
+}
+
+func testWatchDir(s AppSettings, result *ConnectionTestResult) error {
+	if s.WatchDir == "" {
+		return fmt.Errorf("watch directory is not configured")
+	}
+	if err := checkWritableDir(s.WatchDir); err != nil {
+		return fmt.Errorf("%s: %v", s.WatchDir, err)
+	}
+	return nil
+}
+
+// testBuiltinSeeder reports the running seeder; the unsaved settings only apply once saved
+func testBuiltinSeeder(s AppSettings, result *ConnectionTestResult) error {
+	e, err := currentSeeder()
+	if err != nil {
+		return err
+	}
+	rows, err := querySeederTorrents("")
+	if err != nil {
+		return err
+	}
+	result.Version = version.DefaultExtendedHandshakeClientVersion
+	result.Capabilities["listenPort"] = fmt.Sprint(e.port)
+	result.Capabilities["torrents"] = fmt.Sprint(len(rows))
+	result.Capabilities["uploadLimitKiB"] = fmt.Sprint(e.uploadLimitKiB())
+	return nil
+}
+
+// testTracker logs in to a tracker profile
+func testTracker(s AppSettings, name string, result *ConnectionTestResult) error {
+	profile, _ := findTrackerProfile(s, name)
+	tracker, err := newTrackerUploader(profile)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTestTimeout)
+	defer cancel()
+	if err := tracker.Login(ctx); err != nil {
+		return err
+	}
+	result.Capabilities["type"] = profile.Type
+	if profile.Type == "lacale" {
+		result.Capabilities["passkeyConfigured"] = fmt.Sprint(profile.Passkey != "")
+	}
+	return nil
+}
+
+// withoutRequestURL drops the URL repeated by a *url.Error, so query string credentials never reach the logs or the UI
+func withoutRequestURL(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return fmt.Errorf("%s: %w", ue.Op, ue.Err)
+	}
+	return err
+}
+
+func testTMDB(result *ConnectionTestResult) error {
+	client := &http.Client{Timeout: connectionTestTimeout}
+	resp, err := client.Get("https://api.themoviedb.org/3/configuration?api_key=" + url.QueryEscape(getTMDBAPIKey()))
+	if err != nil {
+		return fmt.Errorf("request failed: %w", withoutRequestURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 401 {
+		return fmt.Errorf("invalid TMDB API key")
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("TMDB returned status %d", resp.StatusCode)
+	}
+	result.Version = "3"
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// settingsErrorFields returns the fields of the errors
+func settingsErrorFields(errs []SettingsError) map[string]bool {
+	fields := map[string]bool{}
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	return fields
+}
+
+func TestValidateSettingsToSave(t *testing.T) {
+	initTestDB(t)
+	tests := []struct {
+		name    string
+		payload string
+		env     map[string]string
+		want    []string
+	}{
+		// Empty values are the defaults, as loadStoredSettings reads them back
+		{name: "empty", payload: `{}`},
+		{name: "partial", payload: `{"torrentClient": "transmission", "logLevel": ""}`},
+		{name: "invalid", payload: `{"logLevel": "verbose", "clientContentLayout": "Flat"}`, want: []string{"logLevel", "clientContentLayout"}},
+		{name: "watchdir without directory", payload: `{"torrentClient": "watchdir"}`, want: []string{"watchDir"}},
+		// An overridden field is saved from the stored settings: the form value doesn't count
+		{name: "overridden", payload: `{"logLevel": "verbose"}`, env: map[string]string{"AATM_LOG_LEVEL": "debug"}},
+		{name: "invalid override", payload: `{}`, env: map[string]string{"AATM_LOG_FORMAT": "xml"}, want: []string{"logFormat"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if err := loadTestOverrides(t, "", ""); err != nil {
+				t.Fatal(err)
+			}
+			var settings AppSettings
+			if err := json.Unmarshal([]byte(tt.payload), &settings); err != nil {
+				t.Fatal(err)
+			}
+			errs, err := validateSettingsToSave(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := settingsErrorFields(errs)
+			if len(got) != len(tt.want) {
+				t.Errorf("errors = %+v, want fields %v", errs, tt.want)
+			}
+			for _, field := range tt.want {
+				if !got[field] {
+					t.Errorf("no error for %s in %+v", field, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreBackupOlderSettings(t *testing.T) {
+	initTestDB(t)
+	// A backup written before the logging, content layout and seeder settings existed
+	var archive BackupArchive
+	if err := json.Unmarshal([]byte(`{"version": 1, "settings": {"rootPath": "/data", "torrentClient": "qbittorrent", "qbitUrl": "http://qbit:8080"}}`), &archive); err != nil {
+		t.Fatal(err)
+	}
+	report, err := (&App{}).RestoreBackup(archive, false)
+	if err != nil {
+		t.Fatalf("restore: %v (%+v)", err, report.SettingsErrors)
+	}
+	settings := (&App{}).GetSettings()
+	if settings.QbitUrl != "http://qbit:8080" || settings.LogLevel != "info" || settings.ClientContentLayout != "Original" {
+		t.Errorf("settings = %+v", settings)
+	}
+}
@@ -119,6 +119,16 @@ const ApiClient = {
         return this.post('/api/settings', settings);
     },
 
+    /**
+     * Teste la connexion à un service (client torrent, La-Cale, TMDB)
+     * @param {string} target - Service à tester
+     * @param {Object} settings - Paramètres à utiliser (non sauvegardés)
+     * @returns {Promise<Object>} {ok, version, capabilities, error}
+     */
+    async testConnection(target, settings) {
+        return this.post(`/api/settings/test/${target}`, settings);
+    },
+
     // ===== Torrent =====
     
     /**
@@ -133,10 +143,44 @@ const ApiClient = {
     /**
      * Upload un torrent vers le client
      * @param {string} torrentPath - Chemin du fichier torrent
+     * @param {string} [contentPath] - Fichier/dossier du contenu (source ou hardlink), utilisé comme dossier de destination
+     * @param {Object} [route] - {tracker, mediaType, sourcePath} pour les règles de routage des clients
+     * @returns {Promise<Object>} {status, client, results: [{client, type, error}]}
+     */
+    async uploadToClient(torrentPath, contentPath, route = {}) {
+        return this.post('/api/torrent-client/upload', { torrentPath, contentPath, ...route });
+    },
+
+    /**
+     * Recherche des releases déjà traitées pour ce contenu (historique + tracker)
+     * @param {Object} options - {sourcePath, title, checkTracker, tracker}
+     * @returns {Promise<Object>} {fingerprint, matches, trackerMatches, trackerCheck}
+     */
+    async checkDuplicates(options) {
+        return this.post('/api/duplicates/check', options);
+    },
+
+    // ===== Seeder intégré =====
+
+    /**
+     * État du seeder intégré et statistiques de ses torrents
+     * @returns {Promise<Object>} {enabled, running, listenPort, uploadLimitKiB, torrents}
+     */
+    async getSeeder() {
+        return this.get('/api/seeder');
+    },
+
+    /**
+     * Démarre, arrête ou retire un torrent du seeder intégré (les données ne sont jamais supprimées)
+     * @param {string} infoHash - Info hash du torrent
+     * @param {string} action - start, stop ou remove
      * @returns {Promise<Object>}
      */
-    async uploadToClient(torrentPath) {
-        return this.post('/api/torrent-client/upload', { torrentPath });
+    async seederAction(infoHash, action) {
+        if (action === 'remove') {
+            return this.delete(`/api/seeder/torrents/${infoHash}`);
+        }
+        return this.post(`/api/seeder/torrents/${infoHash}/${action}`);
     },
 
     // ===== NFO =====
@@ -172,6 +216,14 @@ const ApiClient = {
         return this.post('/api/processed/mark', { path });
     },
 
+    /**
+     * Releases suivies qui n'ont pas commencé à seeder, en erreur ou absentes du client
+     * @returns {Promise<Array>}
+     */
+    async getSeedingIssues() {
+        return this.get('/api/releases/seeding-issues');
+    },
+
     /**
      * Récupère la liste des fichiers traités
      * @returns {Promise<Array>}
@@ -259,6 +311,41 @@ const ApiClient = {
         return this.post('/api/lacale/all-tags', options);
     },
 
+    /**
+     * Vérifie des tags La Cale avant l'upload (catégorie, caractéristiques obligatoires et à choix unique)
+     * @param {Object} options - Options {mediaType, categoryId, tags}
+     * @returns {Promise<Object>} {valid, errors: [{characteristic, slug, tagIds, message}]}
+     */
+    async validateLaCaleTags(options) {
+        return this.post('/api/lacale/validate-tags', options);
+    },
+
+    /**
+     * Explique la sélection automatique des tags La Cale
+     * @param {Object} options - Options {mediaType, releaseInfo}
+     * @returns {Promise<Object>} {tracker, categoryId, characteristics: [{values, aliases, selected, discarded, fallbackFired, candidates}]}
+     */
+    async explainLaCaleTags(options) {
+        return this.post('/api/lacale/explain-tags', options);
+    },
+
+    /**
+     * Synchronise le catalogue de tags depuis La Cale
+     * @param {boolean} dryRun - calcule seulement les différences sans enregistrer
+     * @returns {Promise<Object>} {version, tagCount, changed, diff: {added, removed, renamed, idChanged}}
+     */
+    async syncLaCaleTags(dryRun = false) {
+        return this.post('/api/lacale/tags/sync' + (dryRun ? '?dryRun=true' : ''));
+    },
+
+    /**
+     * Liste les versions synchronisées du catalogue de tags
+     * @returns {Promise<Object>} {current, versions}
+     */
+    async getTagCatalogVersions() {
+        return this.get('/api/lacale/tags/versions');
+    },
+
     // ===== Santé =====
     
     /**
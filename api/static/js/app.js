@@ -21,6 +21,8 @@ document.addEventListener('DOMContentLoaded', () => {
     });
     checkApiStatus();
     setInterval(checkApiStatus, 30000);
+    checkSeedingIssues();
+    setInterval(checkSeedingIssues, 60000);
 
     // Event listeners pour le workflow
     document.getElementById('btnStep1Next').addEventListener('click', () => goToStep(2));
@@ -84,6 +86,7 @@ function navigateTo(page) {
             break;
         case 'history':
             loadHistory();
+            checkSeedingIssues();
             break;
         case 'create':
             updateWorkflowUI();
@@ -106,6 +109,58 @@ async function checkApiStatus() {
     }
 }
 
+// ============ SUIVI DU SEED ============
+
+/**
+ * Décrit le problème de seed d'une release
+ * @param {Object} release - Release renvoyée par /api/releases/seeding-issues
+ * @returns {string}
+ */
+function describeSeedingIssue(release) {
+    if (release.seedState === 'missing') return 'absent du client';
+    if (release.seedState === 'error') return `en erreur${release.seedError ? ' : ' + release.seedError : ''}`;
+    const details = [release.seedState, release.trackerStatus].filter(Boolean).join(', ');
+    return `ne seed pas${details ? ' (' + details + ')' : ''}`;
+}
+
+/**
+ * Met à jour le badge de l'historique et signale les nouveaux problèmes de seed
+ */
+async function checkSeedingIssues() {
+    let issues;
+    try {
+        issues = await ApiClient.getSeedingIssues() || [];
+    } catch (e) {
+        return; // L'API est hors ligne : checkApiStatus le signale déjà
+    }
+
+    const badge = document.getElementById('seedingIssuesBadge');
+    badge.textContent = issues.length;
+    badge.classList.toggle('hidden', issues.length === 0);
+
+    issues.forEach(release => {
+        if (AppState.notifiedSeedingIssues.has(release.id)) return;
+        AppState.notifiedSeedingIssues.add(release.id);
+        showToast(`${release.name} : ${describeSeedingIssue(release)} sur ${release.client}`, 'error', 10000);
+    });
+
+    if (AppState.currentPage === 'history') renderSeedingIssues(issues);
+}
+
+/**
+ * Affiche les problèmes de seed en tête de l'historique
+ * @param {Array} issues - Releases en problème
+ */
+function renderSeedingIssues(issues) {
+    const list = document.getElementById('seedingIssuesList');
+    list.innerHTML = issues.length === 0 ? '' : `
+        <div class="alert alert-warning">
+            <strong>Torrents qui ne seedent pas</strong>
+            ${issues.map(r => `<div>${escapeHtml(r.name)} (${escapeHtml(r.client)}) : ${escapeHtml(describeSeedingIssue(r))}</div>`).join('')}
+        </div>
+    `;
+}
+
 // ============ EXPLORATEUR DE FICHIERS ============
 
 async function loadFiles(path) {
@@ -471,6 +526,7 @@ async function goToStep(step) {
         case 6:
             document.getElementById('createdTorrentPath').textContent = AppState.createdTorrentPath || '-';
             document.getElementById('createdNfoPath').textContent = AppState.createdNfoPath || '-';
+            document.getElementById('uploadAnonymous').checked = AppState.settings.laCaleAnonymous || false;
             break;
     }
 }
@@ -768,6 +824,8 @@ async function selectSteamGame(id) {
 // ============ CREATION TORRENT ============
 
 async function createTorrent() {
+    if (!(await confirmNoDuplicates())) return;
+
     await goToStep(5);
     
     const btn = document.getElementById('btnValidationConfirm');
@@ -827,6 +885,9 @@ async function createTorrent() {
             }
         }
 
+        // Le client torrent seedera le hardlink s'il existe, sinon la source
+        AppState.contentPath = hardlinkPath || AppState.selectedFile;
+
         // Marquer comme traité
         await ApiClient.markProcessed(AppState.selectedFile);
 
@@ -857,13 +918,45 @@ async function createTorrent() {
     }
 }
 
+/**
+ * Vérifie que le contenu n'a pas déjà été traité (historique ou La-Cale)
+ * @returns {Promise<boolean>} true si l'on peut continuer
+ */
+async function confirmNoDuplicates() {
+    let report;
+    try {
+        report = await ApiClient.checkDuplicates({
+            sourcePath: AppState.selectedFile,
+            title: AppState.torrentName,
+            checkTracker: true
+        });
+    } catch (e) {
+        console.warn('Duplicate check failed:', e);
+        return true;
+    }
+
+    const lines = [
+        ...(report.matches || []).map(r => `- ${r.name} (${r.createdAt})`),
+        ...(report.trackerMatches || []).map(t => `- La-Cale: ${t.name}`)
+    ];
+    if (lines.length === 0) return true;
+
+    return confirm(`Ce contenu semble déjà avoir été traité :\n${lines.join('\n')}\n\nContinuer quand même ?`);
+}
+
 // ============ UPLOAD ============
 
-async function uploadToTorrentClient() {
+/**
+ * Ajoute le torrent au(x) client(s)
+ * @param {string} [torrentPath] - torrent réécrit par le tracker, à seeder à la place de celui créé
+ * @param {string} [tracker] - profil de tracker utilisé pour l'upload (règles de routage, options du client)
+ */
+async function uploadToTorrentClient(torrentPath, tracker) {
     const statusEl = document.getElementById('uploadStatus');
     const clientName = AppState.settings.torrentClient || 'qbittorrent';
+    const instances = AppState.settings.clientInstances || [];
     
-    if (clientName === 'none') {
+    if (clientName === 'none' && instances.length === 0) {
         statusEl.innerHTML = '<div class="alert alert-info">Aucun client torrent configure - etape ignoree</div>';
         return;
     }
@@ -871,15 +964,31 @@ async function uploadToTorrentClient() {
     const clientDisplayNames = {
         'qbittorrent': 'qBittorrent',
         'transmission': 'Transmission',
-        'deluge': 'Deluge'
+        'deluge': 'Deluge',
+        'rtorrent': 'rTorrent',
+        'aria2': 'aria2',
+        'watchdir': 'le dossier surveille',
+        'builtin': 'le seeder integre'
     };
-    const displayName = clientDisplayNames[clientName] || clientName;
+    // Avec plusieurs instances, les regles de routage choisissent le(s) client(s)
+    const displayName = instances.length > 0 ? 'le client torrent' : (clientDisplayNames[clientName] || clientName);
     statusEl.innerHTML = `<div class="loading"><div class="spinner"></div>Upload vers ${displayName}...</div>`;
     
     try {
-        await ApiClient.uploadToClient(AppState.createdTorrentPath);
-        statusEl.innerHTML = `<div class="alert alert-success">Upload ${displayName} reussi!</div>`;
-        showToast(`Upload ${displayName} OK!`, 'success');
+        const result = await ApiClient.uploadToClient(torrentPath || AppState.createdTorrentPath, AppState.contentPath, {
+            tracker: tracker || '',
+            mediaType: AppState.mediaType,
+            sourcePath: AppState.selectedFile
+        });
+        const failed = (result.results || []).filter(r => r.error);
+        const added = result.client || displayName;
+        if (failed.length > 0) {
+            const errors = failed.map(r => `${r.client}: ${r.error}`).join('<br>');
+            statusEl.innerHTML = `<div class="alert alert-warning">Ajoute a ${added}, echec pour :<br>${errors}</div>`;
+        } else {
+            statusEl.innerHTML = `<div class="alert alert-success">Upload ${added} reussi!</div>`;
+        }
+        showToast(`Upload ${added} OK!`, 'success');
     } catch (e) {
         statusEl.innerHTML = `<div class="alert alert-danger">Erreur: ${e.message}</div>`;
         showToast('Erreur: ' + e.message, 'error');
@@ -890,6 +999,10 @@ async function uploadToQBittorrent() {
     return uploadToTorrentClient();
 }
 
+/**
+ * Upload vers La Cale
+ * @returns {Promise<Object|null>} résultat de l'upload (tracker, url, torrentPath si le tracker a modifié le torrent), null en cas d'échec
+ */
 async function uploadToLaCale() {
     const statusEl = document.getElementById('uploadStatus');
     statusEl.innerHTML = '<div class="loading"><div class="spinner"></div>Generation de la description et upload...</div>';
@@ -943,6 +1056,10 @@ async function uploadToLaCale() {
             passkey: AppState.settings.passkey,
             email: AppState.settings.laCaleEmail,
             password: AppState.settings.laCalePassword,
+            anonymous: document.getElementById('uploadAnonymous').checked,
+            imdbId: media?.imdbId || '',
+            trailerUrl: document.getElementById('uploadTrailerUrl').value.trim(),
+            screenshots: document.getElementById('uploadScreenshots').value.split('\n').map(s => s.trim()).filter(Boolean),
             customTags: media?.selectedTagIds 
                 ? Array.from(media.selectedTagIds) 
                 : (AppState.selectedTagIds ? Array.from(AppState.selectedTagIds) : [])
@@ -951,16 +1068,42 @@ async function uploadToLaCale() {
         // Ajouter releaseInfo pour compatibilité backend
         uploadData.releaseInfo = media ? media.toJSON() : AppState.releaseInfo;
 
-        await ApiClient.uploadToLaCale(uploadData);
+        // Vérifier les tags choisis avant l'envoi (sans tags, le serveur les détecte puis les vérifie)
+        if (uploadData.customTags.length > 0) {
+            const check = await ApiClient.validateLaCaleTags({ mediaType: uploadData.mediaType, tags: uploadData.customTags });
+            if (!check.valid) {
+                statusEl.innerHTML = `<div class="alert alert-danger">Tags invalides :<br>${formatTagErrors(check.errors)}</div>`;
+                showToast('Tags La-Cale invalides', 'error');
+                return null;
+            }
+        }
+
+        const result = await ApiClient.uploadToLaCale(uploadData);
 
         statusEl.innerHTML = '<div class="alert alert-success">Upload La-Cale reussi!</div>';
         showToast('Upload La-Cale OK!', 'success');
+        return result;
     } catch (e) {
-        statusEl.innerHTML = `<div class="alert alert-danger">Erreur: ${e.message}</div>`;
+        let message = escapeHtml(e.message);
+        try {
+            const data = JSON.parse(e.message);
+            if (Array.isArray(data.tagErrors)) message = 'Tags invalides :<br>' + formatTagErrors(data.tagErrors);
+        } catch {}
+        statusEl.innerHTML = `<div class="alert alert-danger">Erreur: ${message}</div>`;
         showToast('Erreur: ' + e.message, 'error');
+        return null;
     }
 }
 
+/**
+ * Met en forme les erreurs de tags par caractéristique (validate-tags ou upload refusé)
+ * @param {Array} errors - [{characteristic, message}]
+ * @returns {string} HTML
+ */
+function formatTagErrors(errors) {
+    return errors.map(err => escapeHtml((err.characteristic ? err.characteristic + ' : ' : '') + err.message)).join('<br>');
+}
+
 // ============ VALIDATION ============
 
 async function populateValidationScreen() {
@@ -1182,8 +1325,9 @@ async function finishWorkflow() {
     btn.disabled = true;
     btn.textContent = 'Upload en cours...';
 
-    await uploadToTorrentClient();
-    await uploadToLaCale();
+    // La Cale d'abord : le torrent servi par le tracker (announce, source) est celui a seeder
+    const laCaleResult = await uploadToLaCale();
+    await uploadToTorrentClient(laCaleResult?.torrentPath, laCaleResult?.tracker);
 
     AppState.resetWorkflow();
     AppState.selectedFile = null;
@@ -1219,27 +1363,145 @@ function loadSettingsForm() {
     document.getElementById('settingTransmissionPassword').value = AppState.settings.transmissionPassword || '';
     document.getElementById('settingDelugeUrl').value = AppState.settings.delugeUrl || 'http://localhost:8112';
     document.getElementById('settingDelugePassword').value = AppState.settings.delugePassword || 'deluge';
+    document.getElementById('settingRtorrentUrl').value = AppState.settings.rtorrentUrl || 'scgi://localhost:5000';
+    document.getElementById('settingRtorrentUsername').value = AppState.settings.rtorrentUsername || '';
+    document.getElementById('settingRtorrentPassword').value = AppState.settings.rtorrentPassword || '';
+    document.getElementById('settingAria2Url').value = AppState.settings.aria2Url || 'http://localhost:6800/jsonrpc';
+    document.getElementById('settingAria2Secret').value = AppState.settings.aria2Secret || '';
+    document.getElementById('settingWatchDir').value = AppState.settings.watchDir || '';
+    document.getElementById('settingClientInstances').value = formatJsonSetting(AppState.settings.clientInstances);
+    document.getElementById('settingClientRoutes').value = formatJsonSetting(AppState.settings.clientRoutes);
+    document.getElementById('settingClientCategory').value = AppState.settings.clientCategory || '';
+    document.getElementById('settingClientTags').value = AppState.settings.clientTags || '';
+    document.getElementById('settingClientContentLayout').value = AppState.settings.clientContentLayout || 'Original';
+    document.getElementById('settingClientSkipChecking').checked = AppState.settings.clientSkipChecking || false;
+    document.getElementById('settingSeedingTimeoutMinutes').value = AppState.settings.seedingTimeoutMinutes || 30;
+    document.getElementById('settingEnableSeeder').checked = AppState.settings.enableSeeder || false;
+    document.getElementById('settingSeederPort').value = AppState.settings.seederPort || 6882;
+    document.getElementById('settingSeederUploadLimitKiB').value = AppState.settings.seederUploadLimitKiB || 0;
     document.getElementById('settingLaCaleEmail').value = AppState.settings.laCaleEmail || '';
     document.getElementById('settingLaCalePassword').value = AppState.settings.laCalePassword || '';
     document.getElementById('settingLaCalePasskey').value = AppState.settings.passkey || '';
+    document.getElementById('settingLaCaleUrl').value = AppState.settings.laCaleUrl || '';
+    document.getElementById('settingLaCaleAnonymous').checked = AppState.settings.laCaleAnonymous || false;
+    document.getElementById('settingTrackerProfiles').value = formatJsonSetting(AppState.settings.trackerProfiles);
     document.getElementById('settingEnableHardlink').checked = AppState.settings.enableHardlink || false;
     document.getElementById('settingHardlinkDirs').value = (AppState.settings.hardlinkDirs || []).join('\n');
     document.getElementById('settingShowProcessed').checked = AppState.settings.showProcessed || false;
+    document.getElementById('settingEnableAutoBackup').checked = AppState.settings.enableAutoBackup || false;
+    document.getElementById('settingBackupIntervalHours').value = AppState.settings.backupIntervalHours || 24;
+    document.getElementById('settingBackupRetention').value = AppState.settings.backupRetention || 7;
+    document.getElementById('btnDownloadBackup').href = API_BASE + '/api/backup';
+    lockOverriddenSettings();
     toggleTorrentClientSettings();
 }
 
+// Correspondance champ du formulaire -> clé des paramètres
+const SETTINGS_FORM_FIELDS = {
+    settingRootPath: 'rootPath',
+    settingTrackers: 'torrentTrackers',
+    settingTorrentClient: 'torrentClient',
+    settingQbitUrl: 'qbitUrl',
+    settingQbitUsername: 'qbitUsername',
+    settingQbitPassword: 'qbitPassword',
+    settingTransmissionUrl: 'transmissionUrl',
+    settingTransmissionUsername: 'transmissionUsername',
+    settingTransmissionPassword: 'transmissionPassword',
+    settingDelugeUrl: 'delugeUrl',
+    settingDelugePassword: 'delugePassword',
+    settingRtorrentUrl: 'rtorrentUrl',
+    settingRtorrentUsername: 'rtorrentUsername',
+    settingRtorrentPassword: 'rtorrentPassword',
+    settingAria2Url: 'aria2Url',
+    settingAria2Secret: 'aria2Secret',
+    settingWatchDir: 'watchDir',
+    settingClientInstances: 'clientInstances',
+    settingClientRoutes: 'clientRoutes',
+    settingClientCategory: 'clientCategory',
+    settingClientTags: 'clientTags',
+    settingClientContentLayout: 'clientContentLayout',
+    settingClientSkipChecking: 'clientSkipChecking',
+    settingSeedingTimeoutMinutes: 'seedingTimeoutMinutes',
+    settingEnableSeeder: 'enableSeeder',
+    settingSeederPort: 'seederPort',
+    settingSeederUploadLimitKiB: 'seederUploadLimitKiB',
+    settingLaCaleEmail: 'laCaleEmail',
+    settingLaCalePassword: 'laCalePassword',
+    settingLaCalePasskey: 'passkey',
+    settingLaCaleUrl: 'laCaleUrl',
+    settingLaCaleAnonymous: 'laCaleAnonymous',
+    settingTrackerProfiles: 'trackerProfiles',
+    settingEnableHardlink: 'enableHardlink',
+    settingHardlinkDirs: 'hardlinkDirs',
+    settingShowProcessed: 'showProcessed',
+    settingEnableAutoBackup: 'enableAutoBackup',
+    settingBackupIntervalHours: 'backupIntervalHours',
+    settingBackupRetention: 'backupRetention'
+};
+
+/**
+ * Verrouille les champs imposés par une variable AATM_* ou le fichier aatm.yaml / aatm.toml
+ */
+function lockOverriddenSettings() {
+    const sources = AppState.settings.sources || {};
+    Object.entries(SETTINGS_FORM_FIELDS).forEach(([id, key]) => {
+        const el = document.getElementById(id);
+        if (!el) return;
+        const source = sources[key];
+        const locked = source === 'env' || source === 'file';
+        el.disabled = locked;
+        el.title = locked
+            ? (source === 'env' ? 'Défini par variable d\'environnement' : 'Défini par le fichier de configuration')
+            : '';
+    });
+}
+
 function toggleTorrentClientSettings() {
     const client = document.getElementById('settingTorrentClient').value;
     document.getElementById('qbittorrentSettings').style.display = client === 'qbittorrent' ? 'block' : 'none';
     document.getElementById('transmissionSettings').style.display = client === 'transmission' ? 'block' : 'none';
     document.getElementById('delugeSettings').style.display = client === 'deluge' ? 'block' : 'none';
+    document.getElementById('rtorrentSettings').style.display = client === 'rtorrent' ? 'block' : 'none';
+    document.getElementById('aria2Settings').style.display = client === 'aria2' ? 'block' : 'none';
+    document.getElementById('watchdirSettings').style.display = client === 'watchdir' ? 'block' : 'none';
 }
 
-async function saveSettings() {
+/**
+ * Affiche une liste de paramètres en JSON (vide si la liste est vide)
+ * @param {Array} value
+ * @returns {string}
+ */
+function formatJsonSetting(value) {
+    return value && value.length > 0 ? JSON.stringify(value, null, 2) : '';
+}
+
+/**
+ * Lit une liste JSON depuis un champ du formulaire
+ * @param {string} id - Identifiant du champ
+ * @param {string} label - Nom affiché en cas d'erreur
+ * @returns {Array}
+ */
+function parseJsonSetting(id, label) {
+    const text = document.getElementById(id).value.trim();
+    if (text === '') return [];
+    try {
+        const value = JSON.parse(text);
+        if (!Array.isArray(value)) throw new Error('une liste [...] est attendue');
+        return value;
+    } catch (e) {
+        throw new Error(`${label} : JSON invalide (${e.message})`);
+    }
+}
+
+/**
+ * Construit l'objet paramètres à partir du formulaire
+ * @returns {Object}
+ */
+function collectSettingsForm() {
     const hardlinkDirsText = document.getElementById('settingHardlinkDirs').value;
     const hardlinkDirs = hardlinkDirsText.split('\n').map(s => s.trim()).filter(s => s !== '');
 
-    const settings = {
+    return {
         rootPath: document.getElementById('settingRootPath').value,
         torrentTrackers: document.getElementById('settingTrackers').value,
         torrentClient: document.getElementById('settingTorrentClient').value,
@@ -1251,21 +1513,223 @@ async function saveSettings() {
         transmissionPassword: document.getElementById('settingTransmissionPassword').value,
         delugeUrl: document.getElementById('settingDelugeUrl').value,
         delugePassword: document.getElementById('settingDelugePassword').value,
+        rtorrentUrl: document.getElementById('settingRtorrentUrl').value,
+        rtorrentUsername: document.getElementById('settingRtorrentUsername').value,
+        rtorrentPassword: document.getElementById('settingRtorrentPassword').value,
+        aria2Url: document.getElementById('settingAria2Url').value,
+        aria2Secret: document.getElementById('settingAria2Secret').value,
+        watchDir: document.getElementById('settingWatchDir').value.trim(),
+        clientInstances: parseJsonSetting('settingClientInstances', 'Instances de clients'),
+        clientRoutes: parseJsonSetting('settingClientRoutes', 'Regles de routage'),
+        clientCategory: document.getElementById('settingClientCategory').value.trim(),
+        clientTags: document.getElementById('settingClientTags').value.trim(),
+        clientContentLayout: document.getElementById('settingClientContentLayout').value,
+        clientSkipChecking: document.getElementById('settingClientSkipChecking').checked,
+        seedingTimeoutMinutes: parseInt(document.getElementById('settingSeedingTimeoutMinutes').value, 10) || 30,
+        enableSeeder: document.getElementById('settingEnableSeeder').checked,
+        seederPort: parseInt(document.getElementById('settingSeederPort').value, 10) || 6882,
+        seederUploadLimitKiB: parseInt(document.getElementById('settingSeederUploadLimitKiB').value, 10) || 0,
         laCaleEmail: document.getElementById('settingLaCaleEmail').value,
         laCalePassword: document.getElementById('settingLaCalePassword').value,
         passkey: document.getElementById('settingLaCalePasskey').value,
+        laCaleUrl: document.getElementById('settingLaCaleUrl').value.trim(),
+        laCaleAnonymous: document.getElementById('settingLaCaleAnonymous').checked,
+        trackerProfiles: parseJsonSetting('settingTrackerProfiles', 'Profils de trackers'),
         enableHardlink: document.getElementById('settingEnableHardlink').checked,
         hardlinkDirs: hardlinkDirs,
-        showProcessed: document.getElementById('settingShowProcessed').checked
+        showProcessed: document.getElementById('settingShowProcessed').checked,
+        enableAutoBackup: document.getElementById('settingEnableAutoBackup').checked,
+        backupIntervalHours: parseInt(document.getElementById('settingBackupIntervalHours').value, 10) || 24,
+        backupRetention: parseInt(document.getElementById('settingBackupRetention').value, 10) || 7
     };
-    
+}
+
+async function saveSettings() {
     try {
+        // Le serveur remplace tous les paramètres : les champs absents du formulaire sont repris des valeurs chargées
+        const settings = { ...AppState.settings, ...collectSettingsForm() };
         await ApiClient.saveSettings(settings);
-        AppState.settings = settings;
-        AppState.currentPath = settings.rootPath;
+        await loadSettings();
         showToast('Parametres sauvegardes!', 'success');
     } catch (e) { 
-        showToast('Erreur: ' + e.message, 'error'); 
+        showToast('Erreur: ' + formatSettingsErrors(e.message), 'error'); 
+    }
+}
+
+/**
+ * Met en forme les erreurs de validation renvoyées par POST /api/settings
+ * @param {string} message - Corps de la réponse d'erreur
+ * @returns {string}
+ */
+function formatSettingsErrors(message) {
+    try {
+        const data = JSON.parse(message);
+        if (Array.isArray(data.errors)) {
+            return data.errors.map(err => `${err.field}: ${err.message}`).join(' | ');
+        }
+    } catch {}
+    return message;
+}
+
+/**
+ * Teste la connexion à un service avec les valeurs actuelles du formulaire
+ * @param {string} target - qbittorrent, transmission, deluge, rtorrent, aria2, watchdir, lacale ou tmdb
+ */
+async function testConnection(target) {
+    showToast(`Test ${target} en cours...`, 'info');
+    try {
+        const result = await ApiClient.testConnection(target, collectSettingsForm());
+        if (result.ok) {
+            const version = result.version ? ` (version ${result.version})` : '';
+            showToast(`${target} OK${version}`, 'success');
+        } else {
+            showToast(`${target}: ${result.error}`, 'error');
+        }
+    } catch (e) {
+        showToast('Erreur: ' + e.message, 'error');
+    }
+}
+
+/**
+ * Teste chaque instance de client déclarée dans le formulaire
+ */
+async function testClientInstances() {
+    try {
+        const instances = parseJsonSetting('settingClientInstances', 'Instances de clients');
+        if (instances.length === 0) {
+            showToast('Aucune instance supplementaire', 'info');
+            return;
+        }
+        for (const instance of instances) {
+            await testConnection(instance.name);
+        }
+    } catch (e) {
+        showToast('Erreur: ' + e.message, 'error');
+    }
+}
+
+/**
+ * Affiche les torrents du seeder intégré avec leurs statistiques
+ */
+async function loadSeederTorrents() {
+    const container = document.getElementById('seederTorrents');
+    try {
+        const info = await ApiClient.getSeeder();
+        if (!info.running) {
+            container.innerHTML = '<div class="alert alert-info">Seeder integre desactive (enregistrez les parametres apres l\'avoir active)</div>';
+            return;
+        }
+        if (info.torrents.length === 0) {
+            container.innerHTML = `<div class="alert alert-info">Port ${info.listenPort} - aucun torrent</div>`;
+            return;
+        }
+        container.innerHTML = info.torrents.map(t => `
+            <div class="history-item">
+                <span class="history-path" title="${escapeHtml(t.savePath)}">${escapeHtml(t.name)}</span>
+                <span style="color:var(--text-muted);font-size:0.8rem;margin-left:1rem;">
+                    ${escapeHtml(t.state)} - ${Math.round(t.progress * 100)}% - envoye ${formatSize(t.uploaded)} (ratio ${t.ratio.toFixed(2)}) - ${t.peers} pairs
+                    ${t.error ? `<br>${escapeHtml(t.error)}` : ''}
+                </span>
+                <span style="margin-left:auto;white-space:nowrap;">
+                    <button type="button" class="btn btn-secondary" onclick="seederTorrentAction('${t.infoHash}', '${t.paused ? 'start' : 'stop'}')">${t.paused ? 'Demarrer' : 'Arreter'}</button>
+                    <button type="button" class="btn btn-secondary" onclick="seederTorrentAction('${t.infoHash}', 'remove')">Retirer</button>
+                </span>
+            </div>
+        `).join('');
+    } catch (e) {
+        container.innerHTML = `<div class="alert alert-danger">Erreur: ${escapeHtml(e.message)}</div>`;
+    }
+}
+
+/**
+ * Démarre, arrête ou retire un torrent du seeder intégré puis rafraîchit la liste
+ * @param {string} infoHash
+ * @param {string} action - start, stop ou remove
+ */
+async function seederTorrentAction(infoHash, action) {
+    if (action === 'remove' && !confirm('Retirer ce torrent du seeder ? Les fichiers ne sont pas supprimes.')) return;
+    try {
+        await ApiClient.seederAction(infoHash, action);
+    } catch (e) {
+        showToast('Erreur: ' + e.message, 'error');
+    }
+    loadSeederTorrents();
+}
+
+/**
+ * Teste la connexion de chaque profil de tracker saisi
+ */
+async function testTrackerProfiles() {
+    try {
+        const profiles = parseJsonSetting('settingTrackerProfiles', 'Profils de trackers');
+        if (profiles.length === 0) {
+            showToast('Aucun profil de tracker', 'info');
+            return;
+        }
+        for (const profile of profiles) {
+            await testConnection(profile.name);
+        }
+    } catch (e) {
+        showToast('Erreur: ' + e.message, 'error');
+    }
+}
+
+/**
+ * Synchronise le catalogue de tags La Cale et affiche les différences
+ */
+async function syncLaCaleTags() {
+    const resultEl = document.getElementById('tagSyncResult');
+    resultEl.innerHTML = '<div class="loading"><div class="spinner"></div>Synchronisation des tags...</div>';
+    try {
+        const report = await ApiClient.syncLaCaleTags();
+        const { added, removed, renamed } = report.diff;
+        const idChanged = report.diff.idChanged || [];
+        const list = (title, changes, format) => changes.length === 0 ? '' :
+            `<div><strong>${title}</strong><br>${changes.map(c => escapeHtml(format(c))).join('<br>')}</div>`;
+        const version = report.version ? `version ${report.version}` : 'catalogue inchange';
+        resultEl.innerHTML = `<div class="alert alert-success">${report.tagCount} tags (${version}) : ${added.length} ajoutes, ${removed.length} supprimes, ${renamed.length} renommes, ${idChanged.length} identifiants changes</div>` +
+            list('Ajoutes', added, c => `${c.characteristic} : ${c.name}`) +
+            list('Supprimes', removed, c => `${c.characteristic} : ${c.name}`) +
+            list('Renommes', renamed, c => `${c.characteristic} : ${c.oldName} -> ${c.name}`) +
+            list('Identifiants changes', idChanged, c => `${c.characteristic} : ${c.name} (${c.oldId} -> ${c.id || 'aucun'})`);
+        // Les tags en cache dans l'interface viennent de l'ancien catalogue
+        window.LaCaleTagCache = null;
+    } catch (e) {
+        resultEl.innerHTML = `<div class="alert alert-danger">Erreur: ${escapeHtml(e.message)}</div>`;
+    }
+}
+
+/**
+ * Affiche pour chaque caractéristique les valeurs lues, les tags candidats et la raison de leur sélection
+ */
+async function explainLaCaleTags() {
+    const resultEl = document.getElementById('tagExplanation');
+    resultEl.innerHTML = '<div class="loading"><div class="spinner"></div>Analyse des tags...</div>';
+    const media = AppState.currentMedia;
+    try {
+        // Même releaseInfo que l'upload, pour expliquer exactement les tags qui seront envoyés
+        const explanation = await ApiClient.explainLaCaleTags({
+            mediaType: media ? media.type : AppState.mediaType,
+            releaseInfo: media ? media.toJSON() : AppState.releaseInfo
+        });
+        const statusLabels = { selected: 'retenu', discarded: 'ecarte', fallback: 'repli', unmatched: 'non retenu', unselectable: 'sans id' };
+        resultEl.innerHTML = explanation.characteristics.map(c => {
+            const candidates = c.candidates
+                .filter(t => t.status !== 'unmatched' || c.values.length > 0)
+                .map(t => `<div><strong>${escapeHtml(t.name)}</strong> : ${statusLabels[t.status] || t.status}` +
+                    (t.value ? ` (${escapeHtml(t.value)})` : '') + ` - ${escapeHtml(t.reason)}</div>`)
+                .join('');
+            return `<details class="tag-category">
+                <summary class="tag-category-title">${escapeHtml(c.characteristic)} : ${c.selected.map(t => escapeHtml(t.name)).join(', ') || 'aucun tag'}${c.fallbackFired ? ' (repli)' : ''}</summary>
+                <div style="font-size: 0.8rem; color: var(--text-muted);">
+                    <div>Valeurs : ${c.values.map(escapeHtml).join(', ') || '-'}${c.note ? ' (' + escapeHtml(c.note) + ')' : ''}</div>
+                    ${c.aliases ? `<div>Alias : ${c.aliases.map(escapeHtml).join(', ')}</div>` : ''}
+                    ${candidates}
+                </div>
+            </details>`;
+        }).join('');
+    } catch (e) {
+        resultEl.innerHTML = `<div class="alert alert-danger">Erreur: ${escapeHtml(e.message)}</div>`;
     }
 }
 
@@ -38,6 +38,8 @@ const AppState = {
     // Fichiers créés
     createdTorrentPath: null,
     createdNfoPath: null,
+    // Contenu réellement seedé (hardlink ou source)
+    contentPath: null,
     
     // Métadonnées génériques
     metadataId: '',
@@ -63,6 +65,9 @@ const AppState = {
     
     // Présentation BBCode (modifiable)
     presentationBBCode: '',
+
+    // Problèmes de seed déjà signalés (ids de releases)
+    notifiedSeedingIssues: new Set(),
     
     /**
      * Réinitialise l'état du workflow
@@ -74,6 +79,7 @@ const AppState = {
         this.torrentName = '';
         this.createdTorrentPath = null;
         this.createdNfoPath = null;
+        this.contentPath = null;
         this.metadataId = '';
         this.metadataData = null;
         this.tmdbId = '';
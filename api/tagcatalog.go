@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tagCatalogPollInterval is how often the watcher looks for a catalog synced by another process
+const tagCatalogPollInterval = time.Minute
+
+// laCaleCatalogPath is the endpoint of the La-Cale internal API serving the category and tag tree
+const laCaleCatalogPath = "/categories"
+
+// TagCatalogVersion is a synced copy of the La-Cale category and tag tree
+type TagCatalogVersion struct {
+	Version  int64           `json:"version"`
+	Tracker  string          `json:"tracker"`
+	TagCount int             `json:"tagCount"`
+	SyncedAt string          `json:"syncedAt"`
+	Diff     *TagCatalogDiff `json:"diff,omitempty"`
+}
+
+// TagCatalogDiff lists the tag changes between two catalogs
+type TagCatalogDiff struct {
+	Added   []TagChange `json:"added"`
+	Removed []TagChange `json:"removed"`
+	Renamed []TagChange `json:"renamed"`
+	// IDChanged are the tags kept under another id (OldID), or without id when ID is empty
+	IDChanged []TagChange `json:"idChanged"`
+}
+
+// TagChange is a tag of a TagCatalogDiff
+type TagChange struct {
+	ID             string `json:"id,omitempty"`
+	OldID          string `json:"oldId,omitempty"`
+	Name           string `json:"name"`
+	OldName        string `json:"oldName,omitempty"`
+	Characteristic string `json:"characteristic"`
+}
+
+// TagSyncReport is the result of a tag sync
+type TagSyncReport struct {
+	// Version is the stored version, 0 for a dry run or when nothing changed
+	Version  int64          `json:"version"`
+	TagCount int            `json:"tagCount"`
+	Changed  bool           `json:"changed"`
+	Diff     TagCatalogDiff `json:"diff"`
+}
+
+// tagCatalog is the La-Cale category and tag tree, parsed once and indexed
+// A catalog is never modified: a reload builds a new one and swaps it in.
+type tagCatalog struct {
+	Meta LocalMetaRoot
+	// Source is "embedded" or "version N" of the tag_catalogs table
+	Source   string
+	Version  int64
+	TagCount int
+	// categories holds the result of findLocalCategory for each media type key (see mediaTypeKey)
+	categories      map[string]catalogCategory
+	characteristics map[string]LocalCharacteristic
+	// categoriesByID holds the tag groups of every category, by id (by slug without id, like findLocalCategory)
+	categoriesByID map[string][]LocalCharacteristic
+	tagsByID       map[string]catalogTag
+	tagsByName     map[string][]catalogTag
+}
+
+// catalogCategory is the La-Cale category of a media type and its tag groups
+type catalogCategory struct {
+	ID              string
+	Characteristics []LocalCharacteristic
+}
+
+// activeTagCatalog is the catalog in use, loaded at startup and swapped by reloadTagCatalog
+var activeTagCatalog atomic.Pointer[tagCatalog]
+
+// reloadTagMu serializes the reloads
+var reloadTagMu sync.Mutex
+
+// mediaTypeKey groups the media types sharing a category in findLocalCategory
+func mediaTypeKey(mediaType string) string {
+	switch mediaType {
+	case "movie", "ebook", "game":
+		return mediaType
+	default:
+		return "series"
+	}
+}
+
+// newTagCatalog indexes a category tree
+func newTagCatalog(meta LocalMetaRoot, source string, version int64) *tagCatalog {
+	c := &tagCatalog{
+		Meta:            meta,
+		Source:          source,
+		Version:         version,
+		categories:      map[string]catalogCategory{},
+		characteristics: map[string]LocalCharacteristic{},
+		categoriesByID:  map[string][]LocalCharacteristic{},
+		tagsByID:        map[string]catalogTag{},
+		tagsByName:      map[string][]catalogTag{},
+	}
+	for _, mediaType := range []string{"movie", "ebook", "game", "series"} {
+		id, chars := findLocalCategory(meta.Categories, mediaType)
+		c.categories[mediaType] = catalogCategory{ID: id, Characteristics: chars}
+	}
+	var walk func(categories []LocalCategory)
+	walk = func(categories []LocalCategory) {
+		for _, cat := range categories {
+			id := cat.ID
+			if id == "" {
+				id = cat.Slug
+			}
+			if _, ok := c.categoriesByID[id]; !ok {
+				c.categoriesByID[id] = cat.Characteristics
+			}
+			for _, char := range cat.Characteristics {
+				// The first characteristic with a slug wins, like the first matching category
+				if _, ok := c.characteristics[char.Slug]; !ok {
+					c.characteristics[char.Slug] = char
+				}
+			}
+			walk(cat.SubCategories)
+		}
+	}
+	walk(meta.Categories)
+	tags := flattenCatalogTags(meta)
+	c.TagCount = len(tags)
+	for _, t := range tags {
+		if t.ID != "" {
+			c.tagsByID[t.ID] = t
+		}
+		name := normalizeTagName(t.Name)
+		c.tagsByName[name] = append(c.tagsByName[name], t)
+	}
+	return c
+}
+
+// Category returns the La-Cale category id of a media type and its tag groups
+func (c *tagCatalog) Category(mediaType string) (string, []LocalCharacteristic) {
+	cat := c.categories[mediaTypeKey(mediaType)]
+	return cat.ID, cat.Characteristics
+}
+
+// CategoryByID returns the tag groups of a La-Cale category id
+func (c *tagCatalog) CategoryByID(id string) ([]LocalCharacteristic, bool) {
+	chars, ok := c.categoriesByID[id]
+	return chars, ok
+}
+
+// Characteristic returns the tag group with this slug
+func (c *tagCatalog) Characteristic(slug string) (LocalCharacteristic, bool) {
+	char, ok := c.characteristics[slug]
+	return char, ok
+}
+
+// Tag returns the tag with this id
+func (c *tagCatalog) Tag(id string) (catalogTag, bool) {
+	t, ok := c.tagsByID[id]
+	return t, ok
+}
+
+// TagsByName returns the tags whose name matches once normalized (see normalizeTagName)
+func (c *tagCatalog) TagsByName(name string) []catalogTag {
+	return c.tagsByName[normalizeTagName(name)]
+}
+
+// TagLookup is a tag of a catalog lookup
+type TagLookup struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Characteristic string `json:"characteristic"`
+}
+
+// Lookup answers /api/lacale/tags/lookup: the tags with an id, a name, in a characteristic (slug) or a media type category
+func (c *tagCatalog) Lookup(query url.Values) ([]TagLookup, error) {
+	var tags []catalogTag
+	switch {
+	case query.Get("id") != "":
+		if t, ok := c.Tag(query.Get("id")); ok {
+			tags = append(tags, t)
+		}
+	case query.Get("name") != "":
+		tags = c.TagsByName(query.Get("name"))
+	case query.Get("characteristic") != "":
+		if char, ok := c.Characteristic(query.Get("characteristic")); ok {
+			for _, t := range char.Tags {
+				tags = append(tags, catalogTag{LocalTag: t, Characteristic: char.Name})
+			}
+		}
+	case query.Get("mediaType") != "":
+		_, chars := c.Category(query.Get("mediaType"))
+		for _, char := range chars {
+			for _, t := range char.Tags {
+				tags = append(tags, catalogTag{LocalTag: t, Characteristic: char.Name})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("one of id, name, characteristic or mediaType is required")
+	}
+	lookup := make([]TagLookup, 0, len(tags))
+	for _, t := range tags {
+		lookup = append(lookup, TagLookup{ID: t.ID, Name: t.Name, Characteristic: t.Characteristic})
+	}
+	return lookup, nil
+}
+
+// currentTagCatalog returns the catalog in use, loading it on first use
+func currentTagCatalog() (*tagCatalog, error) {
+	if c := activeTagCatalog.Load(); c != nil {
+		return c, nil
+	}
+	return reloadTagCatalog()
+}
+
+// reloadTagCatalog loads the latest synced catalog, or the embedded copy, and makes it the catalog in use
+func reloadTagCatalog() (*tagCatalog, error) {
+	reloadTagMu.Lock()
+	defer reloadTagMu.Unlock()
+
+	var meta LocalMetaRoot
+	source := "embedded"
+	data, version, err := latestTagCatalog()
+	if err != nil {
+		logWarn("Tag catalog: %v, using the embedded tags", err)
+	}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &meta); err != nil {
+			logWarn("Tag catalog: version %d is invalid (%v), using the embedded tags", version, err)
+			meta = LocalMetaRoot{}
+		} else {
+			source = fmt.Sprintf("version %d", version)
+		}
+	}
+	if len(meta.Categories) == 0 {
+		version = 0
+		if err := json.Unmarshal([]byte(tagsData), &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse embedded tags data: %w", err)
+		}
+	}
+	c := newTagCatalog(meta, source, version)
+	activeTagCatalog.Store(c)
+	logInfo("Tag catalog: loaded %s (%d tags)", source, c.TagCount)
+	return c, nil
+}
+
+// StartTagCatalogWatcher reloads the catalog when a sync stored a new version from another process (aatm sync-tags)
+func (a *App) StartTagCatalogWatcher() {
+	go func() {
+		ticker := time.NewTicker(tagCatalogPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			var latest int64
+			if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM tag_catalogs").Scan(&latest); err != nil {
+				logWarn("Tag catalog: %v", err)
+				continue
+			}
+			if c := activeTagCatalog.Load(); c == nil || c.Version != latest {
+				if _, err := reloadTagCatalog(); err != nil {
+					logError("Tag catalog: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// latestTagCatalog returns the JSON of the latest synced catalog, empty when there is none
+func latestTagCatalog() (string, int64, error) {
+	if db == nil {
+		return "", 0, nil
+	}
+	var data string
+	var version int64
+	err := db.QueryRow("SELECT version, data FROM tag_catalogs ORDER BY version DESC LIMIT 1").Scan(&version, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil
+	}
+	return data, version, err
+}
+
+// fetchTagCatalog downloads the category and tag tree of the tracker
+// The answer is the tree itself ({"quaiprincipalcategories": [...]}), optionally wrapped in "data", or the bare category list.
+func (t *laCaleTracker) fetchTagCatalog(ctx context.Context) (LocalMetaRoot, error) {
+	var meta LocalMetaRoot
+	resp, err := t.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", t.apiURL+laCaleCatalogPath, nil)
+	})
+	if err != nil {
+		return meta, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return meta, fmt.Errorf("catalog request failed with status %d", resp.StatusCode)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return meta, fmt.Errorf("failed to decode catalog: %w", err)
+	}
+	var wrapped struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(raw, &wrapped) == nil && len(wrapped.Data) > 0 {
+		raw = wrapped.Data
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil || len(meta.Categories) == 0 {
+		if err := json.Unmarshal(raw, &meta.Categories); err != nil {
+			return meta, fmt.Errorf("unexpected catalog format: %w", err)
+		}
+	}
+	if len(meta.Categories) == 0 || len(flattenCatalogTags(meta)) == 0 {
+		return meta, fmt.Errorf("the tracker returned an empty catalog")
+	}
+	return meta, nil
+}
+
+// SyncLaCaleTags fetches the tag catalog of La-Cale and stores it as a new version when it changed
+func (a *App) SyncLaCaleTags(ctx context.Context, dryRun bool) (*TagSyncReport, error) {
+	profile, _ := findTrackerProfile(a.GetSettings(), "lacale")
+	fetched, err := newLaCaleTracker(profile).fetchTagCatalog(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("La-Cale tag sync failed: %w", err)
+	}
+	current, err := currentTagCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffTagCatalogs(current.Meta, fetched)
+	report := &TagSyncReport{
+		TagCount: len(flattenCatalogTags(fetched)),
+		Diff:     diff,
+		Changed:  len(diff.Added)+len(diff.Removed)+len(diff.Renamed)+len(diff.IDChanged) > 0,
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	data, err := json.Marshal(fetched)
+	if err != nil {
+		return nil, err
+	}
+	// An identical tree isn't stored again, but a first sync always replaces the embedded copy
+	latest, _, err := latestTagCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if !report.Changed && latest == string(data) {
+		logInfoCtx(ctx, "SyncLaCaleTags: catalog unchanged (%d tags)", report.TagCount)
+		return report, nil
+	}
+	diffJSON, _ := json.Marshal(diff)
+	res, err := db.Exec("INSERT INTO tag_catalogs (tracker, data, tag_count, diff) VALUES (?, ?, ?, ?)",
+		profile.Name, string(data), report.TagCount, string(diffJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tag catalog: %w", err)
+	}
+	report.Version, _ = res.LastInsertId()
+	if _, err := reloadTagCatalog(); err != nil {
+		return nil, err
+	}
+	logInfoCtx(ctx, "SyncLaCaleTags: stored version %d (%d tags: %d added, %d removed, %d renamed, %d new ids)",
+		report.Version, report.TagCount, len(diff.Added), len(diff.Removed), len(diff.Renamed), len(diff.IDChanged))
+	return report, nil
+}
+
+// runTagSync runs SyncLaCaleTags from the command line and prints its report
+func runTagSync(app *App, dryRun bool) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	report, err := app.SyncLaCaleTags(ctx, dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	return 0
+}
+
+// GetTagCatalogVersions lists the synced tag catalogs, most recent first
+func (a *App) GetTagCatalogVersions() ([]TagCatalogVersion, error) {
+	rows, err := db.Query("SELECT version, tracker, tag_count, COALESCE(diff, ''), synced_at FROM tag_catalogs ORDER BY version DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []TagCatalogVersion{}
+	for rows.Next() {
+		var v TagCatalogVersion
+		var diff string
+		if err := rows.Scan(&v.Version, &v.Tracker, &v.TagCount, &diff, &v.SyncedAt); err != nil {
+			return nil, err
+		}
+		if diff != "" {
+			v.Diff = &TagCatalogDiff{}
+			if err := json.Unmarshal([]byte(diff), v.Diff); err != nil {
+				v.Diff = nil
+			}
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// catalogTag is a tag with the characteristic it belongs to
+type catalogTag struct {
+	LocalTag
+	Characteristic string
+}
+
+// key identifies tags without id by their characteristic and name
+func (t catalogTag) key() string {
+	return t.Characteristic + "/" + t.Name
+}
+
+// flattenCatalogTags lists the tags of every category once
+// The same tag (id) may be offered by several categories, e.g. the languages of movies and series.
+func flattenCatalogTags(meta LocalMetaRoot) []catalogTag {
+	var tags []catalogTag
+	seen := map[string]bool{}
+	var walk func(categories []LocalCategory)
+	walk = func(categories []LocalCategory) {
+		for _, cat := range categories {
+			for _, char := range cat.Characteristics {
+				for _, tag := range char.Tags {
+					t := catalogTag{LocalTag: tag, Characteristic: char.Name}
+					key := "id:" + t.ID
+					if t.ID == "" {
+						key = "name:" + t.key()
+					}
+					if !seen[key] {
+						seen[key] = true
+						tags = append(tags, t)
+					}
+				}
+			}
+			walk(cat.SubCategories)
+		}
+	}
+	walk(meta.Categories)
+	return tags
+}
+
+// diffTagCatalogs reports the tags added, removed, renamed and whose id changed from old to new
+// Tags are matched by id, then by characteristic and name: a tag receiving an id is not a change,
+// but a tag whose id changed or was dropped is, as uploads send the ids.
+func diffTagCatalogs(old, new LocalMetaRoot) TagCatalogDiff {
+	oldTags, newTags := flattenCatalogTags(old), flattenCatalogTags(new)
+	oldByID, oldByKey := indexCatalogTags(oldTags)
+	newByID, _ := indexCatalogTags(newTags)
+
+	diff := TagCatalogDiff{Added: []TagChange{}, Removed: []TagChange{}, Renamed: []TagChange{}, IDChanged: []TagChange{}}
+	// matched holds the old tags paired by name with a new tag
+	matched := map[*catalogTag]bool{}
+	for _, t := range newTags {
+		if o, ok := oldByID[t.ID]; ok && t.ID != "" {
+			if o.Name != t.Name {
+				diff.Renamed = append(diff.Renamed, TagChange{ID: t.ID, Name: t.Name, OldName: o.Name, Characteristic: t.Characteristic})
+			}
+			continue
+		}
+		var same *catalogTag
+		for _, o := range oldByKey[t.key()] {
+			if _, kept := newByID[o.ID]; (o.ID == "" || !kept) && !matched[o] {
+				same = o
+				break
+			}
+		}
+		switch {
+		case same == nil:
+			diff.Added = append(diff.Added, TagChange{ID: t.ID, Name: t.Name, Characteristic: t.Characteristic})
+		case same.ID != "":
+			diff.IDChanged = append(diff.IDChanged, TagChange{ID: t.ID, OldID: same.ID, Name: t.Name, Characteristic: t.Characteristic})
+		}
+		if same != nil {
+			matched[same] = true
+		}
+	}
+	for i := range oldTags {
+		t := &oldTags[i]
+		if _, ok := newByID[t.ID]; (ok && t.ID != "") || matched[t] {
+			continue
+		}
+		diff.Removed = append(diff.Removed, TagChange{ID: t.ID, Name: t.Name, Characteristic: t.Characteristic})
+	}
+	return diff
+}
+
+// indexCatalogTags indexes tags by id and by characteristic and name
+func indexCatalogTags(tags []catalogTag) (map[string]catalogTag, map[string][]*catalogTag) {
+	byID, byKey := map[string]catalogTag{}, map[string][]*catalogTag{}
+	for i, t := range tags {
+		if t.ID != "" {
+			byID[t.ID] = t
+		}
+		byKey[t.key()] = append(byKey[t.key()], &tags[i])
+	}
+	return byID, byKey
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// categoryByUnmarshal is the lookup done before the catalog was indexed: the embedded tree parsed on every call
+func categoryByUnmarshal(mediaType string) (string, []LocalCharacteristic) {
+	var meta LocalMetaRoot
+	if err := json.Unmarshal([]byte(tagsData), &meta); err != nil {
+		panic(err)
+	}
+	return findLocalCategory(meta.Categories, mediaType)
+}
+
+// embeddedTagCatalog parses the embedded tree without touching the catalog in use
+func embeddedTagCatalog(t testing.TB) *tagCatalog {
+	t.Helper()
+	var meta LocalMetaRoot
+	if err := json.Unmarshal([]byte(tagsData), &meta); err != nil {
+		t.Fatal(err)
+	}
+	return newTagCatalog(meta, "embedded", 0)
+}
+
+func BenchmarkCategory(b *testing.B) {
+	b.Run("unmarshal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			categoryByUnmarshal("movie")
+		}
+	})
+	b.Run("catalog", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			catalog, err := currentTagCatalog()
+			if err != nil {
+				b.Fatal(err)
+			}
+			catalog.Category("movie")
+		}
+	})
+}
+
+func TestTagCatalogCategory(t *testing.T) {
+	catalog := embeddedTagCatalog(t)
+	for _, mediaType := range []string{"movie", "episode", "season", "ebook", "game"} {
+		wantID, wantChars := categoryByUnmarshal(mediaType)
+		id, chars := catalog.Category(mediaType)
+		if id != wantID || !reflect.DeepEqual(chars, wantChars) {
+			t.Errorf("Category(%q) = %q (%d groups), want %q (%d groups)", mediaType, id, len(chars), wantID, len(wantChars))
+		}
+	}
+	if chars, ok := catalog.CategoryByID(laCaleFilmsID); !ok || len(chars) == 0 {
+		t.Errorf("CategoryByID(films) = %d groups, %v", len(chars), ok)
+	}
+	// Categories without id are indexed by slug
+	if _, ok := catalog.CategoryByID("bd"); !ok {
+		t.Error("CategoryByID(bd) not found")
+	}
+}
+
+func TestTagCatalogLookup(t *testing.T) {
+	catalog := embeddedTagCatalog(t)
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []TagLookup
+		count int
+	}{
+		{
+			name:  "id",
+			query: url.Values{"id": {laCaleTag1080p}},
+			want:  []TagLookup{{ID: laCaleTag1080p, Name: "1080p (Full HD)", Characteristic: "Qualité / Résolution"}},
+		},
+		{name: "unknown id", query: url.Values{"id": {"nope"}}, want: []TagLookup{}},
+		{
+			// Names are compared without accents, case and separators
+			name:  "name",
+			query: url.Values{"name": {"comedie"}},
+			want:  []TagLookup{{ID: "cmjudwi0v000juyrujjoqy8ya", Name: "Comédie", Characteristic: "Genres"}},
+		},
+		{name: "characteristic slug", query: url.Values{"characteristic": {"qualit-r-solution"}}, count: 5},
+		{name: "unknown slug", query: url.Values{"characteristic": {"nope"}}, want: []TagLookup{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := catalog.Lookup(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%v) = %+v, want %+v", tt.query, got, tt.want)
+			}
+			if tt.count > 0 && len(got) != tt.count {
+				t.Errorf("Lookup(%v) = %d tags, want %d", tt.query, len(got), tt.count)
+			}
+		})
+	}
+	if _, err := catalog.Lookup(url.Values{}); err == nil {
+		t.Error("Lookup without criteria succeeded")
+	}
+}
+
+func TestTagCatalogTagLookups(t *testing.T) {
+	catalog := embeddedTagCatalog(t)
+	tag, ok := catalog.Tag(laCaleTagWEBDL)
+	if !ok || tag.Name != "WEB-DL" || tag.Characteristic != "Source / Type" {
+		t.Errorf("Tag(WEB-DL) = %+v, %v", tag, ok)
+	}
+	// A tag offered by movies and series is indexed once, next to the tags without id of the same name
+	withID := 0
+	for _, tag := range catalog.TagsByName("WEB DL") {
+		if tag.ID == laCaleTagWEBDL {
+			withID++
+		}
+	}
+	if withID != 1 {
+		t.Errorf("TagsByName(WEB DL) = %+v", catalog.TagsByName("WEB DL"))
+	}
+	if char, ok := catalog.Characteristic("source-type"); !ok || char.Name != "Source / Type" {
+		t.Errorf("Characteristic(source-type) = %+v, %v", char, ok)
+	}
+}
+
+func TestReloadTagCatalogSwap(t *testing.T) {
+	initTestDB(t)
+	previous := activeTagCatalog.Load()
+	t.Cleanup(func() { activeTagCatalog.Store(previous) })
+
+	before, err := reloadTagCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Source != "embedded" {
+		t.Fatalf("Source = %q without a synced catalog", before.Source)
+	}
+
+	// A synced version renaming a tag replaces the catalog in use
+	meta := before.Meta
+	data, _ := json.Marshal(meta)
+	var synced LocalMetaRoot
+	json.Unmarshal(data, &synced)
+	renameCatalogTag(synced.Categories, laCaleTagWEBDL, "WEB-DL (renamed)")
+	data, _ = json.Marshal(synced)
+	if _, err := db.Exec("INSERT INTO tag_catalogs (tracker, data, tag_count) VALUES ('lacale', ?, 1)", string(data)); err != nil {
+		t.Fatal(err)
+	}
+	after, err := reloadTagCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := currentTagCatalog()
+	if current != after || after.Source != "version 1" || after.Version != 1 {
+		t.Fatalf("current = %s (version %d), want the reloaded version 1", current.Source, current.Version)
+	}
+	if tag, _ := after.Tag(laCaleTagWEBDL); tag.Name != "WEB-DL (renamed)" {
+		t.Errorf("reloaded tag = %q", tag.Name)
+	}
+	if tags := after.TagsByName("WEB-DL (renamed)"); len(tags) != 1 {
+		t.Errorf("renamed tag not indexed by name: %+v", tags)
+	}
+	// A catalog is never modified: readers holding the previous one keep a consistent copy
+	if tag, _ := before.Tag(laCaleTagWEBDL); tag.Name != "WEB-DL" {
+		t.Errorf("previous catalog changed: %q", tag.Name)
+	}
+
+	// An invalid version falls back to the embedded tags
+	if _, err := db.Exec("INSERT INTO tag_catalogs (tracker, data, tag_count) VALUES ('lacale', '{', 0)"); err != nil {
+		t.Fatal(err)
+	}
+	fallback, err := reloadTagCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback.Source != "embedded" || fallback.TagCount != before.TagCount {
+		t.Errorf("fallback = %s (%d tags), want the embedded catalog", fallback.Source, fallback.TagCount)
+	}
+}
+
+// renameCatalogTag renames the tag with this id in every category
+func renameCatalogTag(categories []LocalCategory, id, name string) {
+	for _, cat := range categories {
+		for _, char := range cat.Characteristics {
+			for i := range char.Tags {
+				if char.Tags[i].ID == id {
+					char.Tags[i].Name = name
+				}
+			}
+		}
+		renameCatalogTag(cat.SubCategories, id, name)
+	}
+}
+
+// testTagCatalog builds a one-category catalog from tags by characteristic
+func testTagCatalog(chars map[string][]LocalTag) LocalMetaRoot {
+	cat := LocalCategory{Name: "Films", ID: "films"}
+	for name, tags := range chars {
+		cat.Characteristics = append(cat.Characteristics, LocalCharacteristic{Name: name, Tags: tags})
+	}
+	return LocalMetaRoot{Categories: []LocalCategory{cat}}
+}
+
+func TestDiffTagCatalogs(t *testing.T) {
+	base := map[string][]LocalTag{
+		"Qualite": {{ID: "q1", Name: "1080p"}, {ID: "q2", Name: "2160p"}},
+		"Langue":  {{ID: "l1", Name: "French"}},
+	}
+	tests := []struct {
+		name     string
+		old, new map[string][]LocalTag
+		want     TagCatalogDiff
+	}{
+		{name: "unchanged", old: base, new: base},
+		{
+			name: "added",
+			old:  base,
+			new: map[string][]LocalTag{
+				"Qualite": {{ID: "q1", Name: "1080p"}, {ID: "q2", Name: "2160p"}, {ID: "q3", Name: "720p"}},
+				"Langue":  {{ID: "l1", Name: "French"}},
+			},
+			want: TagCatalogDiff{Added: []TagChange{{ID: "q3", Name: "720p", Characteristic: "Qualite"}}},
+		},
+		{
+			name: "removed",
+			old:  base,
+			new:  map[string][]LocalTag{"Qualite": {{ID: "q1", Name: "1080p"}}, "Langue": {{ID: "l1", Name: "French"}}},
+			want: TagCatalogDiff{Removed: []TagChange{{ID: "q2", Name: "2160p", Characteristic: "Qualite"}}},
+		},
+		{
+			name: "renamed",
+			old:  base,
+			new: map[string][]LocalTag{
+				"Qualite": {{ID: "q1", Name: "1080p"}, {ID: "q2", Name: "4K"}},
+				"Langue":  {{ID: "l1", Name: "French"}},
+			},
+			want: TagCatalogDiff{Renamed: []TagChange{{ID: "q2", Name: "4K", OldName: "2160p", Characteristic: "Qualite"}}},
+		},
+		{
+			name: "id assigned",
+			old:  map[string][]LocalTag{"Langue": {{Name: "French"}, {Name: "English"}}},
+			new:  map[string][]LocalTag{"Langue": {{ID: "l1", Name: "French"}, {Name: "English"}}},
+		},
+		{
+			name: "id changed",
+			old:  base,
+			new: map[string][]LocalTag{
+				"Qualite": {{ID: "q1", Name: "1080p"}, {ID: "q9", Name: "2160p"}},
+				"Langue":  {{ID: "l1", Name: "French"}},
+			},
+			want: TagCatalogDiff{IDChanged: []TagChange{{ID: "q9", OldID: "q2", Name: "2160p", Characteristic: "Qualite"}}},
+		},
+		{
+			name: "id dropped",
+			old:  base,
+			new: map[string][]LocalTag{
+				"Qualite": {{ID: "q1", Name: "1080p"}, {ID: "q2", Name: "2160p"}},
+				"Langue":  {{Name: "French"}},
+			},
+			want: TagCatalogDiff{IDChanged: []TagChange{{OldID: "l1", Name: "French", Characteristic: "Langue"}}},
+		},
+		{
+			name: "one of two homonyms changes id",
+			old:  map[string][]LocalTag{"Langue": {{ID: "l1", Name: "Multi"}, {ID: "l2", Name: "Multi"}}},
+			new:  map[string][]LocalTag{"Langue": {{ID: "l1", Name: "Multi"}, {ID: "l3", Name: "Multi"}, {ID: "l4", Name: "Multi"}}},
+			want: TagCatalogDiff{
+				Added:     []TagChange{{ID: "l4", Name: "Multi", Characteristic: "Langue"}},
+				IDChanged: []TagChange{{ID: "l3", OldID: "l2", Name: "Multi", Characteristic: "Langue"}},
+			},
+		},
+		{
+			// Neither the id nor the name matches: another tag
+			name: "renamed under a new id",
+			old:  map[string][]LocalTag{"Qualite": {{ID: "q2", Name: "2160p"}}},
+			new:  map[string][]LocalTag{"Qualite": {{ID: "q9", Name: "4K"}}},
+			want: TagCatalogDiff{
+				Added:   []TagChange{{ID: "q9", Name: "4K", Characteristic: "Qualite"}},
+				Removed: []TagChange{{ID: "q2", Name: "2160p", Characteristic: "Qualite"}},
+			},
+		},
+		{
+			name: "moved to another characteristic",
+			old:  map[string][]LocalTag{"Source": {{ID: "s1", Name: "WEB"}}},
+			new:  map[string][]LocalTag{"Type": {{ID: "t1", Name: "WEB"}}},
+			want: TagCatalogDiff{
+				Added:   []TagChange{{ID: "t1", Name: "WEB", Characteristic: "Type"}},
+				Removed: []TagChange{{ID: "s1", Name: "WEB", Characteristic: "Source"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, list := range []*[]TagChange{&tt.want.Added, &tt.want.Removed, &tt.want.Renamed, &tt.want.IDChanged} {
+				if *list == nil {
+					*list = []TagChange{}
+				}
+			}
+			got := diffTagCatalogs(testTagCatalog(tt.old), testTagCatalog(tt.new))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchTagCatalog(t *testing.T) {
+	tree := `{"quaiprincipalcategories": [{"name": "Films", "id": "films", "caracteristiques": [{"name": "Qualite", "tags": [{"id": "q1", "name": "1080p"}]}]}]}`
+	list := `[{"name": "Films", "id": "films", "caracteristiques": [{"name": "Qualite", "tags": [{"id": "q1", "name": "1080p"}]}]}]`
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		ok     bool
+	}{
+		{"tree", http.StatusOK, tree, true},
+		{"wrapped tree", http.StatusOK, `{"success": true, "data": ` + tree + `}`, true},
+		{"category list", http.StatusOK, list, true},
+		{"wrapped category list", http.StatusOK, `{"data": ` + list + `}`, true},
+		{"empty", http.StatusOK, `{"quaiprincipalcategories": []}`, false},
+		{"no tags", http.StatusOK, `[{"name": "Films", "id": "films"}]`, false},
+		{"other format", http.StatusOK, `{"data": "maintenance"}`, false},
+		{"error status", http.StatusInternalServerError, `{}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestDB(t)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/internal/auth/login":
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+					fmt.Fprint(w, `{"success": true}`)
+				case "/api/internal" + laCaleCatalogPath:
+					if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, tt.body)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			meta, err := newTestLaCaleTracker(t, srv, "secret").fetchTagCatalog(context.Background())
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			tags := flattenCatalogTags(meta)
+			if len(meta.Categories) != 1 || len(tags) != 1 || tags[0].ID != "q1" || tags[0].Characteristic != "Qualite" {
+				t.Errorf("catalog = %+v", meta)
+			}
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTagRules are the tag matching rules shipped with the binary
+//
+//go:embed tag_rules.json
+var defaultTagRules []byte
+
+// TagRuleSet holds the tag matching rules of each tracker type
+type TagRuleSet struct {
+	// Dictionaries are named alias lists shared by the rules (codecs, audio, sources, hdr, languages, genres)
+	// A rule file without a dictionary gets the embedded one.
+	Dictionaries map[string]map[string][]string `json:"dictionaries,omitempty"`
+	Trackers     map[string]TrackerTagRules     `json:"trackers"`
+}
+
+// TrackerTagRules are the rules of the characteristics (tag groups) of a tracker
+type TrackerTagRules struct {
+	// Characteristics are tried in order, the first one with a slug contained in the characteristic slug applies
+	Characteristics []CharacteristicRule `json:"characteristics"`
+}
+
+// CharacteristicRule selects the tags of a characteristic from release fields
+type CharacteristicRule struct {
+	Slugs []string `json:"slugs"`
+	// Fields are ReleaseInfo fields: genres, resolution, codec, audioCodecs, audioLanguages, subtitleLanguages, container, source, hdr, tags, releaseGroup
+	Fields []string `json:"fields"`
+	// Exclusive keeps only the matched tag with the highest priority, and uploads accept one tag at most
+	Exclusive bool `json:"exclusive,omitempty"`
+	// Required makes uploads without a tag of the characteristic invalid
+	Required bool `json:"required,omitempty"`
+	// Fallback is the start of the name of the tag selected when the fields have values but no tag matched ("Autre")
+	Fallback string `json:"fallback,omitempty"`
+	// Dictionaries are the alias dictionaries the rule uses
+	Dictionaries []string `json:"dictionaries,omitempty"`
+	// Aliases add values to match for a value ("comedy" -> "Comédie"), after those of the dictionaries
+	Aliases map[string][]string `json:"aliases,omitempty"`
+	// Tags give patterns and priorities to tags; the other tags match values equal to their name
+	Tags []TagRule `json:"tags,omitempty"`
+
+	aliases map[string][]string
+	tags    map[string]*TagRule
+}
+
+// TagRule matches a tag (by name or id) with case-insensitive regular expressions on the release values
+type TagRule struct {
+	Tag      string   `json:"tag"`
+	Patterns []string `json:"patterns,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+
+	patterns []*regexp.Regexp
+}
+
+// tagRuleFields are the release fields the rules can use
+var tagRuleFields = map[string]bool{
+	"genres": true, "resolution": true, "codec": true, "audioCodecs": true, "audioLanguages": true,
+	"subtitleLanguages": true, "container": true, "source": true, "hdr": true, "tags": true, "releaseGroup": true,
+}
+
+// activeTagRules are the rules in use, swapped by reloadTagRules
+var activeTagRules atomic.Pointer[TagRuleSet]
+
+var reloadTagRulesMu sync.Mutex
+
+// tagRulesFile returns the rule file overriding the embedded rules: AATM_TAG_RULES_FILE,
+// or tag_rules.yaml / tag_rules.yml / tag_rules.json in the data directory
+func tagRulesFile() string {
+	if path := os.Getenv("AATM_TAG_RULES_FILE"); path != "" {
+		return path
+	}
+	for _, name := range []string{"tag_rules.yaml", "tag_rules.yml", "tag_rules.json"} {
+		path := filepath.Join(dataDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// currentTagRules returns the rules in use, loading them on first use
+func currentTagRules() (*TagRuleSet, error) {
+	if rules := activeTagRules.Load(); rules != nil {
+		return rules, nil
+	}
+	return reloadTagRules()
+}
+
+// reloadTagRules loads the rule file, or the embedded rules, and makes them the rules in use
+// An invalid rule file is reported and the rules in use are kept (the embedded ones on first load)
+func reloadTagRules() (*TagRuleSet, error) {
+	reloadTagRulesMu.Lock()
+	defer reloadTagRulesMu.Unlock()
+
+	var embedded TagRuleSet
+	if err := json.Unmarshal(defaultTagRules, &embedded); err != nil {
+		return nil, fmt.Errorf("invalid embedded tag rules: %w", err)
+	}
+
+	var rules TagRuleSet
+	var loadErr error
+	source := "embedded"
+	if path := tagRulesFile(); path != "" {
+		source = path
+		data, err := os.ReadFile(path)
+		if err == nil {
+			// YAML is a superset of JSON: both rule files are read the same way
+			err = yaml.Unmarshal(data, &rules)
+		}
+		if err == nil {
+			if rules.Dictionaries == nil {
+				rules.Dictionaries = map[string]map[string][]string{}
+			}
+			for name, dictionary := range embedded.Dictionaries {
+				if _, ok := rules.Dictionaries[name]; !ok {
+					rules.Dictionaries[name] = dictionary
+				}
+			}
+			err = rules.compile()
+		}
+		if err != nil {
+			loadErr = fmt.Errorf("invalid tag rules file %s: %w", path, err)
+		}
+	}
+	if loadErr != nil || source == "embedded" {
+		if current := activeTagRules.Load(); loadErr != nil && current != nil {
+			return current, loadErr
+		}
+		rules = embedded
+		if err := rules.compile(); err != nil {
+			return nil, fmt.Errorf("invalid embedded tag rules: %w", err)
+		}
+		source = "embedded"
+	}
+	activeTagRules.Store(&rules)
+	logInfo("Tag rules: loaded %s", source)
+	return &rules, loadErr
+}
+
+// compile checks the rules and builds their indexes
+func (s *TagRuleSet) compile() error {
+	for tracker, trackerRules := range s.Trackers {
+		for i := range trackerRules.Characteristics {
+			rule := &trackerRules.Characteristics[i]
+			label := fmt.Sprintf("%s rule %d", tracker, i+1)
+			if len(rule.Slugs) == 0 {
+				return fmt.Errorf("%s: slugs are required", label)
+			}
+			for _, field := range rule.Fields {
+				if !tagRuleFields[field] {
+					return fmt.Errorf("%s: unknown field %q", label, field)
+				}
+			}
+			rule.aliases = map[string][]string{}
+			// Values are added in sorted order and once, since several spellings share a normalized key ("web dl", "webdl")
+			addAliases := func(values map[string][]string) {
+				keys := make([]string, 0, len(values))
+				for value := range values {
+					keys = append(keys, value)
+				}
+				sort.Strings(keys)
+				for _, value := range keys {
+					key := normalizeTagName(value)
+					for _, alias := range values[value] {
+						if !slices.Contains(rule.aliases[key], alias) {
+							rule.aliases[key] = append(rule.aliases[key], alias)
+						}
+					}
+				}
+			}
+			for _, name := range rule.Dictionaries {
+				dictionary, ok := s.Dictionaries[name]
+				if !ok {
+					return fmt.Errorf("%s: unknown dictionary %q", label, name)
+				}
+				addAliases(dictionary)
+			}
+			addAliases(rule.Aliases)
+			rule.tags = map[string]*TagRule{}
+			for j := range rule.Tags {
+				tagRule := &rule.Tags[j]
+				for _, pattern := range tagRule.Patterns {
+					re, err := regexp.Compile("(?i)" + pattern)
+					if err != nil {
+						return fmt.Errorf("%s, tag %q: %w", label, tagRule.Tag, err)
+					}
+					tagRule.patterns = append(tagRule.patterns, re)
+				}
+				rule.tags[normalizeTagName(tagRule.Tag)] = tagRule
+			}
+		}
+	}
+	return nil
+}
+
+// forCharacteristic returns the rule of a characteristic slug, nil when none applies
+func (r TrackerTagRules) forCharacteristic(slug string) *CharacteristicRule {
+	slug = strings.ToLower(slug)
+	for i := range r.Characteristics {
+		for _, s := range r.Characteristics[i].Slugs {
+			if strings.Contains(slug, strings.ToLower(s)) {
+				return &r.Characteristics[i]
+			}
+		}
+	}
+	return nil
+}
+
+// tagRule returns the rule of a tag, by id or name
+func (r *CharacteristicRule) tagRule(tag LocalTag) *TagRule {
+	if tr, ok := r.tags[normalizeTagName(tag.ID)]; ok && tag.ID != "" {
+		return tr
+	}
+	return r.tags[normalizeTagName(tag.Name)]
+}
+
+// tagRuleValues returns the values of a release field, after the enhance*Detection functions
+func tagRuleValues(info ReleaseInfo, field string) []string {
+	switch field {
+	case "genres":
+		return info.Genres
+	case "resolution":
+		return []string{info.Resolution}
+	case "codec":
+		return []string{info.Codec}
+	case "audioCodecs":
+		values := info.AudioCodecs
+		if len(values) == 0 && info.Audio != "" {
+			values = []string{info.Audio}
+		}
+		// Atmos, DTS:X
+		return enhanceAudioCodecDetection(values, info.ReleaseGroup, info.Tags)
+	case "audioLanguages":
+		values := info.AudioLanguages
+		if len(values) == 0 && info.Language != "" {
+			values = []string{info.Language}
+		}
+		// VFF, VFQ, MULTi
+		return enhanceLanguageDetection(values, info.ReleaseGroup, info.Tags)
+	case "subtitleLanguages":
+		return info.SubtitleLanguages
+	case "container":
+		return []string{info.Container}
+	case "source":
+		return enhanceSourceDetection(info.Source, info.ReleaseGroup, info.Tags)
+	case "hdr":
+		// 10 bits from the codec
+		return enhanceCharacteristics(info.Hdr, info.Codec)
+	case "tags":
+		return info.Tags
+	case "releaseGroup":
+		return []string{info.ReleaseGroup}
+	}
+	return nil
+}
+
+// TagExplanation tells which tags were selected for a release and why
+type TagExplanation struct {
+	Tracker         string                      `json:"tracker"`
+	CategoryID      string                      `json:"categoryId"`
+	Characteristics []CharacteristicExplanation `json:"characteristics"`
+}
+
+// CharacteristicExplanation is the tag selection of a characteristic
+type CharacteristicExplanation struct {
+	Characteristic string `json:"characteristic"`
+	Slug           string `json:"slug"`
+	// Rule is the first slug of the rule applied, empty when no rule applies
+	Rule   string   `json:"rule,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	// Values are the values of the fields, after the enhance*Detection functions
+	Values []string `json:"values"`
+	// Aliases are the values added by the dictionaries and aliases of the rule ("comedy -> Comédie")
+	Aliases   []string `json:"aliases,omitempty"`
+	Exclusive bool     `json:"exclusive,omitempty"`
+	// Note tells why no tag was looked for (no rule, no value)
+	Note          string           `json:"note,omitempty"`
+	Selected      []TagMatchReason `json:"selected"`
+	Discarded     []TagMatchReason `json:"discarded,omitempty"`
+	FallbackFired bool             `json:"fallbackFired"`
+	// Candidates are all the tags of the characteristic, in catalog order, with their status
+	Candidates []TagMatchReason `json:"candidates"`
+
+	rule   *CharacteristicRule
+	values []tagRuleMatchTarget
+}
+
+// TagMatchReason is a tag and the reason it was selected or not
+type TagMatchReason struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	// Value is the release value that matched ("comedy -> Comédie" through an alias)
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+	// Status is selected, discarded, fallback, unmatched or unselectable (tag without id)
+	Status string `json:"status"`
+}
+
+// tagRuleMatchTarget is a value to match, with the release value it comes from
+type tagRuleMatchTarget struct {
+	value string
+	label string
+}
+
+// explainTags runs the rules of a tracker on the characteristics of a category
+func explainTags(tracker string, characteristics []LocalCharacteristic, info ReleaseInfo) ([]CharacteristicExplanation, error) {
+	rules, err := currentTagRules()
+	if err != nil && rules == nil {
+		return nil, err
+	}
+	trackerRules := rules.Trackers[tracker]
+
+	explanations := make([]CharacteristicExplanation, 0, len(characteristics))
+	for _, char := range characteristics {
+		e := CharacteristicExplanation{Characteristic: char.Name, Slug: char.Slug, Values: []string{},
+			Selected: []TagMatchReason{}, Candidates: []TagMatchReason{}}
+		e.rule = trackerRules.forCharacteristic(char.Slug)
+		if e.rule == nil {
+			e.Note = "no rule for this characteristic"
+		} else {
+			e.Rule, e.Fields, e.Exclusive = e.rule.Slugs[0], e.rule.Fields, e.rule.Exclusive
+			e.collectValues(info)
+			if len(e.values) == 0 {
+				e.Note = "no value in " + strings.Join(e.Fields, ", ")
+			}
+		}
+		e.matchTags(char)
+		explanations = append(explanations, e)
+	}
+	return explanations, nil
+}
+
+// collectValues gathers the non-empty values of the rule fields and their aliases
+func (e *CharacteristicExplanation) collectValues(info ReleaseInfo) {
+	seen := map[string]bool{}
+	for _, field := range e.rule.Fields {
+		for _, v := range tagRuleValues(info, field) {
+			key := normalizeTagName(v)
+			if v == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			e.Values = append(e.Values, v)
+			e.values = append(e.values, tagRuleMatchTarget{value: v, label: v})
+			for _, alias := range e.rule.aliases[key] {
+				if normalizeTagName(alias) == key {
+					continue
+				}
+				label := v + " -> " + alias
+				e.Aliases = append(e.Aliases, label)
+				e.values = append(e.values, tagRuleMatchTarget{value: alias, label: label})
+			}
+		}
+	}
+}
+
+// matchTags selects the tags of the characteristic matching the values and lists every tag as a candidate
+func (e *CharacteristicExplanation) matchTags(char LocalCharacteristic) {
+	var matched []int
+	for _, tag := range char.Tags {
+		m := TagMatchReason{ID: tag.ID, Name: tag.Name, Status: "unmatched"}
+		switch {
+		case tag.ID == "":
+			m.Status, m.Reason = "unselectable", "no id on the tracker"
+		case len(e.values) == 0:
+			m.Reason = e.Note
+		default:
+			var ok bool
+			m, ok = e.rule.match(tag, e.values)
+			m.Status = "unmatched"
+			if ok {
+				m.Status = "selected"
+				matched = append(matched, len(e.Candidates))
+			}
+		}
+		e.Candidates = append(e.Candidates, m)
+	}
+
+	if e.Exclusive && len(matched) > 1 {
+		// Highest priority first, catalog order between equal priorities
+		sort.SliceStable(matched, func(i, j int) bool {
+			return e.Candidates[matched[i]].Priority > e.Candidates[matched[j]].Priority
+		})
+		winner := e.Candidates[matched[0]].Name
+		for _, i := range matched[1:] {
+			m := &e.Candidates[i]
+			m.Status = "discarded"
+			m.Reason += fmt.Sprintf(" (discarded: exclusive characteristic, %s has a higher priority)", winner)
+			e.Discarded = append(e.Discarded, *m)
+		}
+		matched = matched[:1]
+	}
+	for _, i := range matched {
+		e.Selected = append(e.Selected, e.Candidates[i])
+	}
+
+	if len(matched) == 0 && len(e.values) > 0 && e.rule.Fallback != "" {
+		prefix := normalizeTagName(e.rule.Fallback)
+		for i, tag := range char.Tags {
+			if tag.ID != "" && strings.HasPrefix(normalizeTagName(tag.Name), prefix) {
+				m := &e.Candidates[i]
+				m.Status = "fallback"
+				m.Reason = fmt.Sprintf("fallback: no tag matched %s", strings.Join(e.Values, ", "))
+				e.Selected = append(e.Selected, *m)
+				e.FallbackFired = true
+				break
+			}
+		}
+	}
+}
+
+// match tells whether a tag matches one of the values, with its patterns or by name
+func (r *CharacteristicRule) match(tag LocalTag, values []tagRuleMatchTarget) (TagMatchReason, bool) {
+	m := TagMatchReason{ID: tag.ID, Name: tag.Name}
+	tr := r.tagRule(tag)
+	if tr != nil {
+		m.Priority = tr.Priority
+	}
+	for _, v := range values {
+		if tr != nil && len(tr.patterns) > 0 {
+			for _, re := range tr.patterns {
+				if re.MatchString(v.value) {
+					m.Value, m.Reason = v.label, "pattern "+strings.TrimPrefix(re.String(), "(?i)")
+					return m, true
+				}
+			}
+			continue
+		}
+		if tagNameMatches(tag.Name, v.value) {
+			m.Value, m.Reason = v.label, "name"
+			return m, true
+		}
+	}
+	if tr != nil && len(tr.patterns) > 0 {
+		m.Reason = "no value matched its patterns"
+	} else {
+		m.Reason = "no value names it"
+	}
+	return m, false
+}
+
+// tagNameMatches tells whether a value names a tag: same normalized name, or the words of the value in sequence
+// among the words of the tag ("Thriller" names "Policier / Thriller", "x264" names "AVC/H264/x264", "HD" doesn't name "UHD")
+func tagNameMatches(name, value string) bool {
+	v := normalizeTagName(value)
+	if v == "" {
+		return false
+	}
+	if normalizeTagName(name) == v {
+		return true
+	}
+	words, run := tagTokens(name), tagTokens(value)
+	if len(run) == 0 {
+		return false
+	}
+	for i := 0; i+len(run) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(run)], run) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchedTags returns the selected tags of an explanation, each tag once
+func matchedTags(explanations []CharacteristicExplanation) []TagMatchReason {
+	var tags []TagMatchReason
+	seen := map[string]bool{}
+	for _, e := range explanations {
+		for _, m := range e.Selected {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				tags = append(tags, m)
+			}
+		}
+	}
+	return tags
+}
+
+// ExplainLaCaleTags returns the tags selected for a release on La-Cale, with the reason of each selection
+func (a *App) ExplainLaCaleTags(mediaType string, info ReleaseInfo) (*TagExplanation, error) {
+	catalog, err := currentTagCatalog()
+	if err != nil {
+		return nil, err
+	}
+	categoryID, chars := catalog.Category(mediaType)
+	explanations, err := explainTags("lacale", chars, info)
+	if err != nil {
+		return nil, err
+	}
+	return &TagExplanation{Tracker: "lacale", CategoryID: categoryID, Characteristics: explanations}, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// explainTagsCase is a testdata/explain_tags input
+type explainTagsCase struct {
+	MediaType   string      `json:"mediaType"`
+	ReleaseInfo ReleaseInfo `json:"releaseInfo"`
+}
+
+// explainTagsGolden is the content of a golden file: the selected tag ids and the explanation of each characteristic
+type explainTagsGolden struct {
+	TagIDs          []string               `json:"tagIds"`
+	Characteristics []goldenCharacteristic `json:"characteristics"`
+}
+
+// goldenCharacteristic leaves out the candidates, which list every tag of the catalog
+type goldenCharacteristic struct {
+	CharacteristicExplanation
+	Candidates []TagMatchReason `json:"candidates,omitempty"`
+}
+
+func TestExplainTagsGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "explain_tags", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := embeddedTagCatalog(t)
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tc explainTagsCase
+			if err := json.Unmarshal(data, &tc); err != nil {
+				t.Fatal(err)
+			}
+			_, chars := catalog.Category(tc.MediaType)
+			explanations, err := explainTags("lacale", chars, tc.ReleaseInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := explainTagsGolden{TagIDs: []string{}}
+			for _, m := range matchedTags(explanations) {
+				out.TagIDs = append(out.TagIDs, m.ID)
+			}
+			for _, e := range explanations {
+				out.Characteristics = append(out.Characteristics, goldenCharacteristic{CharacteristicExplanation: e})
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(out); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.Bytes()
+
+			golden := strings.TrimSuffix(input, ".json") + ".golden.json"
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -run TestExplainTagsGolden -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("explanation differs from %s (run go test -run TestExplainTagsGolden -update and review the diff):\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// selectedTagNames returns the selected tags of a characteristic slug, and whether the fallback fired
+func selectedTagNames(t *testing.T, explanations []CharacteristicExplanation, slug string) ([]string, bool) {
+	t.Helper()
+	for _, e := range explanations {
+		if e.Slug == slug {
+			var names []string
+			for _, m := range e.Selected {
+				names = append(names, m.Name)
+			}
+			return names, e.FallbackFired
+		}
+	}
+	t.Fatalf("no characteristic %s", slug)
+	return nil, false
+}
+
+func TestExplainTagsSourcePriority(t *testing.T) {
+	_, chars := embeddedTagCatalog(t).Category("movie")
+	tests := []struct {
+		name      string
+		info      ReleaseInfo
+		want      string
+		discarded int
+	}{
+		{"full disc", ReleaseInfo{Source: "BluRay", Tags: []string{"COMPLETE DISC", "REMUX"}}, "FULL Disc", 2},
+		{"remux", ReleaseInfo{Source: "BluRay", Tags: []string{"REMUX"}}, "REMUX", 1},
+		{"bluray", ReleaseInfo{Source: "BluRay"}, "BluRay", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanations, err := explainTags("lacale", chars, tt.info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names, _ := selectedTagNames(t, explanations, "source-type")
+			if len(names) != 1 || names[0] != tt.want {
+				t.Errorf("selected = %v, want %s", names, tt.want)
+			}
+			for _, e := range explanations {
+				if e.Slug == "source-type" && len(e.Discarded) != tt.discarded {
+					t.Errorf("discarded = %+v, want %d tags", e.Discarded, tt.discarded)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainTagsFallback(t *testing.T) {
+	_, chars := embeddedTagCatalog(t).Category("movie")
+	explanations, err := explainTags("lacale", chars, ReleaseInfo{AudioCodecs: []string{"Vorbis"}, AudioLanguages: []string{"Swedish"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for slug, want := range map[string]string{"codec-audio": "Autres", "langues-audio": "Autre Langue"} {
+		names, fired := selectedTagNames(t, explanations, slug)
+		if !fired || len(names) != 1 || names[0] != want {
+			t.Errorf("%s: selected = %v (fallback %v), want %s", slug, names, fired, want)
+		}
+	}
+	// Without any value there is nothing to fall back from
+	if names, fired := selectedTagNames(t, explanations, "extension"); fired || len(names) != 0 {
+		t.Errorf("extension: selected = %v (fallback %v) without a container", names, fired)
+	}
+}
+
+func TestReloadTagRulesRejectsInvalidFile(t *testing.T) {
+	previous := activeTagRules.Load()
+	t.Cleanup(func() { activeTagRules.Store(previous) })
+	t.Setenv("AATM_TAG_RULES_FILE", "")
+	current, err := reloadTagRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"yaml":       "trackers: [",
+		"field":      `{"trackers": {"lacale": {"characteristics": [{"slugs": ["genre"], "fields": ["plot"]}]}}}`,
+		"pattern":    `{"trackers": {"lacale": {"characteristics": [{"slugs": ["source"], "tags": [{"tag": "REMUX", "patterns": ["(remux"]}]}]}}}`,
+		"dictionary": `{"trackers": {"lacale": {"characteristics": [{"slugs": ["genre"], "dictionaries": ["nope"]}]}}}`,
+		"slugs":      `{"trackers": {"lacale": {"characteristics": [{"fields": ["genres"]}]}}}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tag_rules.yaml")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("AATM_TAG_RULES_FILE", path)
+			rules, err := reloadTagRules()
+			if err == nil || !strings.Contains(err.Error(), path) {
+				t.Errorf("err = %v, want an error naming %s", err, path)
+			}
+			// The rules in use are kept
+			if rules != current || activeTagRules.Load() != current {
+				t.Error("an invalid rule file replaced the rules in use")
+			}
+		})
+	}
+
+	// A valid file replaces them, with the embedded dictionaries it doesn't define
+	path := filepath.Join(t.TempDir(), "tag_rules.yaml")
+	valid := "trackers:\n  lacale:\n    characteristics:\n      - slugs: [genre]\n        fields: [genres]\n        dictionaries: [genres]\n"
+	if err := os.WriteFile(path, []byte(valid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AATM_TAG_RULES_FILE", path)
+	rules, err := reloadTagRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules == current || len(rules.Trackers["lacale"].Characteristics) != 1 || rules.Dictionaries["genres"] == nil {
+		t.Errorf("valid rule file not applied: %+v", rules.Trackers)
+	}
+}
+
+// tagCorpusEntry is a release of testdata/tag_corpus.json: its name, the fields parsed from it and its mediainfo,
+// and the expected tag names of some characteristics (by slug)
+type tagCorpusEntry struct {
+	Release     string              `json:"release"`
+	MediaType   string              `json:"mediaType"`
+	ReleaseInfo ReleaseInfo         `json:"releaseInfo"`
+	Tags        map[string][]string `json:"tags"`
+}
+
+func TestTagCorpus(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "tag_corpus.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corpus []tagCorpusEntry
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		t.Fatal(err)
+	}
+	catalog := embeddedTagCatalog(t)
+	for _, entry := range corpus {
+		t.Run(entry.Release, func(t *testing.T) {
+			_, chars := catalog.Category(entry.MediaType)
+			explanations, err := explainTags("lacale", chars, entry.ReleaseInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for slug, want := range entry.Tags {
+				got, _ := selectedTagNames(t, explanations, slug)
+				sort.Strings(got)
+				sort.Strings(want)
+				if strings.Join(got, ", ") != strings.Join(want, ", ") {
+					t.Errorf("%s = [%s], want [%s]", slug, strings.Join(got, ", "), strings.Join(want, ", "))
+				}
+			}
+		})
+	}
+}
+
+func TestTagNameMatches(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        bool
+	}{
+		{"720p (HD)", "HD", true},
+		{"720p (HD)", "UHD", false},
+		{"HDR", "HDR10", false},
+		{"HDR10+", "HDR10", false},
+		{"HDR10+", "HDR10+", true},
+		{"AC3", "E-AC3", false},
+		{"E-AC3", "EAC3", true},
+		{"E-AC3", "AC3", false},
+		{"Comédie", "comedie", true},
+		{"Policier / Thriller", "Thriller", true},
+		{"Téléfilm", "Telefilm", true},
+		{"AVC/H264/x264", "x264", true},
+	}
+	for _, tt := range tests {
+		if got := tagNameMatches(tt.name, tt.value); got != tt.want {
+			t.Errorf("tagNameMatches(%q, %q) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
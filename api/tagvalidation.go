@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TagValidationError describes why the tags of a characteristic were rejected
+type TagValidationError struct {
+	// Characteristic is empty for tags unknown to the catalog
+	Characteristic string   `json:"characteristic,omitempty"`
+	Slug           string   `json:"slug,omitempty"`
+	TagIDs         []string `json:"tagIds,omitempty"`
+	Message        string   `json:"message"`
+	// Missing marks a required characteristic without tag, a gap rather than a wrong tag
+	Missing bool `json:"missing,omitempty"`
+}
+
+// TagValidationErrors are the problems found in the tags of a release, returned before anything is sent to the tracker
+type TagValidationErrors []TagValidationError
+
+func (e TagValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, v := range e {
+		if v.Characteristic != "" {
+			messages = append(messages, v.Characteristic+": "+v.Message)
+		} else {
+			messages = append(messages, v.Message)
+		}
+	}
+	return "invalid tags: " + strings.Join(messages, "; ")
+}
+
+// splitMissing separates the required characteristics without tag from the other errors
+func (e TagValidationErrors) splitMissing() (invalid, missing TagValidationErrors) {
+	for _, v := range e {
+		if v.Missing {
+			missing = append(missing, v)
+		} else {
+			invalid = append(invalid, v)
+		}
+	}
+	return invalid, missing
+}
+
+// validateTags checks tag ids against a category of the catalog and the required and exclusive rules of its characteristics
+func validateTags(catalog *tagCatalog, rules TrackerTagRules, categoryID string, tagIDs []string) TagValidationErrors {
+	errs := TagValidationErrors{}
+	chars, ok := catalog.CategoryByID(categoryID)
+	if !ok {
+		return append(errs, TagValidationError{Message: fmt.Sprintf("unknown category %q", categoryID)})
+	}
+
+	// Characteristic of each tag of the category
+	charOf := map[string]int{}
+	for i, char := range chars {
+		for _, tag := range char.Tags {
+			if tag.ID != "" {
+				charOf[tag.ID] = i
+			}
+		}
+	}
+
+	selected := make([][]string, len(chars))
+	seen := map[string]bool{}
+	var unknown []string
+	for _, id := range tagIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if i, ok := charOf[id]; ok {
+			selected[i] = append(selected[i], id)
+			continue
+		}
+		tag, ok := catalog.Tag(id)
+		if !ok {
+			unknown = append(unknown, id)
+			continue
+		}
+		errs = append(errs, TagValidationError{Characteristic: tag.Characteristic, TagIDs: []string{id},
+			Message: fmt.Sprintf("%s is not a tag of this category", tag.Name)})
+	}
+	if len(unknown) > 0 {
+		errs = append(errs, TagValidationError{TagIDs: unknown,
+			Message: fmt.Sprintf("unknown tag ids: %s", strings.Join(unknown, ", "))})
+	}
+
+	for i, char := range chars {
+		rule := rules.forCharacteristic(char.Slug)
+		if rule == nil {
+			continue
+		}
+		switch {
+		case rule.Required && len(selected[i]) == 0:
+			errs = append(errs, TagValidationError{Characteristic: char.Name, Slug: char.Slug,
+				Message: "one tag is required", Missing: true})
+		case rule.Exclusive && len(selected[i]) > 1:
+			names := make([]string, len(selected[i]))
+			for j, id := range selected[i] {
+				tag, _ := catalog.Tag(id)
+				names[j] = tag.Name
+			}
+			errs = append(errs, TagValidationError{Characteristic: char.Name, Slug: char.Slug, TagIDs: selected[i],
+				Message: fmt.Sprintf("only one tag is allowed (%s)", strings.Join(names, ", "))})
+		}
+	}
+	return errs
+}
+
+// validateLaCaleTags checks tag ids against the La-Cale category of a media type, or categoryID when set
+func validateLaCaleTags(mediaType, categoryID string, tagIDs []string) (TagValidationErrors, error) {
+	catalog, err := currentTagCatalog()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := currentTagRules()
+	if rules == nil {
+		return nil, err
+	}
+	if categoryID == "" {
+		if categoryID, _ = catalog.Category(mediaType); categoryID == "" {
+			return nil, fmt.Errorf("could not find a matching category for type: %s", mediaType)
+		}
+	}
+	return validateTags(catalog, rules.Trackers["lacale"], categoryID, tagIDs), nil
+}
+
+// ValidateTags checks the tags of a La-Cale upload against the current catalog
+func (t *laCaleTracker) ValidateTags(categoryID string, tagIDs []string) error {
+	errs, err := validateLaCaleTags("", categoryID, tagIDs)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// writeTagValidationErrors answers 422 with the per-characteristic errors when err holds TagValidationErrors
+func writeTagValidationErrors(w http.ResponseWriter, err error) bool {
+	var errs TagValidationErrors
+	if !errors.As(err, &errs) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "tagErrors": errs})
+	return true
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// laCaleTagRules returns the La-Cale rules of the rules in use
+func laCaleTagRules(t *testing.T) TrackerTagRules {
+	t.Helper()
+	rules, err := currentTagRules()
+	if rules == nil {
+		t.Fatal(err)
+	}
+	return rules.Trackers["lacale"]
+}
+
+// seriesOnlyTag is added to the series of the test catalog: the embedded movies and series share all their tags
+var seriesOnlyTag = LocalTag{Name: "Saison complete", ID: "series-only"}
+
+// validationTagCatalog is the embedded catalog with a tag that only series offer
+func validationTagCatalog(t *testing.T) *tagCatalog {
+	t.Helper()
+	meta := embeddedTagCatalog(t).Meta
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var copied LocalMetaRoot
+	if err := json.Unmarshal(data, &copied); err != nil {
+		t.Fatal(err)
+	}
+	if !addCatalogTag(copied.Categories, laCaleSeriesID, seriesOnlyTag) {
+		t.Fatal("no series category")
+	}
+	return newTagCatalog(copied, "test", 0)
+}
+
+// addCatalogTag adds a tag to the first characteristic of the category with this id
+func addCatalogTag(categories []LocalCategory, categoryID string, tag LocalTag) bool {
+	for i := range categories {
+		if categories[i].ID == categoryID {
+			chars := categories[i].Characteristics
+			chars[0].Tags = append(chars[0].Tags, tag)
+			return true
+		}
+		if addCatalogTag(categories[i].SubCategories, categoryID, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolutionTagIDs returns the ids of the movie resolution tags
+func resolutionTagIDs(t *testing.T, catalog *tagCatalog) []string {
+	t.Helper()
+	films, _ := catalog.CategoryByID(laCaleFilmsID)
+	for _, char := range films {
+		if char.Slug == "qualit-r-solution" {
+			var ids []string
+			for _, tag := range char.Tags {
+				ids = append(ids, tag.ID)
+			}
+			return ids
+		}
+	}
+	t.Fatal("no resolution characteristic for movies")
+	return nil
+}
+
+func TestValidateTags(t *testing.T) {
+	catalog := validationTagCatalog(t)
+	rules := laCaleTagRules(t)
+	resolutions := resolutionTagIDs(t, catalog)
+	valid := []string{laCaleTag1080p, laCaleTagWEBDL, laCaleTagX264, laCaleTagMKV}
+
+	tests := []struct {
+		name string
+		tags []string
+		// want is the characteristic slug of each expected error, "" for unknown ids and other categories
+		want    []string
+		missing int
+	}{
+		{name: "valid", tags: valid},
+		{name: "duplicate ids count once", tags: append(valid, laCaleTag1080p)},
+		{name: "unknown id", tags: append(valid, "nope"), want: []string{""}},
+		{name: "other category", tags: append(valid, seriesOnlyTag.ID), want: []string{""}},
+		{name: "required", tags: []string{laCaleTagWEBDL, laCaleTagMKV}, want: []string{"qualit-r-solution"}, missing: 1},
+		{name: "nothing", tags: nil, want: []string{"qualit-r-solution", "source-type"}, missing: 2},
+		{name: "exclusive", tags: append(valid, resolutions[0], resolutions[1]), want: []string{"qualit-r-solution"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateTags(catalog, rules, laCaleFilmsID, tt.tags)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors = %+v, want %d", errs, len(tt.want))
+			}
+			for i, e := range errs {
+				if e.Slug != tt.want[i] {
+					t.Errorf("error %d = %+v, want slug %q", i, e, tt.want[i])
+				}
+			}
+			if _, missing := errs.splitMissing(); len(missing) != tt.missing {
+				t.Errorf("missing = %+v, want %d", missing, tt.missing)
+			}
+		})
+	}
+
+	if errs := validateTags(catalog, rules, "nope", valid); len(errs) != 1 {
+		t.Errorf("unknown category: errors = %+v", errs)
+	}
+	// The other category tag names its characteristic
+	errs := validateTags(catalog, rules, laCaleFilmsID, append(valid, seriesOnlyTag.ID))
+	if errs[0].Characteristic == "" || len(errs[0].TagIDs) != 1 || errs[0].TagIDs[0] != seriesOnlyTag.ID {
+		t.Errorf("other category error = %+v", errs[0])
+	}
+}
+
+// gapTracker maps a fixed set of tags and validates them against the embedded catalog
+type gapTracker struct {
+	laCaleTracker
+	t        *testing.T
+	tags     []string
+	uploaded bool
+}
+
+func (g *gapTracker) Login(ctx context.Context) error { return nil }
+
+func (g *gapTracker) MapTags(mediaType string, info ReleaseInfo) ([]string, error) {
+	return g.tags, nil
+}
+
+func (g *gapTracker) ValidateTags(categoryID string, tagIDs []string) error {
+	if errs := validateTags(embeddedTagCatalog(g.t), laCaleTagRules(g.t), categoryID, tagIDs); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (g *gapTracker) Upload(ctx context.Context, release TrackerRelease) (TrackerUploadResult, error) {
+	g.uploaded = true
+	return TrackerUploadResult{}, nil
+}
+
+func TestUploadToTrackerRequiredTags(t *testing.T) {
+	noResolution := []string{laCaleTagWEBDL, laCaleTagMKV}
+	tests := []struct {
+		name       string
+		detected   []string
+		custom     []string
+		wantUpload bool
+	}{
+		// A release name without resolution is uploaded with the tags that were found
+		{name: "detected gap", detected: noResolution, wantUpload: true},
+		// The user picked the tags: the missing resolution is an error to fix
+		{name: "custom gap", custom: noResolution},
+		// A wrong tag is refused even when detected, without the gaps
+		{name: "detected unknown", detected: append(noResolution, "nope")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &gapTracker{t: t, tags: tt.detected}
+			_, err := (&App{}).UploadToTracker(context.Background(), tracker, TrackerRelease{MediaType: "movie",
+				Category: laCaleFilmsID, Tags: tt.custom})
+			if tracker.uploaded != tt.wantUpload {
+				t.Fatalf("uploaded = %v (err %v), want %v", tracker.uploaded, err, tt.wantUpload)
+			}
+			var errs TagValidationErrors
+			if !tt.wantUpload && !errors.As(err, &errs) {
+				t.Errorf("err = %v, want TagValidationErrors", err)
+			}
+			if tt.name == "detected unknown" {
+				if _, missing := errs.splitMissing(); len(missing) != 0 {
+					t.Errorf("detected gaps returned as errors: %+v", missing)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// TorrentClient is implemented by every supported torrent client
+// Hashes are lowercase hex v1 info hashes.
+type TorrentClient interface {
+	// Name returns the registry name of the client ("qbittorrent", "transmission"...)
+	Name() string
+	// Capabilities reports the optional features supported by the client
+	Capabilities() ClientCapabilities
+	// Add adds a .torrent file and starts it unless opts.Paused is set
+	Add(ctx context.Context, torrentPath string, opts AddOptions) error
+	// Remove removes a torrent, deleting its data only when deleteData is set
+	Remove(ctx context.Context, infoHash string, deleteData bool) error
+	// Status returns the state of a single torrent
+	Status(ctx context.Context, infoHash string) (*TorrentStatus, error)
+	// List returns every torrent known to the client
+	List(ctx context.Context) ([]TorrentStatus, error)
+	// SetCategory sets the category (qBittorrent) or label (Transmission, Deluge) of a torrent
+	SetCategory(ctx context.Context, infoHash string, category string) error
+	// Recheck forces a hash check of the torrent data
+	Recheck(ctx context.Context, infoHash string) error
+}
+
+// clientVersioner is implemented by the clients that can report their version
+// It is used by the connectivity test, so it must log in like any other call.
+type clientVersioner interface {
+	// Version returns the client version and extra details shown next to it
+	Version(ctx context.Context) (string, map[string]string, error)
+}
+
+// ClientCapabilities lists the optional features of a torrent client
+type ClientCapabilities struct {
+	Categories    bool `json:"categories"`
+	Labels        bool `json:"labels"`
+	Tags          bool `json:"tags"`
+	Recheck       bool `json:"recheck"`
+	SavePath      bool `json:"savePath"`
+	SkipChecking  bool `json:"skipChecking"`
+	ContentLayout bool `json:"contentLayout"`
+}
+
+// AddOptions are the per-torrent options passed to TorrentClient.Add
+// Options a client doesn't support (see ClientCapabilities) are ignored.
+type AddOptions struct {
+	Paused bool `json:"paused"`
+	// SavePath is the directory containing the content, so the client seeds it instead of downloading
+	SavePath     string   `json:"savePath,omitempty"`
+	SkipChecking bool     `json:"skipChecking"`
+	Category     string   `json:"category,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// ContentLayout is the qBittorrent layout: "Original", "Subfolder" or "NoSubfolder"
+	ContentLayout string `json:"contentLayout,omitempty"`
+}
+
+// TorrentStatus is the client-independent state of a torrent
+// State is one of "downloading", "seeding", "paused", "checking", "queued", "error" or "unknown".
+type TorrentStatus struct {
+	InfoHash string  `json:"infoHash"`
+	Name     string  `json:"name"`
+	State    string  `json:"state"`
+	Progress float64 `json:"progress"`
+	Size     int64   `json:"size"`
+	Uploaded int64   `json:"uploaded"`
+	Ratio    float64 `json:"ratio"`
+	SavePath string  `json:"savePath,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	// TrackerStatus is the last announce result ("working", "not working: <message>"...), filled by Status only
+	TrackerStatus string `json:"trackerStatus,omitempty"`
+}
+
+// ErrTorrentNotFound is returned by Status when the client doesn't know the torrent
+var ErrTorrentNotFound = errors.New("torrent not found in client")
+
+// ErrNotSupported is returned by the clients that can't perform an operation (watch directory...)
+var ErrNotSupported = errors.New("not supported by this torrent client")
+
+// torrentClientTimeout bounds every request made to a torrent client
+const torrentClientTimeout = 60 * time.Second
+
+// torrentClientFactory builds a client from the settings
+// key returns the settings the client depends on, so a cached session is dropped when they change.
+type torrentClientFactory struct {
+	new func(settings AppSettings) (TorrentClient, error)
+	key func(settings AppSettings) string
+}
+
+// torrentClientRegistry maps AppSettings.TorrentClient values to their implementation
+var torrentClientRegistry = map[string]torrentClientFactory{}
+
+// registerTorrentClient is called from the init() of each client implementation
+func registerTorrentClient(name string, factory torrentClientFactory) {
+	torrentClientRegistry[name] = factory
+}
+
+// registeredTorrentClients returns the sorted names of the registered clients
+func registeredTorrentClients() []string {
+	names := make([]string, 0, len(torrentClientRegistry))
+	for name := range torrentClientRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cachedTorrentClient keeps a client (and its login session) for reuse between requests
+type cachedTorrentClient struct {
+	key    string
+	client TorrentClient
+}
+
+// torrentClients caches one client per instance name
+var torrentClients struct {
+	sync.Mutex
+	byName map[string]cachedTorrentClient
+}
+
+// GetTorrentClient returns the default client instance, or nil when none is configured
+func (a *App) GetTorrentClient(settings AppSettings) (TorrentClient, error) {
+	return a.GetClientInstance(settings, "")
+}
+
+// GetClientInstance returns the client instance with this name ("" for the default one)
+// The client is reused as long as its connection settings don't change.
+func (a *App) GetClientInstance(settings AppSettings, name string) (TorrentClient, error) {
+	ci, ok := findClientInstance(settings, name)
+	if !ok {
+		if name == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown torrent client instance: %s", name)
+	}
+	return newClientForInstance(settings, ci)
+}
+
+// newClientForInstance builds (or reuses) the client of an instance
+func newClientForInstance(settings AppSettings, ci ClientInstance) (TorrentClient, error) {
+	factory, ok := torrentClientRegistry[ci.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown torrent client: %s", ci.Type)
+	}
+
+	clientSettings := ci.settings(settings)
+	key := factory.key(clientSettings)
+	torrentClients.Lock()
+	defer torrentClients.Unlock()
+	if cached, ok := torrentClients.byName[ci.Name]; ok && cached.key == key {
+		return cached.client, nil
+	}
+	client, err := factory.new(clientSettings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ci.Name, err)
+	}
+	if torrentClients.byName == nil {
+		torrentClients.byName = map[string]cachedTorrentClient{}
+	}
+	torrentClients.byName[ci.Name] = cachedTorrentClient{key: key, client: client}
+	return client, nil
+}
+
+// torrentInfoHash returns the lowercase info hash of a .torrent file
+func torrentInfoHash(torrentPath string) (string, error) {
+	mi, err := metainfo.LoadFromFile(torrentPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load torrent file: %w", err)
+	}
+	return strings.ToLower(mi.HashInfoBytes().HexString()), nil
+}
+
+// clientAddOptions builds the add options of a tracker profile, the global settings filling its empty fields
+// contentPath is the file or directory the torrent was made from (the source or its hardlink);
+// the client is pointed at its parent directory so it seeds the existing data.
+// needsLayout is set when the client only finds the data with the NoSubfolder content layout.
+func clientAddOptions(ctx context.Context, torrentPath string, contentPath string, settings AppSettings, profile TrackerProfile) (opts AddOptions, needsLayout bool, err error) {
+	opts = AddOptions{
+		SkipChecking:  settings.ClientSkipChecking,
+		Category:      strings.TrimSpace(settings.ClientCategory),
+		ContentLayout: settings.ClientContentLayout,
+	}
+	tags := settings.ClientTags
+	if profile.ClientSkipChecking != nil {
+		opts.SkipChecking = *profile.ClientSkipChecking
+	}
+	if category := strings.TrimSpace(profile.ClientCategory); category != "" {
+		opts.Category = category
+	}
+	if profile.ClientContentLayout != "" {
+		opts.ContentLayout = profile.ClientContentLayout
+	}
+	if profile.ClientTags != "" {
+		tags = profile.ClientTags
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			opts.Tags = append(opts.Tags, tag)
+		}
+	}
+
+	if contentPath == "" {
+		return opts, false, nil
+	}
+	if _, err := os.Stat(contentPath); err != nil {
+		return opts, false, fmt.Errorf("content not found: %w", err)
+	}
+	saveDir := filepath.Dir(contentPath)
+	if profile.ClientSavePath != "" {
+		saveDir = profile.ClientSavePath
+	}
+	opts.SavePath = saveDir
+
+	// The client looks for <savePath>/<torrent name>
+	mi, err := metainfo.LoadFromFile(torrentPath)
+	if err != nil {
+		return opts, false, fmt.Errorf("failed to load torrent file: %w", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return opts, false, fmt.Errorf("failed to read torrent info: %w", err)
+	}
+	if info.Name == filepath.Base(contentPath) {
+		return opts, false, nil
+	}
+
+	// A directory torrent whose files keep their paths is seeded from the content directory itself
+	if !info.IsDir() || !torrentFilesUnder(&info, contentPath) {
+		return opts, false, fmt.Errorf("torrent name %q differs from content %s: the client would not find the data, enable hardlinks to seed it under the release name",
+			info.Name, shortPath(contentPath))
+	}
+	logInfoCtx(ctx, "clientAddOptions: torrent name %q differs from content %s, adding it without subfolder",
+		info.Name, shortPath(contentPath))
+	opts.SavePath = filepath.Join(saveDir, filepath.Base(contentPath))
+	opts.ContentLayout = "NoSubfolder"
+	// The data is only matched by path: let the client verify it
+	opts.SkipChecking = false
+	return opts, true, nil
+}
+
+// torrentFilesUnder reports whether every file of a directory torrent exists in dir with its size
+func torrentFilesUnder(info *metainfo.Info, dir string) bool {
+	for _, f := range info.UpvertedFiles() {
+		fi, err := os.Stat(filepath.Join(append([]string{dir}, f.Path...)...))
+		if err != nil || fi.Size() != f.Length {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientAddResult is the outcome of adding a torrent to one client instance
+type ClientAddResult struct {
+	Client string `json:"client"`
+	Type   string `json:"type"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UploadToTorrentClient adds a torrent to the client instances selected by the routing rules
+// contentPath may be empty, in which case the clients use their default save path.
+// It fails only when no instance accepted the torrent.
+func (a *App) UploadToTorrentClient(ctx context.Context, torrentPath string, contentPath string, route ClientRouteInput, settings AppSettings) ([]ClientAddResult, error) {
+	targets, err := resolveClientTargets(settings, route)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, nil // No client configured, skip upload
+	}
+	profile, _ := findTrackerProfile(settings, route.Tracker)
+	opts, needsLayout, err := clientAddOptions(ctx, torrentPath, contentPath, settings, profile)
+	if err != nil {
+		return nil, err
+	}
+	infoHash, err := torrentInfoHash(torrentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ClientAddResult, 0, len(targets))
+	var added []string
+	var lastErr error
+	for _, ci := range targets {
+		result := ClientAddResult{Client: ci.Name, Type: ci.Type}
+		client, err := newClientForInstance(settings, ci)
+		if err == nil && needsLayout && !client.Capabilities().ContentLayout {
+			err = fmt.Errorf("%s can't seed the content under another name, enable hardlinks", ci.Type)
+		}
+		// The local directory is only meaningful to the instances that see the same files
+		instanceOpts := opts
+		if profile.ClientSavePath == "" && opts.SavePath != "" {
+			instanceOpts.SavePath = ci.clientSavePath(opts.SavePath)
+			if instanceOpts.SavePath == "" {
+				// The client downloads to its default directory: nothing to skip
+				instanceOpts.SkipChecking = false
+			}
+		}
+		if err == nil {
+			err = client.Add(ctx, torrentPath, instanceOpts)
+			clientUploadsTotal.WithLabelValues(ci.Type, resultLabel(err)).Inc()
+		}
+		if err != nil {
+			logErrorCtx(ctx, "UploadToTorrentClient: %s: %v", ci.Name, err)
+			result.Error = err.Error()
+			lastErr = err
+		} else {
+			savePath := "client default"
+			if instanceOpts.SavePath != "" {
+				savePath = shortPath(instanceOpts.SavePath)
+			}
+			logInfoCtx(ctx, "UploadToTorrentClient: added %s to %s (save path: %s)", shortPath(torrentPath), ci.Name, savePath)
+			added = append(added, ci.Name)
+			// A client that can't skip the check is asked to verify the data, so it seeds instead of downloading
+			if instanceOpts.SkipChecking && !client.Capabilities().SkipChecking && client.Capabilities().Recheck {
+				if err := client.Recheck(ctx, infoHash); err != nil {
+					logWarnCtx(ctx, "UploadToTorrentClient: %s: recheck failed: %v", ci.Name, err)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	if len(added) == 0 {
+		return results, lastErr
+	}
+
+	// Every instance is recorded for removal; the seeding monitor follows the first one
+	if err := markReleaseAddedToClient(infoHash, added); err != nil {
+		logWarnCtx(ctx, "UploadToTorrentClient: failed to record client state: %v", err)
+	}
+	return results, nil
+}
+
+// RemoveFromTorrentClient removes a torrent without deleting its data
+// Every instance recorded for the release is used, the default one otherwise.
+func (a *App) RemoveFromTorrentClient(ctx context.Context, torrentPath string, settings AppSettings) error {
+	infoHash, err := torrentInfoHash(torrentPath)
+	if err != nil {
+		return err
+	}
+	names := []string{""}
+	if releases, err := queryReleases("info_hash = ? AND client IS NOT NULL", infoHash); err == nil && len(releases) > 0 {
+		names = releases[0].Clients
+	}
+	var firstErr error
+	for _, name := range names {
+		client, err := a.GetClientInstance(settings, name)
+		if err == nil && client == nil {
+			continue
+		}
+		if name == "" && client != nil {
+			name = client.Name()
+		}
+		if err == nil {
+			err = client.Remove(ctx, infoHash, false)
+		}
+		if err != nil {
+			logErrorCtx(ctx, "RemoveFromTorrentClient: %s: %v", name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		logInfoCtx(ctx, "RemoveFromTorrentClient: removed %s from %s", infoHash, name)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := markReleaseRemovedFromClient(infoHash); err != nil {
+		logWarnCtx(ctx, "RemoveFromTorrentClient: failed to record client state: %v", err)
+	}
+	return nil
+}
+
+// ClientInfo describes the configured torrent client for GET /api/torrent-client
+type ClientInfo struct {
+	Client       string             `json:"client"`
+	Available    []string           `json:"available"`
+	Capabilities ClientCapabilities `json:"capabilities"`
+	// Instances lists every client instance, the default one first
+	Instances []ClientInstanceInfo `json:"instances"`
+	Routes    []ClientRoute        `json:"routes"`
+}
+
+// ClientInstanceInfo describes a client instance without its credentials
+type ClientInstanceInfo struct {
+	Name         string             `json:"name"`
+	Type         string             `json:"type"`
+	Capabilities ClientCapabilities `json:"capabilities"`
+}
+
+// GetTorrentClientInfo reports the configured clients and their capabilities
+func (a *App) GetTorrentClientInfo() (ClientInfo, error) {
+	settings := a.GetSettings()
+	info := ClientInfo{
+		Client:    settings.TorrentClient,
+		Available: registeredTorrentClients(),
+		Instances: []ClientInstanceInfo{},
+		Routes:    settings.ClientRoutes,
+	}
+	if info.Routes == nil {
+		info.Routes = []ClientRoute{}
+	}
+	for i, ci := range clientInstances(settings) {
+		client, err := newClientForInstance(settings, ci)
+		if err != nil {
+			return info, err
+		}
+		instance := ClientInstanceInfo{Name: ci.Name, Type: ci.Type, Capabilities: client.Capabilities()}
+		if i == 0 {
+			info.Capabilities = instance.Capabilities
+		}
+		info.Instances = append(info.Instances, instance)
+	}
+	return info, nil
+}
@@ -0,0 +1,1062 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// writeTestTorrent creates a single-file torrent in a temp directory and returns its path and info hash
+func writeTestTorrent(t *testing.T, name string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	content := filepath.Join(dir, name)
+	if err := os.WriteFile(content, []byte("aatm test content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(content); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi := metainfo.MetaInfo{InfoBytes: infoBytes, Announce: "http://tracker.test/announce"}
+	torrentPath := filepath.Join(dir, name+".torrent")
+	f, err := os.Create(torrentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := mi.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	return torrentPath, strings.ToLower(mi.HashInfoBytes().HexString())
+}
+
+// callRecorder keeps the methods called on a fake server and their last arguments
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []string
+	args  map[string]map[string]interface{}
+}
+
+func (r *callRecorder) record(method string, args map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, method)
+	if r.args == nil {
+		r.args = map[string]map[string]interface{}{}
+	}
+	r.args[method] = args
+}
+
+func (r *callRecorder) count(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, c := range r.calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *callRecorder) lastArgs(method string) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.args[method]
+}
+
+// fakeQBittorrent serves the WebUI API v2 endpoints used by qbitClient
+type fakeQBittorrent struct {
+	callRecorder
+	hash       string
+	sid        int
+	categories map[string]bool
+	// expireOnce answers 403 to the next authenticated request, as after a session timeout
+	expireOnce bool
+}
+
+func newFakeQBittorrent(t *testing.T, hash string) (*fakeQBittorrent, *httptest.Server) {
+	f := &fakeQBittorrent{hash: hash, categories: map[string]bool{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeQBittorrent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v2/auth/login" {
+		r.ParseForm()
+		f.record(r.URL.Path, nil)
+		if r.FormValue("username") != "admin" || r.FormValue("password") != "secret" {
+			fmt.Fprint(w, "Fails.")
+			return
+		}
+		f.mu.Lock()
+		f.sid++
+		sid := f.sid
+		f.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: fmt.Sprint(sid), Path: "/"})
+		fmt.Fprint(w, "Ok.")
+		return
+	}
+
+	f.mu.Lock()
+	cookie, err := r.Cookie("SID")
+	valid := err == nil && cookie.Value == fmt.Sprint(f.sid) && !f.expireOnce
+	f.expireOnce = false
+	f.mu.Unlock()
+	if !valid {
+		f.record("403 "+r.URL.Path, nil)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	args := map[string]interface{}{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		r.ParseMultipartForm(1 << 20)
+	} else {
+		r.ParseForm()
+	}
+	for k := range r.Form {
+		args[k] = r.FormValue(k)
+	}
+	if r.MultipartForm != nil {
+		args["files"] = len(r.MultipartForm.File["torrents"])
+	}
+	f.record(r.URL.Path, args)
+
+	switch r.URL.Path {
+	case "/api/v2/torrents/add", "/api/v2/torrents/delete", "/api/v2/torrents/recheck":
+		fmt.Fprint(w, "Ok.")
+	case "/api/v2/torrents/info":
+		list := []qbitTorrentInfo{}
+		if hashes := r.URL.Query().Get("hashes"); hashes == "" || hashes == f.hash {
+			list = append(list, qbitTorrentInfo{Hash: strings.ToUpper(f.hash), Name: "Release.mkv", State: "stalledUP",
+				Progress: 1, TotalSize: 17, Uploaded: 34, Ratio: 2, SavePath: "/data", Category: "films"})
+		}
+		json.NewEncoder(w).Encode(list)
+	case "/api/v2/torrents/trackers":
+		json.NewEncoder(w).Encode([]qbitTracker{{URL: "** [DHT] **", Status: 2}, {URL: "http://tracker.test/announce", Status: 4, Msg: "unregistered"}})
+	case "/api/v2/torrents/setCategory":
+		f.mu.Lock()
+		exists := f.categories[r.FormValue("category")]
+		f.mu.Unlock()
+		if !exists && r.FormValue("category") != "" {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, "Incorrect category name")
+			return
+		}
+	case "/api/v2/torrents/createCategory":
+		f.mu.Lock()
+		f.categories[r.FormValue("category")] = true
+		f.mu.Unlock()
+	case "/api/v2/app/version":
+		fmt.Fprint(w, "v4.6.0")
+	case "/api/v2/app/webapiVersion":
+		fmt.Fprint(w, "2.9.3")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// fakeTransmission serves the Transmission RPC, requiring the CSRF session id
+type fakeTransmission struct {
+	callRecorder
+	hash      string
+	sessionID string
+}
+
+func newFakeTransmission(t *testing.T, hash string) (*fakeTransmission, *httptest.Server) {
+	f := &fakeTransmission{hash: hash, sessionID: "session-1"}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeTransmission) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/transmission/rpc" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	sessionID := f.sessionID
+	f.mu.Unlock()
+	if r.Header.Get("X-Transmission-Session-Id") != sessionID {
+		f.record("409", nil)
+		w.Header().Set("X-Transmission-Session-Id", sessionID)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Method    string                 `json:"method"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.record(req.Method, req.Arguments)
+
+	resp := TransmissionRPCResponse{Result: "success", Arguments: map[string]interface{}{}}
+	switch req.Method {
+	case "torrent-add":
+		resp.Arguments["torrent-added"] = map[string]interface{}{"hashString": f.hash}
+	case "torrent-get":
+		torrents := []interface{}{}
+		ids, _ := req.Arguments["ids"].([]interface{})
+		if ids == nil || (len(ids) == 1 && ids[0] == f.hash) {
+			torrents = append(torrents, map[string]interface{}{
+				"hashString": f.hash, "name": "Release.mkv", "status": 6, "percentDone": 1,
+				"totalSize": 17, "uploadedEver": 34, "uploadRatio": 2, "downloadDir": "/data",
+				"labels": []string{"films", "aatm"},
+				"trackerStats": []interface{}{map[string]interface{}{
+					"hasAnnounced": true, "lastAnnounceSucceeded": false, "lastAnnounceResult": "unregistered"}},
+			})
+		}
+		resp.Arguments["torrents"] = torrents
+	case "session-get":
+		resp.Arguments["version"] = "4.0.5"
+		resp.Arguments["rpc-version"] = 17
+		resp.Arguments["rpc-version-minimum"] = 14
+	case "torrent-remove", "torrent-set", "torrent-verify":
+	default:
+		resp.Result = "method name not recognized"
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fakeDeluge serves the Deluge WebUI JSON-RPC, requiring the session cookie
+type fakeDeluge struct {
+	callRecorder
+	hash   string
+	labels map[string]bool
+}
+
+func newFakeDeluge(t *testing.T, hash string) (*fakeDeluge, *httptest.Server) {
+	f := &fakeDeluge{hash: hash, labels: map[string]bool{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeDeluge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req DelugeRPCRequest
+	if r.URL.Path != "/json" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.record(req.Method, map[string]interface{}{"params": req.Params})
+	resp := DelugeRPCResponse{ID: req.ID}
+
+	if req.Method == "auth.login" {
+		ok := len(req.Params) == 1 && req.Params[0] == "secret"
+		if ok {
+			http.SetCookie(w, &http.Cookie{Name: "_session_id", Value: "s1", Path: "/"})
+		}
+		resp.Result = ok
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	if c, err := r.Cookie("_session_id"); err != nil || c.Value != "s1" {
+		resp.Error = map[string]interface{}{"message": "Not authenticated", "code": delugeNotAuthenticated}
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	status := map[string]interface{}{
+		"name": "Release.mkv", "state": "Seeding", "progress": 100, "total_size": 17, "total_uploaded": 34,
+		"ratio": 2, "save_path": "/data", "label": "films", "tracker_status": "Announce OK",
+	}
+	switch req.Method {
+	case "web.connected":
+		resp.Result = true
+	case "daemon.info":
+		resp.Result = "2.1.1"
+	case "core.add_torrent_file":
+		resp.Result = f.hash
+	case "core.get_torrent_status":
+		if len(req.Params) > 0 && req.Params[0] == f.hash {
+			resp.Result = status
+		} else {
+			resp.Result = map[string]interface{}{}
+		}
+	case "core.get_torrents_status":
+		resp.Result = map[string]interface{}{f.hash: status}
+	case "label.add":
+		label := fmt.Sprint(req.Params[0])
+		f.mu.Lock()
+		exists := f.labels[label]
+		f.labels[label] = true
+		f.mu.Unlock()
+		if exists {
+			resp.Error = map[string]interface{}{"message": "Label already exists", "code": 4}
+		}
+	case "label.set_torrent", "core.remove_torrent", "core.force_recheck":
+		resp.Result = true
+	default:
+		resp.Error = map[string]interface{}{"message": "Unknown method", "code": 2}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// clientMethods are the server-side methods expected for each TorrentClient operation
+type clientMethods struct {
+	add, remove, status, list, setCategory, recheck string
+}
+
+func TestTorrentClientContract(t *testing.T) {
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	opts := AddOptions{SavePath: "/data", SkipChecking: true, Category: "films", Tags: []string{"aatm"}}
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T) (TorrentClient, *callRecorder)
+		methods clientMethods
+		// checkAdd verifies how the AddOptions were sent
+		checkAdd func(t *testing.T, rec *callRecorder)
+		tracker  string
+	}{
+		{
+			name: "qbittorrent",
+			setup: func(t *testing.T) (TorrentClient, *callRecorder) {
+				f, srv := newFakeQBittorrent(t, hash)
+				f.categories["films"] = true
+				c, err := newQBittorrentClient(srv.URL+"/", "admin", "secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return c, &f.callRecorder
+			},
+			methods: clientMethods{
+				add: "/api/v2/torrents/add", remove: "/api/v2/torrents/delete", status: "/api/v2/torrents/info",
+				list: "/api/v2/torrents/info", setCategory: "/api/v2/torrents/setCategory", recheck: "/api/v2/torrents/recheck",
+			},
+			checkAdd: func(t *testing.T, rec *callRecorder) {
+				args := rec.lastArgs("/api/v2/torrents/add")
+				want := map[string]interface{}{"files": 1, "savepath": "/data", "autoTMM": "false", "skip_checking": "true",
+					"category": "films", "tags": "aatm", "paused": "false", "stopped": "false"}
+				for k, v := range want {
+					if args[k] != v {
+						t.Errorf("add field %s = %v, want %v", k, args[k], v)
+					}
+				}
+			},
+			tracker: "not working: unregistered",
+		},
+		{
+			name: "transmission",
+			setup: func(t *testing.T) (TorrentClient, *callRecorder) {
+				f, srv := newFakeTransmission(t, hash)
+				c, err := newTransmissionClient(srv.URL, "admin", "secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return c, &f.callRecorder
+			},
+			methods: clientMethods{
+				add: "torrent-add", remove: "torrent-remove", status: "torrent-get",
+				list: "torrent-get", setCategory: "torrent-set", recheck: "torrent-verify",
+			},
+			checkAdd: func(t *testing.T, rec *callRecorder) {
+				args := rec.lastArgs("torrent-add")
+				if args["download-dir"] != "/data" || args["paused"] != false || args["metainfo"] == "" {
+					t.Errorf("torrent-add arguments = %v", args)
+				}
+				// Category and tags end up as labels, set once the torrent is added
+				labels := fmt.Sprint(rec.lastArgs("torrent-set")["labels"])
+				if labels != "[films aatm]" {
+					t.Errorf("labels = %s, want [films aatm]", labels)
+				}
+			},
+			tracker: "not working: unregistered",
+		},
+		{
+			name: "deluge",
+			setup: func(t *testing.T) (TorrentClient, *callRecorder) {
+				f, srv := newFakeDeluge(t, hash)
+				c, err := newDelugeClient(srv.URL, "secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return c, &f.callRecorder
+			},
+			methods: clientMethods{
+				add: "core.add_torrent_file", remove: "core.remove_torrent", status: "core.get_torrent_status",
+				list: "core.get_torrents_status", setCategory: "label.set_torrent", recheck: "core.force_recheck",
+			},
+			checkAdd: func(t *testing.T, rec *callRecorder) {
+				params, _ := rec.lastArgs("core.add_torrent_file")["params"].([]interface{})
+				if len(params) != 3 || params[0] != "Release.mkv.torrent" {
+					t.Fatalf("core.add_torrent_file params = %v", params)
+				}
+				options, _ := params[2].(map[string]interface{})
+				if options["download_location"] != "/data" || options["seed_mode"] != true || options["add_paused"] != false {
+					t.Errorf("add options = %v", options)
+				}
+				if rec.count("label.set_torrent") != 1 {
+					t.Errorf("the category was not set as a label")
+				}
+			},
+			tracker: "Announce OK",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client, rec := tt.setup(t)
+			if client.Name() != tt.name {
+				t.Errorf("Name() = %q, want %q", client.Name(), tt.name)
+			}
+
+			if err := client.Add(ctx, torrentPath, opts); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if rec.count(tt.methods.add) != 1 {
+				t.Fatalf("Add did not call %s (calls: %v)", tt.methods.add, rec.calls)
+			}
+			tt.checkAdd(t, rec)
+
+			status, err := client.Status(ctx, hash)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			want := TorrentStatus{InfoHash: hash, Name: "Release.mkv", State: "seeding", Progress: 1, Size: 17,
+				Uploaded: 34, Ratio: 2, SavePath: "/data", Category: "films", TrackerStatus: tt.tracker}
+			if *status != want {
+				t.Errorf("Status = %+v, want %+v", *status, want)
+			}
+			if _, err := client.Status(ctx, strings.Repeat("0", 40)); !errors.Is(err, ErrTorrentNotFound) {
+				t.Errorf("Status of an unknown torrent: err = %v, want ErrTorrentNotFound", err)
+			}
+
+			list, err := client.List(ctx)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(list) != 1 || list[0].InfoHash != hash || list[0].State != "seeding" {
+				t.Errorf("List = %+v", list)
+			}
+
+			before := rec.count(tt.methods.setCategory)
+			if err := client.SetCategory(ctx, hash, "films"); err != nil {
+				t.Fatalf("SetCategory: %v", err)
+			}
+			if rec.count(tt.methods.setCategory) != before+1 {
+				t.Errorf("SetCategory did not call %s", tt.methods.setCategory)
+			}
+
+			if err := client.Recheck(ctx, hash); err != nil {
+				t.Fatalf("Recheck: %v", err)
+			}
+			if rec.count(tt.methods.recheck) != 1 {
+				t.Errorf("Recheck did not call %s", tt.methods.recheck)
+			}
+
+			if err := client.Remove(ctx, hash, false); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			if rec.count(tt.methods.remove) != 1 {
+				t.Errorf("Remove did not call %s", tt.methods.remove)
+			}
+		})
+	}
+}
+
+func TestQBittorrentReloginOn403(t *testing.T) {
+	f, srv := newFakeQBittorrent(t, "abc")
+	c, err := newQBittorrentClient(srv.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("/api/v2/auth/login"); n != 1 {
+		t.Fatalf("logins = %d, want 1", n)
+	}
+	if _, err := c.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("/api/v2/auth/login"); n != 1 {
+		t.Fatalf("the session was not reused: logins = %d", n)
+	}
+
+	f.mu.Lock()
+	f.expireOnce = true
+	f.mu.Unlock()
+	list, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("List after the session expired: %v", err)
+	}
+	if len(list) != 1 {
+		t.Errorf("List = %+v", list)
+	}
+	if n := f.count("/api/v2/auth/login"); n != 2 {
+		t.Errorf("logins = %d, want a single new login after the 403", n)
+	}
+	if n := f.count("403 /api/v2/torrents/info"); n != 1 {
+		t.Errorf("403 answers = %d, want 1", n)
+	}
+}
+
+func TestQBittorrentBadCredentials(t *testing.T) {
+	_, srv := newFakeQBittorrent(t, "abc")
+	c, err := newQBittorrentClient(srv.URL, "admin", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.List(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+		t.Errorf("err = %v, want invalid credentials", err)
+	}
+}
+
+func TestQBittorrentSetCategoryCreatesMissingCategory(t *testing.T) {
+	f, srv := newFakeQBittorrent(t, "abc")
+	c, err := newQBittorrentClient(srv.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetCategory(context.Background(), "abc", "series"); err != nil {
+		t.Fatalf("SetCategory: %v", err)
+	}
+	if n := f.count("/api/v2/torrents/createCategory"); n != 1 {
+		t.Errorf("createCategory calls = %d, want 1", n)
+	}
+	if n := f.count("/api/v2/torrents/setCategory"); n != 2 {
+		t.Errorf("setCategory calls = %d, want 2 (409 then retry)", n)
+	}
+	if got := f.lastArgs("/api/v2/torrents/createCategory")["category"]; got != "series" {
+		t.Errorf("created category = %v", got)
+	}
+
+	// An existing category is set directly
+	if err := c.SetCategory(context.Background(), "abc", "series"); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("/api/v2/torrents/createCategory"); n != 1 {
+		t.Errorf("createCategory calls = %d, want 1", n)
+	}
+}
+
+func TestTransmissionSessionIDRetry(t *testing.T) {
+	f, srv := newFakeTransmission(t, "abc")
+	c, err := newTransmissionClient(srv.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("409"); n != 1 {
+		t.Fatalf("409 answers = %d, want 1", n)
+	}
+	if _, err := c.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("409"); n != 1 {
+		t.Errorf("the session id was not reused: 409 answers = %d", n)
+	}
+
+	// Transmission rotates the id: a single retry picks the new one up
+	f.mu.Lock()
+	f.sessionID = "session-2"
+	f.mu.Unlock()
+	if _, err := c.List(ctx); err != nil {
+		t.Fatalf("List after the session id changed: %v", err)
+	}
+	if n := f.count("409"); n != 2 {
+		t.Errorf("409 answers = %d, want 2", n)
+	}
+	if n := f.count("torrent-get"); n != 3 {
+		t.Errorf("torrent-get calls = %d, want 3", n)
+	}
+}
+
+func TestTransmissionBadCredentials(t *testing.T) {
+	_, srv := newFakeTransmission(t, "abc")
+	c, err := newTransmissionClient(srv.URL, "admin", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.List(context.Background()); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("err = %v, want authentication failed", err)
+	}
+}
+
+func TestDelugeReloginWhenNotAuthenticated(t *testing.T) {
+	f, srv := newFakeDeluge(t, "abc")
+	c, err := newDelugeClient(srv.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Drop the session cookie, as when the WebUI session times out
+	c.http.Jar, _ = cookiejar.New(nil)
+	if _, err := c.List(ctx); err != nil {
+		t.Fatalf("List after the session expired: %v", err)
+	}
+	if n := f.count("auth.login"); n != 2 {
+		t.Errorf("logins = %d, want 2", n)
+	}
+}
+
+func TestClientAddOptionsProfileDefaults(t *testing.T) {
+	torrentPath, _ := writeTestTorrent(t, "Release.mkv")
+	contentPath := filepath.Join(filepath.Dir(torrentPath), "Release.mkv")
+	settings := AppSettings{ClientCategory: "films", ClientTags: "aatm, upload", ClientSkipChecking: true, ClientContentLayout: "Original"}
+	noSkip := false
+
+	tests := []struct {
+		name    string
+		profile TrackerProfile
+		want    AddOptions
+	}{
+		{
+			name:    "global settings",
+			profile: TrackerProfile{Name: "lacale"},
+			want: AddOptions{SavePath: filepath.Dir(contentPath), SkipChecking: true, Category: "films",
+				Tags: []string{"aatm", "upload"}, ContentLayout: "Original"},
+		},
+		{
+			name: "profile overrides",
+			profile: TrackerProfile{Name: "other", ClientCategory: "other", ClientTags: "x", ClientSkipChecking: &noSkip,
+				ClientContentLayout: "Subfolder", ClientSavePath: "/downloads"},
+			want: AddOptions{SavePath: "/downloads", Category: "other", Tags: []string{"x"}, ContentLayout: "Subfolder"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, needsLayout, err := clientAddOptions(context.Background(), torrentPath, contentPath, settings, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if needsLayout {
+				t.Error("needsLayout set for a torrent named after its content")
+			}
+			if fmt.Sprint(opts) != fmt.Sprint(tt.want) {
+				t.Errorf("opts = %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientAddOptionsNameMismatch(t *testing.T) {
+	settings := AppSettings{ClientSkipChecking: true, ClientContentLayout: "Original"}
+
+	// A single file can't be found under another name
+	torrentPath, _ := writeTestTorrent(t, "Release.mkv")
+	renamed := filepath.Join(filepath.Dir(torrentPath), "source.mkv")
+	if err := os.Rename(filepath.Join(filepath.Dir(torrentPath), "Release.mkv"), renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := clientAddOptions(context.Background(), torrentPath, renamed, settings, TrackerProfile{}); err == nil {
+		t.Error("a single-file torrent named differently from its content was accepted")
+	}
+
+	// A directory torrent is seeded from the content directory without subfolder
+	root := t.TempDir()
+	release := filepath.Join(root, "Release")
+	if err := os.MkdirAll(filepath.Join(release, "Sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(release, "a.mkv"), []byte("aaaa"), 0644)
+	os.WriteFile(filepath.Join(release, "Sub", "b.nfo"), []byte("bb"), 0644)
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(release); err != nil {
+		t.Fatal(err)
+	}
+	info.Name = "Release.2024.1080p"
+	infoBytes, _ := bencode.Marshal(info)
+	dirTorrent := filepath.Join(root, "release.torrent")
+	f, err := os.Create(dirTorrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	(&metainfo.MetaInfo{InfoBytes: infoBytes}).Write(f)
+	f.Close()
+
+	opts, needsLayout, err := clientAddOptions(context.Background(), dirTorrent, release, settings, TrackerProfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !needsLayout || opts.SavePath != release || opts.ContentLayout != "NoSubfolder" || opts.SkipChecking {
+		t.Errorf("needsLayout = %v, opts = %+v", needsLayout, opts)
+	}
+
+	// Missing files can't be seeded in place
+	os.Remove(filepath.Join(release, "Sub", "b.nfo"))
+	if _, _, err := clientAddOptions(context.Background(), dirTorrent, release, settings, TrackerProfile{}); err == nil {
+		t.Error("a directory torrent with missing files was accepted")
+	}
+}
+
+// initTestDB opens a fresh database in a temp CONFIG_DIR for the duration of the test
+func initTestDB(t *testing.T) {
+	t.Helper()
+	previousDir := dataDir
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	InitDB()
+	t.Cleanup(func() {
+		db.Close()
+		db = nil
+		dataDir = previousDir
+	})
+}
+
+func TestClientSavePath(t *testing.T) {
+	seedbox := ClientInstance{Name: "seedbox", Type: "qbittorrent", PathMappings: []ClientPathMapping{
+		{Local: "/data", Remote: "/home/user/data"},
+		{Local: "/data/films", Remote: "/mnt/films"},
+	}}
+	tests := []struct {
+		name string
+		ci   ClientInstance
+		dir  string
+		want string
+	}{
+		{"default instance", ClientInstance{Name: "qbittorrent", Type: "qbittorrent"}, "/data/films", "/data/films"},
+		{"builtin", ClientInstance{Name: "seeder", Type: "builtin"}, "/data/films", "/data/films"},
+		{"mapped", seedbox, "/data/series/Show", "/home/user/data/series/Show"},
+		{"longest prefix", seedbox, "/data/films/Movie", "/mnt/films/Movie"},
+		{"mapping root", seedbox, "/data", "/home/user/data"},
+		{"sibling prefix", seedbox, "/database/x", ""},
+		{"unmapped", seedbox, "/downloads", ""},
+		{"no mapping", ClientInstance{Name: "remote", Type: "transmission"}, "/data", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ci.clientSavePath(tt.dir); got != tt.want {
+				t.Errorf("clientSavePath(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadToTorrentClientInstances(t *testing.T) {
+	initTestDB(t)
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	contentPath := filepath.Join(filepath.Dir(torrentPath), "Release.mkv")
+	if err := recordRelease(Release{Name: "Release.mkv", SourcePath: contentPath, TorrentPath: torrentPath, InfoHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	local, localSrv := newFakeQBittorrent(t, hash)
+	seedbox, seedboxSrv := newFakeQBittorrent(t, hash)
+	remote, remoteSrv := newFakeQBittorrent(t, hash)
+	settings := AppSettings{
+		TorrentClient: "qbittorrent", QbitUrl: localSrv.URL, QbitUsername: "admin", QbitPassword: "secret",
+		ClientSkipChecking: true, ClientContentLayout: "Original",
+		ClientInstances: []ClientInstance{
+			{Name: "seedbox", Type: "qbittorrent", URL: seedboxSrv.URL, Username: "admin", Password: "secret",
+				PathMappings: []ClientPathMapping{{Local: filepath.Dir(contentPath), Remote: "/remote/data"}}},
+			{Name: "remote", Type: "qbittorrent", URL: remoteSrv.URL, Username: "admin", Password: "secret"},
+		},
+		ClientRoutes: []ClientRoute{{Clients: []string{"qbittorrent", "seedbox", "remote"}}},
+	}
+
+	results, err := NewApp().UploadToTorrentClient(context.Background(), torrentPath, contentPath, ClientRouteInput{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	checks := []struct {
+		fake     *fakeQBittorrent
+		savePath interface{}
+		skip     interface{}
+	}{
+		{local, filepath.Dir(contentPath), "true"},
+		{seedbox, "/remote/data", "true"},
+		// Without a mapping the client downloads to its default directory (both fields are omitted)
+		{remote, nil, nil},
+	}
+	for i, c := range checks {
+		args := c.fake.lastArgs("/api/v2/torrents/add")
+		if args["savepath"] != c.savePath || args["skip_checking"] != c.skip {
+			t.Errorf("%s: savepath = %v, skip_checking = %v, want %v, %v", results[i].Client, args["savepath"], args["skip_checking"], c.savePath, c.skip)
+		}
+	}
+
+	releases, err := queryReleases("info_hash = ?", hash)
+	if err != nil || len(releases) != 1 {
+		t.Fatalf("releases = %+v, err = %v", releases, err)
+	}
+	if r := releases[0]; r.Client != "qbittorrent" || strings.Join(r.Clients, ",") != "qbittorrent,seedbox,remote" {
+		t.Errorf("client = %q, clients = %v", r.Client, r.Clients)
+	}
+
+	// Removal reaches every recorded instance
+	if err := NewApp().RemoveFromTorrentClient(context.Background(), torrentPath, settings); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*fakeQBittorrent{local, seedbox, remote} {
+		if n := f.count("/api/v2/torrents/delete"); n != 1 {
+			t.Errorf("delete calls = %d, want 1", n)
+		}
+	}
+	if releases, _ := queryReleases("info_hash = ? AND client IS NOT NULL", hash); len(releases) != 0 {
+		t.Errorf("release still tracked after removal: %+v", releases)
+	}
+}
+
+func TestWatchDirClientContract(t *testing.T) {
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	ctx := context.Background()
+	dir := t.TempDir()
+	client, err := newWatchDirClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Name() != "watchdir" {
+		t.Errorf("Name() = %q", client.Name())
+	}
+
+	// Each category is a subfolder, created on the first add
+	if err := client.Add(ctx, torrentPath, AddOptions{Category: "films", SavePath: "/ignored"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	want, _ := os.ReadFile(torrentPath)
+	if got, err := os.ReadFile(filepath.Join(dir, "films", "Release.mkv.torrent")); err != nil || string(got) != string(want) {
+		t.Fatalf("torrent in the category folder: err = %v, same content %v", err, string(got) == string(want))
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "films", ".aatm-*.tmp")); len(tmp) != 0 {
+		t.Errorf("temp files left behind: %v", tmp)
+	}
+	for _, category := range []string{"..", "films/hd", `films\hd`} {
+		if err := client.Add(ctx, torrentPath, AddOptions{Category: category}); err == nil {
+			t.Errorf("category %q accepted", category)
+		}
+	}
+
+	status, err := client.Status(ctx, strings.ToUpper(hash))
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if *status != (TorrentStatus{InfoHash: hash, Name: "Release.mkv.torrent", State: "queued", Category: "films"}) {
+		t.Errorf("Status = %+v", *status)
+	}
+	if _, err := client.Status(ctx, strings.Repeat("0", 40)); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Status of an unknown torrent: err = %v, want ErrNotSupported", err)
+	}
+	if _, err := client.List(ctx); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("List: err = %v, want ErrNotSupported", err)
+	}
+	if err := client.SetCategory(ctx, hash, "series"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SetCategory: err = %v, want ErrNotSupported", err)
+	}
+	if err := client.Recheck(ctx, hash); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Recheck: err = %v, want ErrNotSupported", err)
+	}
+
+	// Remove withdraws every pending copy, then the torrent counts as picked up
+	if err := client.Add(ctx, torrentPath, AddOptions{}); err != nil {
+		t.Fatalf("Add without category: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Release.mkv.torrent")); err != nil {
+		t.Fatalf("torrent in the watch folder: %v", err)
+	}
+	if err := client.Remove(ctx, hash, false); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "*", "*.torrent")); len(left) != 0 {
+		t.Errorf("torrents left after Remove: %v", left)
+	}
+	if err := client.Remove(ctx, hash, false); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Remove of a picked up torrent: err = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestWatchDirClientFailedRename(t *testing.T) {
+	torrentPath, _ := writeTestTorrent(t, "Release.mkv")
+	dir := t.TempDir()
+	client, err := newWatchDirClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the way of the target makes the rename fail
+	if err := os.MkdirAll(filepath.Join(dir, "Release.mkv.torrent", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Add(context.Background(), torrentPath, AddOptions{}); err == nil {
+		t.Fatal("Add succeeded over a directory")
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, ".aatm-*.tmp")); len(tmp) != 0 {
+		t.Errorf("temp file left after a failed rename: %v", tmp)
+	}
+}
+
+// fakeAria2 serves the aria2 JSON-RPC methods used by aria2Client
+type fakeAria2 struct {
+	callRecorder
+	hash   string
+	secret string
+}
+
+func newFakeAria2(t *testing.T, hash, secret string) (*fakeAria2, *httptest.Server) {
+	f := &fakeAria2{hash: hash, secret: secret}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeAria2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string        `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	if r.URL.Path != "/jsonrpc" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if len(req.Params) == 0 || req.Params[0] != "token:"+f.secret {
+		w.WriteHeader(http.StatusBadRequest)
+		resp["error"] = map[string]interface{}{"code": 1, "message": "Unauthorized"}
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	params := req.Params[1:]
+	f.record(req.Method, map[string]interface{}{"params": params})
+
+	download := map[string]interface{}{
+		"gid": "2089b05ecca3d829", "status": "active", "infoHash": f.hash, "totalLength": "17", "completedLength": "17",
+		"uploadLength": "34", "dir": "/data", "bittorrent": map[string]interface{}{"info": map[string]interface{}{"name": "Release.mkv"}},
+	}
+	switch req.Method {
+	case "aria2.getVersion":
+		resp["result"] = map[string]interface{}{"version": "1.37.0", "enabledFeatures": []string{"BitTorrent"}}
+	case "aria2.addTorrent":
+		resp["result"] = "2089b05ecca3d829"
+	case "aria2.tellActive":
+		resp["result"] = []interface{}{download}
+	case "aria2.tellWaiting":
+		// A plain HTTP download is not a torrent
+		resp["result"] = []interface{}{map[string]interface{}{"gid": "1", "status": "waiting"}}
+	case "aria2.tellStopped":
+		resp["result"] = []interface{}{}
+	case "aria2.forceRemove":
+		resp["result"] = params[0]
+	case "aria2.removeDownloadResult":
+		resp["result"] = "OK"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		resp["error"] = map[string]interface{}{"code": 1, "message": "Method not found"}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestAria2ClientContract(t *testing.T) {
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	ctx := context.Background()
+	f, srv := newFakeAria2(t, hash, "s3cret")
+	client, err := newAria2Client(srv.URL+"/jsonrpc", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Name() != "aria2" {
+		t.Errorf("Name() = %q", client.Name())
+	}
+	if version, _, err := client.Version(ctx); err != nil || version != "1.37.0" {
+		t.Errorf("Version = %q, %v", version, err)
+	}
+
+	// addOptions returns the options map sent with the last aria2.addTorrent
+	addOptions := func() map[string]interface{} {
+		t.Helper()
+		params, _ := f.lastArgs("aria2.addTorrent")["params"].([]interface{})
+		if len(params) != 3 {
+			t.Fatalf("aria2.addTorrent params = %v", params)
+		}
+		data, _ := os.ReadFile(torrentPath)
+		if params[0] != base64.StdEncoding.EncodeToString(data) {
+			t.Errorf("the torrent was not sent base64 encoded")
+		}
+		options, _ := params[2].(map[string]interface{})
+		return options
+	}
+	if err := client.Add(ctx, torrentPath, AddOptions{SavePath: "/data"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if options := addOptions(); options["dir"] != "/data" || options["check-integrity"] != "true" ||
+		options["pause"] != "false" || options["bt-seed-unverified"] != nil {
+		t.Errorf("add options = %v", options)
+	}
+	if err := client.Add(ctx, torrentPath, AddOptions{SkipChecking: true, Paused: true}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if options := addOptions(); options["dir"] != nil || options["check-integrity"] != "false" ||
+		options["bt-seed-unverified"] != "true" || options["pause"] != "true" {
+		t.Errorf("add options with skip checking = %v", options)
+	}
+
+	status, err := client.Status(ctx, strings.ToUpper(hash))
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	want := TorrentStatus{InfoHash: hash, Name: "Release.mkv", State: "seeding", Progress: 1, Size: 17, Uploaded: 34, Ratio: 2, SavePath: "/data"}
+	if *status != want {
+		t.Errorf("Status = %+v, want %+v", *status, want)
+	}
+	if _, err := client.Status(ctx, strings.Repeat("0", 40)); !errors.Is(err, ErrTorrentNotFound) {
+		t.Errorf("Status of an unknown torrent: err = %v, want ErrTorrentNotFound", err)
+	}
+	if list, err := client.List(ctx); err != nil || len(list) != 1 || list[0].InfoHash != hash {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+	if err := client.SetCategory(ctx, hash, "films"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SetCategory: err = %v, want ErrNotSupported", err)
+	}
+	if err := client.Recheck(ctx, hash); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Recheck: err = %v, want ErrNotSupported", err)
+	}
+
+	if err := client.Remove(ctx, hash, true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Remove with data: err = %v, want ErrNotSupported", err)
+	}
+	if err := client.Remove(ctx, hash, false); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if f.count("aria2.forceRemove") != 1 || f.count("aria2.removeDownloadResult") != 1 {
+		t.Errorf("Remove calls = %v", f.calls)
+	}
+
+	// Without the right secret, aria2 answers an RPC error
+	bad, err := newAria2Client(srv.URL+"/jsonrpc", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rpcErr *aria2RPCError
+	if _, err := bad.List(ctx); !errors.As(err, &rpcErr) || rpcErr.Message != "Unauthorized" || rpcErr.Method != "aria2.tellActive" {
+		t.Errorf("List with a wrong secret: err = %v", err)
+	}
+}
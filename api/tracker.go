@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TrackerUploader publishes releases on a private tracker
+// Each tracker software (La-Cale, UNIT3D...) registers an implementation built from a TrackerProfile.
+type TrackerUploader interface {
+	// Name is the profile name ("lacale" for the La-Cale settings)
+	Name() string
+	// Login opens a session, or reuses a cached one; the other methods log in themselves when needed
+	Login(ctx context.Context) error
+	// MapCategory returns the tracker category of a media type ("movie", "episode"...)
+	MapCategory(mediaType string) (string, error)
+	// MapTags returns the tracker tags detected for the release
+	MapTags(mediaType string, info ReleaseInfo) ([]string, error)
+	// Search returns the releases of the tracker matching a title (dupe check)
+	Search(ctx context.Context, title string) ([]TrackerMatch, error)
+	// Upload sends the release; Category and Tags are already mapped
+	Upload(ctx context.Context, release TrackerRelease) (TrackerUploadResult, error)
+}
+
+// trackerTagValidator is implemented by the trackers able to check the tags of a release before the upload
+type trackerTagValidator interface {
+	// ValidateTags returns TagValidationErrors when the tags don't fit the category
+	ValidateTags(category string, tags []string) error
+}
+
+// TrackerRelease is a release ready to be uploaded to a tracker
+type TrackerRelease struct {
+	TorrentPath string      `json:"torrentPath"`
+	NfoPath     string      `json:"nfoPath"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	TmdbId      string      `json:"tmdbId"`
+	MediaType   string      `json:"mediaType"`
+	ReleaseInfo ReleaseInfo `json:"releaseInfo"`
+	ImdbId      string      `json:"imdbId,omitempty"`
+	TvdbId      string      `json:"tvdbId,omitempty"`
+	// SourcePath is the released file or folder; its mediainfo is sent when MediaInfo is empty
+	SourcePath      string `json:"sourcePath,omitempty"`
+	MediaInfo       string `json:"mediainfo,omitempty"`
+	PersonalRelease bool   `json:"personalRelease,omitempty"`
+	// Anonymous overrides the anonymous flag of the profile
+	Anonymous   *bool    `json:"anonymous,omitempty"`
+	TrailerURL  string   `json:"trailerUrl,omitempty"`
+	Screenshots []string `json:"screenshots,omitempty"`
+	// ExtraFields are sent as is, after the ExtraFields of the profile
+	ExtraFields map[string]string `json:"extraFields,omitempty"`
+	// Category and Tags override the mapping of the tracker when set
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"customTags,omitempty"`
+}
+
+// TrackerUploadResult is what the tracker returned for an upload
+type TrackerUploadResult struct {
+	// URL is the download link of the torrent on the tracker
+	URL string `json:"url,omitempty"`
+	// TorrentPath is the torrent rewritten by the tracker (source flag, announce URL), to seed instead of the original
+	TorrentPath string `json:"torrentPath,omitempty"`
+}
+
+// TrackerProfile is a configured tracker account
+type TrackerProfile struct {
+	Name string `json:"name"`
+	// Type is a registered tracker software: "lacale" or "unit3d"
+	Type string `json:"type"`
+	// URL is the site root; empty uses the default of the type
+	URL string `json:"url,omitempty"`
+	// Username is the login (the email on La-Cale)
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// APIKey is the UNIT3D api_token
+	APIKey    string `json:"apiKey,omitempty"`
+	Passkey   string `json:"passkey,omitempty"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+	// ExtraFields are added to every upload form
+	ExtraFields map[string]string `json:"extraFields,omitempty"`
+	// UNIT3D ids by media type ("movie", "tv"...), release type ("remux", "web-dl"...) and resolution ("1080p"...)
+	// They override the ids of a default UNIT3D install.
+	Categories  map[string]string `json:"categories,omitempty"`
+	Types       map[string]string `json:"types,omitempty"`
+	Resolutions map[string]string `json:"resolutions,omitempty"`
+	// Options used when adding the torrents of this profile to the client; empty fields use the global settings
+	ClientCategory      string `json:"clientCategory,omitempty"`
+	ClientTags          string `json:"clientTags,omitempty"` // comma separated
+	ClientSkipChecking  *bool  `json:"clientSkipChecking,omitempty"`
+	ClientContentLayout string `json:"clientContentLayout,omitempty"`
+	// ClientSavePath is the directory holding the content as seen by the client, instead of the local one
+	ClientSavePath string `json:"clientSavePath,omitempty"`
+}
+
+// trackerFactory builds the uploader of a profile
+type trackerFactory func(p TrackerProfile) (TrackerUploader, error)
+
+// trackerRegistry maps TrackerProfile.Type values to their implementation
+var trackerRegistry = map[string]trackerFactory{}
+
+// registerTracker is called from the init() of each tracker implementation
+func registerTracker(typ string, factory trackerFactory) {
+	trackerRegistry[typ] = factory
+}
+
+// registeredTrackers returns the sorted names of the registered tracker types
+func registeredTrackers() []string {
+	names := make([]string, 0, len(trackerRegistry))
+	for name := range trackerRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// trackerProfiles returns the La-Cale profile of the settings followed by the configured profiles
+func trackerProfiles(settings AppSettings) []TrackerProfile {
+	lacale := TrackerProfile{
+		Name:      "lacale",
+		Type:      "lacale",
+		URL:       settings.LaCaleUrl,
+		Username:  settings.LaCaleEmail,
+		Password:  settings.LaCalePassword,
+		Passkey:   settings.Passkey,
+		Anonymous: settings.LaCaleAnonymous,
+	}
+	return append([]TrackerProfile{lacale}, settings.TrackerProfiles...)
+}
+
+// findTrackerProfile returns the profile with this name
+func findTrackerProfile(settings AppSettings, name string) (TrackerProfile, bool) {
+	for _, p := range trackerProfiles(settings) {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return TrackerProfile{}, false
+}
+
+// newTrackerUploader builds the uploader of a profile
+func newTrackerUploader(p TrackerProfile) (TrackerUploader, error) {
+	factory, ok := trackerRegistry[p.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown tracker type: %s", p.Type)
+	}
+	return factory(p)
+}
+
+// GetTrackerUploader returns the uploader of the named tracker profile
+func (a *App) GetTrackerUploader(settings AppSettings, name string) (TrackerUploader, error) {
+	p, ok := findTrackerProfile(settings, name)
+	if !ok {
+		return nil, fmt.Errorf("unknown tracker profile %q", name)
+	}
+	return newTrackerUploader(p)
+}
+
+// UploadToTracker maps the category and tags of the release, logs in and uploads it
+func (a *App) UploadToTracker(ctx context.Context, tracker TrackerUploader, release TrackerRelease) (result TrackerUploadResult, err error) {
+	defer trackInProgress("tracker_upload")()
+	defer func() { trackerUploadsTotal.WithLabelValues(tracker.Name(), resultLabel(err)).Inc() }()
+
+	if release.Category == "" {
+		if release.Category, err = tracker.MapCategory(release.MediaType); err != nil {
+			return result, err
+		}
+	}
+	customTags := len(release.Tags) > 0
+	if customTags {
+		logInfoCtx(ctx, "UploadToTracker: using %d custom tags for %s on %s", len(release.Tags), release.ReleaseInfo.Title, tracker.Name())
+	} else {
+		if release.Tags, err = tracker.MapTags(release.MediaType, release.ReleaseInfo); err != nil {
+			return result, err
+		}
+		logInfoCtx(ctx, "UploadToTracker: matched %d tags for %s on %s", len(release.Tags), release.ReleaseInfo.Title, tracker.Name())
+	}
+	if v, ok := tracker.(trackerTagValidator); ok {
+		if err := v.ValidateTags(release.Category, release.Tags); err != nil {
+			// Detected tags only miss what the release doesn't say: the gaps are reported, the upload goes on
+			var errs TagValidationErrors
+			if customTags || !errors.As(err, &errs) {
+				return result, err
+			}
+			invalid, missing := errs.splitMissing()
+			if len(missing) > 0 {
+				logWarnCtx(ctx, "UploadToTracker: detected tags of %s on %s are incomplete: %v", release.ReleaseInfo.Title, tracker.Name(), missing)
+			}
+			if len(invalid) > 0 {
+				return result, invalid
+			}
+		}
+	}
+
+	if release.MediaInfo == "" && release.SourcePath != "" {
+		if release.MediaInfo, err = a.GetMediaInfoText(release.SourcePath); err != nil {
+			logWarnCtx(ctx, "UploadToTracker: no mediainfo for %s: %v", shortPath(release.SourcePath), err)
+		}
+	}
+
+	if err := tracker.Login(ctx); err != nil {
+		return result, fmt.Errorf("%s login failed: %w", tracker.Name(), err)
+	}
+	if result, err = tracker.Upload(ctx, release); err != nil {
+		return result, err
+	}
+	if result.TorrentPath != "" {
+		// The seeding monitor follows the torrent that will be added to the client
+		if err := replaceReleaseTorrent(release.TorrentPath, result.TorrentPath); err != nil {
+			logWarnCtx(ctx, "UploadToTracker: failed to record the torrent of %s: %v", tracker.Name(), err)
+		}
+	}
+	return result, nil
+}
+
+// trackerTorrentPath is where the torrent rewritten by a tracker is saved, next to the original
+func trackerTorrentPath(torrentPath, profile string) string {
+	return strings.TrimSuffix(torrentPath, ".torrent") + "." + profile + ".torrent"
+}
+
+// anonymous returns the anonymous flag of the release, defaulting to the one of the profile
+func (r TrackerRelease) anonymous(profileDefault bool) bool {
+	if r.Anonymous != nil {
+		return *r.Anonymous
+	}
+	return profileDefault
+}
+
+// extraFields returns the extra form fields of the profile overridden by the ones of the release
+func (r TrackerRelease) extraFields(profileFields map[string]string) map[string]string {
+	fields := make(map[string]string, len(profileFields)+len(r.ExtraFields))
+	for k, v := range profileFields {
+		fields[k] = v
+	}
+	for k, v := range r.ExtraFields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// TrackerProfileInfo describes a tracker profile without its credentials
+type TrackerProfileInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url,omitempty"`
+}
+
+// GetTrackerProfiles lists the configured tracker profiles
+func (a *App) GetTrackerProfiles() []TrackerProfileInfo {
+	profiles := trackerProfiles(a.GetSettings())
+	list := make([]TrackerProfileInfo, 0, len(profiles))
+	for _, p := range profiles {
+		list = append(list, TrackerProfileInfo{Name: p.Name, Type: p.Type, URL: p.URL})
+	}
+	return list
+}
+
+// validateTrackerProfiles checks the tracker profiles of the settings
+func validateTrackerProfiles(s AppSettings, add func(field, format string, args ...interface{})) {
+	names := map[string]bool{}
+	for i, p := range s.TrackerProfiles {
+		label := fmt.Sprintf("profile %d", i+1)
+		if p.Name != "" {
+			label = fmt.Sprintf("profile %q", p.Name)
+		}
+		_, isType := trackerRegistry[p.Name]
+		switch {
+		case p.Name == "":
+			add("trackerProfiles", "%s: name is required", label)
+		case isType:
+			add("trackerProfiles", "%s: name must not be a tracker type", label)
+		case names[p.Name]:
+			add("trackerProfiles", "%s: duplicate name", label)
+		}
+		names[p.Name] = true
+
+		if _, ok := trackerRegistry[p.Type]; !ok {
+			add("trackerProfiles", "%s: unknown tracker type %q (expected one of: %s)",
+				label, p.Type, strings.Join(registeredTrackers(), ", "))
+			continue
+		}
+		if p.URL != "" {
+			if err := validateHTTPURL(p.URL); err != nil {
+				add("trackerProfiles", "%s: %v", label, err)
+			}
+		}
+		switch p.ClientContentLayout {
+		case "", "Original", "Subfolder", "NoSubfolder":
+		default:
+			add("trackerProfiles", "%s: unknown content layout %q (expected Original, Subfolder or NoSubfolder)",
+				label, p.ClientContentLayout)
+		}
+		if p.Type == "unit3d" {
+			if p.URL == "" {
+				add("trackerProfiles", "%s: url is required", label)
+			}
+			if p.APIKey == "" {
+				add("trackerProfiles", "%s: apiKey is required", label)
+			}
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// laCaleDefaultURL is the site root used when the profile has no URL
+const laCaleDefaultURL = "https://la-cale.space"
+
+func init() {
+	registerTracker("lacale", func(p TrackerProfile) (TrackerUploader, error) {
+		return newLaCaleTracker(p), nil
+	})
+}
+
+// laCaleTracker uploads to La-Cale through its internal API, authenticated by a session cookie
+type laCaleTracker struct {
+	name        string
+	baseURL     string
+	apiURL      string
+	email       string
+	password    string
+	passkey     string
+	anonymous   bool
+	extraFields map[string]string
+}
+
+func newLaCaleTracker(p TrackerProfile) *laCaleTracker {
+	base := strings.TrimRight(p.URL, "/")
+	if base == "" {
+		base = laCaleDefaultURL
+	}
+	return &laCaleTracker{
+		name:        p.Name,
+		baseURL:     base,
+		apiURL:      base + "/api/internal",
+		email:       p.Username,
+		password:    p.Password,
+		passkey:     p.Passkey,
+		anonymous:   p.Anonymous,
+		extraFields: p.ExtraFields,
+	}
+}
+
+func (t *laCaleTracker) Name() string { return t.name }
+
+type LoginResponse struct {
+	Success bool `json:"success"`
+}
+
+// Login reuses the session of the profile (kept across restarts) and only logs in when there is none
+func (t *laCaleTracker) Login(ctx context.Context) error {
+	_, _, err := t.session(ctx)
+	return err
+}
+
+// laCaleCharacteristics returns the La-Cale category of a media type and its tag groups
+func laCaleCharacteristics(mediaType string) (string, []LocalCharacteristic, error) {
+	catalog, err := currentTagCatalog()
+	if err != nil {
+		return "", nil, err
+	}
+	categoryId, chars := catalog.Category(mediaType)
+	return categoryId, chars, nil
+}
+
+func (t *laCaleTracker) MapCategory(mediaType string) (string, error) {
+	categoryId, _, err := laCaleCharacteristics(mediaType)
+	if err != nil {
+		return "", err
+	}
+	if categoryId == "" {
+		return "", fmt.Errorf("could not find a matching category for type: %s", mediaType)
+	}
+	return categoryId, nil
+}
+
+func (t *laCaleTracker) MapTags(mediaType string, info ReleaseInfo) ([]string, error) {
+	_, chars, err := laCaleCharacteristics(mediaType)
+	if err != nil {
+		return nil, err
+	}
+	return findLocalMatchingTags(chars, info), nil
+}
+
+func (t *laCaleTracker) Search(ctx context.Context, title string) ([]TrackerMatch, error) {
+	resp, err := t.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", t.apiURL+"/torrents/search?q="+url.QueryEscape(title), nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, fmt.Errorf("search API not available (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("search failed with status %d: %s", resp.StatusCode, string(b))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseTrackerSearchResults(body)
+}
+
+func (t *laCaleTracker) Upload(ctx context.Context, release TrackerRelease) (TrackerUploadResult, error) {
+	var result TrackerUploadResult
+	if t.passkey == "" {
+		return result, fmt.Errorf("passkey is missing in settings (required for metadata)")
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	writer.WriteField("title", release.Title)
+	writer.WriteField("description", release.Description)
+	writer.WriteField("categoryId", release.Category)
+	writer.WriteField("isAnonymous", strconv.FormatBool(release.anonymous(t.anonymous)))
+	if release.TmdbId != "" {
+		writer.WriteField("tmdbId", release.TmdbId)
+		// Map simple mediaType to likely tmdb type
+		tmdbType := "MOVIE"
+		if release.MediaType == "episode" || release.MediaType == "season" {
+			tmdbType = "TV"
+		}
+		writer.WriteField("tmdbType", tmdbType)
+	}
+
+	if release.ImdbId != "" {
+		writer.WriteField("imdbId", release.ImdbId)
+	}
+	if release.TrailerURL != "" {
+		writer.WriteField("trailerUrl", release.TrailerURL)
+	}
+	for _, screenshot := range release.Screenshots {
+		writer.WriteField("screenshots", screenshot)
+	}
+	for name, value := range release.extraFields(t.extraFields) {
+		writer.WriteField(name, value)
+	}
+
+	for _, tag := range release.Tags {
+		writer.WriteField("tags", tag)
+	}
+
+	tFile, err := os.Open(release.TorrentPath)
+	if err != nil {
+		return result, err
+	}
+	defer tFile.Close()
+
+	// Create Torrent Part custom
+	h := make(map[string][]string)
+	h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="file"; filename="%s.torrent"`, release.Title)}
+	h["Content-Type"] = []string{"application/x-bittorrent"}
+	tPart, err := writer.CreatePart(h)
+	if err != nil {
+		return result, err
+	}
+	io.Copy(tPart, tFile)
+
+	// NFO
+	nFile, err := os.Open(release.NfoPath)
+	if err != nil {
+		return result, err
+	}
+	defer nFile.Close()
+
+	// Create NFO Part custom
+	hNfo := make(map[string][]string)
+	hNfo["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="nfoFile"; filename="%s.nfo"`, release.Title)}
+	hNfo["Content-Type"] = []string{"text/x-nfo"}
+	nPart, err := writer.CreatePart(hNfo)
+	if err != nil {
+		return result, err
+	}
+	io.Copy(nPart, nFile)
+
+	writer.Close()
+
+	logDebugCtx(ctx, "laCaleTracker.Upload: sending %d bytes (category %s, %d tags)", body.Len(), release.Category, len(release.Tags))
+
+	// The body is sent again if the session expired
+	uploadResp, err := t.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", t.apiURL+"/torrents/upload", bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("upload request failed: %w", err)
+	}
+	defer uploadResp.Body.Close()
+
+	respBody, err := io.ReadAll(uploadResp.Body)
+	if uploadResp.StatusCode != 200 {
+		if err != nil {
+			return result, fmt.Errorf("API Error (Status %d) - Failed to read body: %v", uploadResp.StatusCode, err)
+		}
+		return result, fmt.Errorf("API Error (Status %d) | Response: %s", uploadResp.StatusCode, string(respBody))
+	}
+
+	torrentId, link := laCaleUploadedTorrent(respBody)
+	if link == "" && torrentId != "" {
+		link = t.apiURL + "/torrents/" + url.PathEscape(torrentId) + "/download"
+	}
+	if link == "" {
+		logWarnCtx(ctx, "laCaleTracker.Upload: no torrent id in the upload response, seeding the original torrent")
+		return result, nil
+	}
+	if result.URL, err = t.downloadURL(link); err != nil {
+		logWarnCtx(ctx, "laCaleTracker.Upload: invalid download link %q: %v", link, err)
+		return result, nil
+	}
+
+	// The tracker may rewrite the announce URL or the source field, so the torrent to seed is the one it serves
+	// The upload succeeded: a failed download only keeps the original torrent
+	torrentPath := trackerTorrentPath(release.TorrentPath, t.name)
+	changed, err := t.download(ctx, result.URL, release.TorrentPath, torrentPath)
+	switch {
+	case err != nil:
+		logWarnCtx(ctx, "laCaleTracker.Upload: failed to download torrent %s from %s: %v", torrentId, t.name, err)
+	case changed:
+		result.TorrentPath = torrentPath
+		logInfoCtx(ctx, "laCaleTracker.Upload: %s rewritten by %s, torrent saved to %s", release.Title, t.name, shortPath(torrentPath))
+	default:
+		logInfoCtx(ctx, "laCaleTracker.Upload: %s kept the info hash of %s", t.name, release.Title)
+	}
+	return result, nil
+}
+
+// laCaleUploadedTorrent extracts the torrent id and download link of an upload response
+// such as {"id": 42} or {"data": {"torrent": {"id": "...", "downloadUrl": "..."}}}
+func laCaleUploadedTorrent(body []byte) (id string, link string) {
+	var res map[string]interface{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", ""
+	}
+	objects := []map[string]interface{}{res}
+	for i := 0; i < len(objects); i++ {
+		for _, key := range []string{"data", "torrent"} {
+			if nested, ok := objects[i][key].(map[string]interface{}); ok {
+				objects = append(objects, nested)
+			}
+		}
+	}
+	for _, obj := range objects {
+		if id == "" {
+			id = jsonScalar(obj["torrentId"])
+		}
+		if id == "" {
+			id = jsonScalar(obj["id"])
+		}
+		for _, key := range []string{"downloadUrl", "downloadLink", "download_url", "download"} {
+			if link == "" {
+				link = jsonScalar(obj[key])
+			}
+		}
+	}
+	return id, link
+}
+
+// jsonScalar returns a decoded JSON string or number as a string
+func jsonScalar(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// downloadURL resolves a link of the site and adds the passkey to it
+// A link to another host or scheme is kept as is, so the passkey never leaves the tracker API.
+func (t *laCaleTracker) downloadURL(link string) (string, error) {
+	base, err := url.Parse(t.baseURL + "/")
+	if err != nil {
+		return "", err
+	}
+	api, err := url.Parse(t.apiURL)
+	if err != nil {
+		return "", err
+	}
+	u, err := base.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != api.Scheme || !strings.EqualFold(u.Host, api.Host) {
+		return u.String(), nil
+	}
+	q := u.Query()
+	if q.Get("passkey") == "" {
+		q.Set("passkey", t.passkey)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// download fetches the torrent served by the tracker and saves it to path when its info hash differs from the original
+func (t *laCaleTracker) download(ctx context.Context, link string, originalPath string, path string) (bool, error) {
+	resp, err := t.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", link, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("not a torrent file: %w", err)
+	}
+	originalHash, err := torrentInfoHash(originalPath)
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(mi.HashInfoBytes().HexString(), originalHash) {
+		return false, nil
+	}
+	return true, os.WriteFile(path, data, 0644)
+}
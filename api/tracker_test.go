@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Ids of the embedded catalog
+const (
+	laCaleFilmsID   = "cmjoyv2cd00027eryreyk39gz"
+	laCaleSeriesID  = "cmjoyv2dg00067ery8m6c3q8h"
+	laCaleTag1080p  = "44d9f0e4-5ff2-4c6f-9e17-ec2e33acf448"
+	laCaleTagX264   = "cmjoyv2id000u7eryugoe1bee"
+	laCaleTagWEBDL  = "7bd8b291-6e18-4322-9c35-b3470c90039e"
+	laCaleTagFrench = "2d45b5d1-2dfe-4de5-b0fe-e4a08132d06a"
+	laCaleTagMKV    = "d5fuer9sup7s73eq24s0"
+)
+
+// testReleaseInfo is a parsed 1080p WEB-DL movie
+func testReleaseInfo() ReleaseInfo {
+	return ReleaseInfo{
+		Title: "Movie", Year: "2024", Resolution: "1080p", Source: "WEB-DL", Codec: "x264",
+		AudioCodecs: []string{"AAC"}, Language: "FRENCH", AudioLanguages: []string{"French"},
+		Container: "mkv", Genres: []string{"Action"},
+	}
+}
+
+// fakeLaCale serves the La-Cale internal API: login, upload and the download of the uploaded torrent
+type fakeLaCale struct {
+	callRecorder
+	t *testing.T
+	// rewrite makes the download serve the torrent with a source flag, as a tracker rewriting it would
+	rewrite bool
+	// uploaded is the torrent file received by the upload
+	uploaded []byte
+	nfo      []byte
+	// fields holds every value of the multipart fields of the upload
+	fields map[string][]string
+	// session is the cookie of the last login; an older one is answered 403, as after an expiry
+	session string
+	logins  int
+}
+
+func newFakeLaCale(t *testing.T, rewrite bool) (*fakeLaCale, *httptest.Server) {
+	f := &fakeLaCale{t: t, rewrite: rewrite}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeLaCale) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/internal/auth/login" {
+		var creds map[string]string
+		json.NewDecoder(r.Body).Decode(&creds)
+		f.record(r.URL.Path, nil)
+		if creds["email"] != "user@test" || creds["password"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"success": false}`)
+			return
+		}
+		f.mu.Lock()
+		f.logins++
+		f.session = fmt.Sprintf("s%d", f.logins)
+		session := f.session
+		f.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: session, Path: "/", MaxAge: 3600})
+		fmt.Fprint(w, `{"success": true}`)
+		return
+	}
+	f.mu.Lock()
+	session := f.session
+	f.mu.Unlock()
+	c, err := r.Cookie("session")
+	if err != nil {
+		f.record("401 "+r.URL.Path, nil)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if c.Value != session {
+		f.record("403 "+r.URL.Path, nil)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.record(r.URL.Path, map[string]interface{}{"passkey": r.URL.Query().Get("passkey")})
+
+	switch r.URL.Path {
+	case "/api/internal/torrents/search":
+		fmt.Fprint(w, `{"data": []}`)
+	case "/api/internal/torrents/upload":
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.fields = r.MultipartForm.Value
+		f.uploaded = readFormFile(f.t, r, "file")
+		f.nfo = readFormFile(f.t, r, "nfoFile")
+		f.mu.Unlock()
+		fmt.Fprint(w, `{"success": true, "data": {"torrent": {"id": 42}}}`)
+	case "/api/internal/torrents/42/download":
+		f.mu.Lock()
+		data := f.uploaded
+		f.mu.Unlock()
+		if f.rewrite {
+			data = rewriteTorrentSource(f.t, data, "lacale")
+		}
+		w.Header().Set("Content-Type", "application/x-bittorrent")
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func readFormFile(t *testing.T, r *http.Request, name string) []byte {
+	file, _, err := r.FormFile(name)
+	if err != nil {
+		t.Errorf("upload without %s: %v", name, err)
+		return nil
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return data
+}
+
+// rewriteTorrentSource sets the source field of the info dict, which changes the info hash
+func rewriteTorrentSource(t *testing.T, data []byte, source string) []byte {
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("uploaded torrent: %v", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.Source = source
+	if mi.InfoBytes, err = bencode.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+	mi.Announce = "https://tracker.test/announce?passkey=PK"
+	var buf bytes.Buffer
+	if err := mi.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLaCaleMapCategory(t *testing.T) {
+	tracker, err := newTrackerUploader(TrackerProfile{Name: "lacale", Type: "lacale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for mediaType, want := range map[string]string{
+		"movie": laCaleFilmsID, "episode": laCaleSeriesID, "season": laCaleSeriesID,
+	} {
+		got, err := tracker.MapCategory(mediaType)
+		if err != nil || got != want {
+			t.Errorf("MapCategory(%q) = %q, %v, want %q", mediaType, got, err, want)
+		}
+	}
+}
+
+func TestLaCaleMapTags(t *testing.T) {
+	tracker, err := newTrackerUploader(TrackerProfile{Name: "lacale", Type: "lacale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := tracker.MapTags("movie", testReleaseInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, id := range tags {
+		got[id] = true
+	}
+	for _, id := range []string{laCaleTag1080p, laCaleTagX264, laCaleTagWEBDL, laCaleTagFrench, laCaleTagMKV} {
+		if !got[id] {
+			t.Errorf("tag %s not mapped (tags: %v)", id, tags)
+		}
+	}
+	if err := tracker.(trackerTagValidator).ValidateTags(laCaleFilmsID, tags); err != nil {
+		t.Errorf("mapped tags rejected: %v", err)
+	}
+}
+
+// writeTestRelease records a release and its NFO for an upload
+func writeTestRelease(t *testing.T) (torrentPath, nfoPath, hash string) {
+	t.Helper()
+	torrentPath, hash = writeTestTorrent(t, "Movie.2024.1080p.WEB-DL.mkv")
+	nfoPath = filepath.Join(filepath.Dir(torrentPath), "Movie.nfo")
+	if err := os.WriteFile(nfoPath, []byte("nfo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordRelease(Release{Name: "Movie", TorrentPath: torrentPath, InfoHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+	return torrentPath, nfoPath, hash
+}
+
+func TestUploadToTrackerLaCaleRoundTrip(t *testing.T) {
+	initTestDB(t)
+	fake, srv := newFakeLaCale(t, true)
+	torrentPath, nfoPath, hash := writeTestRelease(t)
+
+	profile := TrackerProfile{Name: "lacale-roundtrip", Type: "lacale", URL: srv.URL,
+		Username: "user@test", Password: "secret", Passkey: "PK"}
+	tracker, err := newTrackerUploader(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewApp().UploadToTracker(context.Background(), tracker, TrackerRelease{
+		TorrentPath: torrentPath, NfoPath: nfoPath, Title: "Movie.2024.1080p.WEB-DL", Description: "desc",
+		TmdbId: "123", MediaType: "movie", ReleaseInfo: testReleaseInfo(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := fake.count("/api/internal/auth/login"); n != 1 {
+		t.Errorf("login calls = %d, want 1", n)
+	}
+	want := map[string]string{"title": "Movie.2024.1080p.WEB-DL", "description": "desc", "categoryId": laCaleFilmsID,
+		"tmdbId": "123", "tmdbType": "MOVIE", "isAnonymous": "false"}
+	for k, v := range want {
+		if got := fake.fields[k]; len(got) != 1 || got[0] != v {
+			t.Errorf("field %s = %v, want %q", k, got, v)
+		}
+	}
+	if tags := strings.Join(fake.fields["tags"], ","); !strings.Contains(tags, laCaleTag1080p) {
+		t.Errorf("tags = %s, want the mapped tags", tags)
+	}
+	if string(fake.nfo) != "nfo" {
+		t.Errorf("nfo = %q", fake.nfo)
+	}
+	if args := fake.lastArgs("/api/internal/torrents/42/download"); args["passkey"] != "PK" {
+		t.Errorf("download passkey = %v", args["passkey"])
+	}
+
+	// The rewritten torrent is saved next to the original and replaces it in the history
+	wantPath := trackerTorrentPath(torrentPath, "lacale-roundtrip")
+	if result.TorrentPath != wantPath {
+		t.Fatalf("TorrentPath = %q, want %q", result.TorrentPath, wantPath)
+	}
+	if !strings.HasPrefix(result.URL, srv.URL+"/api/internal/torrents/42/download?") {
+		t.Errorf("URL = %q", result.URL)
+	}
+	newHash, err := torrentInfoHash(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash == hash {
+		t.Fatal("the rewritten torrent has the original info hash")
+	}
+	releases, err := queryReleases("")
+	if err != nil || len(releases) != 1 {
+		t.Fatalf("releases = %+v, err = %v", releases, err)
+	}
+	if releases[0].TorrentPath != wantPath || releases[0].InfoHash != newHash {
+		t.Errorf("release = %s %s, want %s %s", releases[0].TorrentPath, releases[0].InfoHash, wantPath, newHash)
+	}
+}
+
+func TestUploadToTrackerLaCaleKeepsOriginal(t *testing.T) {
+	initTestDB(t)
+	_, srv := newFakeLaCale(t, false)
+	torrentPath, nfoPath, hash := writeTestRelease(t)
+
+	tracker, err := newTrackerUploader(TrackerProfile{Name: "lacale-same", Type: "lacale", URL: srv.URL,
+		Username: "user@test", Password: "secret", Passkey: "PK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewApp().UploadToTracker(context.Background(), tracker, TrackerRelease{
+		TorrentPath: torrentPath, NfoPath: nfoPath, Title: "Movie", MediaType: "movie", ReleaseInfo: testReleaseInfo(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same info hash: nothing to seed instead of the original
+	if result.TorrentPath != "" || result.URL == "" {
+		t.Errorf("result = %+v", result)
+	}
+	if _, err := os.Stat(trackerTorrentPath(torrentPath, "lacale-same")); !os.IsNotExist(err) {
+		t.Errorf("tracker torrent saved although unchanged: %v", err)
+	}
+	releases, _ := queryReleases("")
+	if len(releases) != 1 || releases[0].TorrentPath != torrentPath || releases[0].InfoHash != hash {
+		t.Errorf("release changed: %+v", releases)
+	}
+}
+
+func TestUploadToTrackerLaCaleBadCredentials(t *testing.T) {
+	initTestDB(t)
+	fake, srv := newFakeLaCale(t, false)
+	torrentPath, nfoPath, _ := writeTestRelease(t)
+
+	tracker, err := newTrackerUploader(TrackerProfile{Name: "lacale-badcreds", Type: "lacale", URL: srv.URL,
+		Username: "user@test", Password: "wrong", Passkey: "PK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewApp().UploadToTracker(context.Background(), tracker, TrackerRelease{
+		TorrentPath: torrentPath, NfoPath: nfoPath, Title: "Movie", MediaType: "movie", ReleaseInfo: testReleaseInfo(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("err = %v, want a login error", err)
+	}
+	if n := fake.count("/api/internal/torrents/upload"); n != 0 {
+		t.Errorf("upload calls = %d after a failed login", n)
+	}
+}
+
+func TestTrackerTorrentPath(t *testing.T) {
+	if got := trackerTorrentPath("/data/Movie.torrent", "lacale"); got != "/data/Movie.lacale.torrent" {
+		t.Errorf("trackerTorrentPath = %q", got)
+	}
+}
+
+func TestLaCaleDownloadURL(t *testing.T) {
+	tracker := newLaCaleTracker(TrackerProfile{Name: "lacale", URL: "https://la-cale.test/", Passkey: "PK"})
+	tests := []struct{ link, want string }{
+		{"/api/internal/torrents/42/download", "https://la-cale.test/api/internal/torrents/42/download?passkey=PK"},
+		{"https://LA-CALE.test/dl/42?x=1", "https://LA-CALE.test/dl/42?passkey=PK&x=1"},
+		// A passkey already in the link is kept
+		{"/dl/42?passkey=OTHER", "https://la-cale.test/dl/42?passkey=OTHER"},
+		// Another host, port or scheme never gets the passkey
+		{"https://cdn.example/42.torrent", "https://cdn.example/42.torrent"},
+		{"https://la-cale.test.evil/42.torrent", "https://la-cale.test.evil/42.torrent"},
+		{"https://la-cale.test:8443/42.torrent", "https://la-cale.test:8443/42.torrent"},
+		{"http://la-cale.test/42.torrent", "http://la-cale.test/42.torrent"},
+		{"//cdn.example/42.torrent", "https://cdn.example/42.torrent"},
+	}
+	for _, tt := range tests {
+		got, err := tracker.downloadURL(tt.link)
+		if err != nil || got != tt.want {
+			t.Errorf("downloadURL(%q) = %q, %v, want %q", tt.link, got, err, tt.want)
+		}
+	}
+	if _, err := tracker.downloadURL("http://[::1"); err == nil {
+		t.Error("invalid link accepted")
+	}
+}
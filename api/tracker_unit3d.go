@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// unit3dTimeout bounds every request to a UNIT3D tracker
+const unit3dTimeout = 60 * time.Second
+
+// Ids of a default UNIT3D install, overridden by the profile maps
+var (
+	unit3dDefaultCategories = map[string]string{"movie": "1", "tv": "2"}
+	unit3dDefaultTypes      = map[string]string{
+		"full disc": "1", "remux": "2", "encode": "3", "web-dl": "4", "webrip": "5", "hdtv": "6",
+	}
+	unit3dDefaultResolutions = map[string]string{
+		"4320p": "1", "2160p": "2", "1080p": "3", "1080i": "4", "720p": "5",
+		"576p": "6", "576i": "7", "480p": "8", "480i": "9", "other": "10",
+	}
+)
+
+func init() {
+	registerTracker("unit3d", func(p TrackerProfile) (TrackerUploader, error) {
+		return newUnit3dTracker(p)
+	})
+}
+
+// unit3dTracker uploads to a UNIT3D tracker through its REST API (/api/torrents), authenticated by api_token
+type unit3dTracker struct {
+	name        string
+	baseURL     string
+	apiToken    string
+	anonymous   bool
+	extraFields map[string]string
+	categories  map[string]string
+	types       map[string]string
+	resolutions map[string]string
+	client      *http.Client
+}
+
+func newUnit3dTracker(p TrackerProfile) (*unit3dTracker, error) {
+	if p.URL == "" {
+		return nil, fmt.Errorf("UNIT3D tracker %s has no URL", p.Name)
+	}
+	return &unit3dTracker{
+		name:        p.Name,
+		baseURL:     strings.TrimRight(p.URL, "/"),
+		apiToken:    p.APIKey,
+		anonymous:   p.Anonymous,
+		extraFields: p.ExtraFields,
+		categories:  mergeIds(unit3dDefaultCategories, p.Categories),
+		types:       mergeIds(unit3dDefaultTypes, p.Types),
+		resolutions: mergeIds(unit3dDefaultResolutions, p.Resolutions),
+		client:      &http.Client{Timeout: unit3dTimeout},
+	}, nil
+}
+
+// mergeIds returns the defaults overridden by the configured ids, with lowercase keys
+func mergeIds(defaults, configured map[string]string) map[string]string {
+	ids := make(map[string]string, len(defaults)+len(configured))
+	for k, v := range defaults {
+		ids[k] = v
+	}
+	for k, v := range configured {
+		ids[strings.ToLower(k)] = v
+	}
+	return ids
+}
+
+func (t *unit3dTracker) Name() string { return t.name }
+
+// do sends an API request with the token and decodes the JSON answer into out
+func (t *unit3dTracker) do(req *http.Request, out interface{}) error {
+	req.Header.Set("Authorization", "Bearer "+t.apiToken)
+	// Without it, Laravel answers authentication errors with a redirect to the login page
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("invalid API token (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API Error (Status %d) | Response: %s", resp.StatusCode, unit3dErrorMessage(body))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// unit3dErrorMessage extracts the message and field errors of a failed API call
+func unit3dErrorMessage(body []byte) string {
+	var res struct {
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.Message == "" {
+		return string(body)
+	}
+	if len(res.Data) > 0 && string(res.Data) != "null" {
+		return res.Message + ": " + string(res.Data)
+	}
+	return res.Message
+}
+
+func (t *unit3dTracker) Login(ctx context.Context) error {
+	if t.apiToken == "" {
+		return fmt.Errorf("API token is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", t.baseURL+"/api/torrents/filter?perPage=1", nil)
+	if err != nil {
+		return err
+	}
+	return t.do(req, nil)
+}
+
+func (t *unit3dTracker) MapCategory(mediaType string) (string, error) {
+	key := mediaType
+	if mediaType == "episode" || mediaType == "season" {
+		if id, ok := t.categories[mediaType]; ok {
+			return id, nil
+		}
+		key = "tv"
+	}
+	id, ok := t.categories[key]
+	if !ok {
+		return "", fmt.Errorf("no UNIT3D category configured for type: %s", mediaType)
+	}
+	return id, nil
+}
+
+// MapTags returns nothing: UNIT3D describes releases with type and resolution ids instead of tags
+func (t *unit3dTracker) MapTags(mediaType string, info ReleaseInfo) ([]string, error) {
+	return nil, nil
+}
+
+// unit3dSourceTypes maps the detected sources, normalized by normalizeTagName, to the UNIT3D types
+// A bare "WEB" is how most WEB-DL releases are named.
+var unit3dSourceTypes = map[string]string{
+	"remux":          "remux",
+	"fulldisc":       "full disc",
+	"completedisc":   "full disc",
+	"completebluray": "full disc",
+	"bdmv":           "full disc",
+	"webdl":          "web-dl",
+	"web":            "web-dl",
+	"webrip":         "webrip",
+	"hdtv":           "hdtv",
+}
+
+// unit3dTypePriority orders the types when several sources are detected ("WEB" and "WEBRip" is a WEBRip)
+var unit3dTypePriority = []string{"remux", "full disc", "webrip", "web-dl", "hdtv"}
+
+// unit3dReleaseType returns the UNIT3D type ("remux", "web-dl"...) of the release
+// The sources are the ones of the tag matching, compared as whole values so that "WEBRip" is not a WEB-DL.
+func unit3dReleaseType(info ReleaseInfo) string {
+	sources := append(enhanceSourceDetection(info.Source, info.ReleaseGroup, info.Tags), info.Tags...)
+	found := map[string]bool{}
+	for _, source := range sources {
+		if typ, ok := unit3dSourceTypes[normalizeTagName(source)]; ok {
+			found[typ] = true
+		}
+	}
+	for _, typ := range unit3dTypePriority {
+		if found[typ] {
+			return typ
+		}
+	}
+	// BluRay, BDRip, DVDRip...: re-encoded releases
+	return "encode"
+}
+
+// numericId keeps the digits of an id such as "tt0111161" or "S02"; empty gives "0"
+func numericId(id string) string {
+	digits := strings.TrimLeftFunc(id, func(r rune) bool { return r < '0' || r > '9' })
+	if n, err := strconv.Atoi(digits); err == nil {
+		return strconv.Itoa(n)
+	}
+	return "0"
+}
+
+func boolField(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (t *unit3dTracker) Search(ctx context.Context, title string) ([]TrackerMatch, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", t.baseURL+"/api/torrents/filter?perPage=25&name="+url.QueryEscape(title), nil)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := t.do(req, &raw); err != nil {
+		return nil, err
+	}
+	return parseTrackerSearchResults(raw)
+}
+
+func (t *unit3dTracker) Upload(ctx context.Context, release TrackerRelease) (TrackerUploadResult, error) {
+	var result TrackerUploadResult
+	releaseType := unit3dReleaseType(release.ReleaseInfo)
+	typeId, ok := t.types[releaseType]
+	if !ok {
+		return result, fmt.Errorf("no UNIT3D type configured for %q", releaseType)
+	}
+	resolution := strings.ToLower(release.ReleaseInfo.Resolution)
+	resolutionId, ok := t.resolutions[resolution]
+	if !ok {
+		resolutionId, ok = t.resolutions["other"]
+		if !ok {
+			return result, fmt.Errorf("no UNIT3D resolution configured for %q", release.ReleaseInfo.Resolution)
+		}
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fields := [][2]string{
+		{"name", release.Title},
+		{"description", release.Description},
+		{"mediainfo", release.MediaInfo},
+		{"category_id", release.Category},
+		{"type_id", typeId},
+		{"resolution_id", resolutionId},
+		{"tmdb", numericId(release.TmdbId)},
+		{"imdb", numericId(release.ImdbId)},
+		{"tvdb", numericId(release.TvdbId)},
+		{"mal", "0"},
+		{"igdb", "0"},
+		{"anonymous", boolField(release.anonymous(t.anonymous))},
+		{"personal_release", boolField(release.PersonalRelease)},
+		{"stream", "0"},
+		{"sd", boolField(resolution == "576p" || resolution == "576i" || resolution == "480p" || resolution == "480i")},
+		{"internal", "0"},
+	}
+	if release.MediaType == "episode" || release.MediaType == "season" {
+		episode := "0" // season pack
+		if release.MediaType == "episode" {
+			episode = numericId(release.ReleaseInfo.Episode)
+		}
+		fields = append(fields,
+			[2]string{"season_number", numericId(release.ReleaseInfo.Season)},
+			[2]string{"episode_number", episode})
+	}
+	for _, f := range fields {
+		writer.WriteField(f[0], f[1])
+	}
+	for name, value := range release.extraFields(t.extraFields) {
+		writer.WriteField(name, value)
+	}
+
+	files := []struct{ field, path string }{{"torrent", release.TorrentPath}, {"nfo", release.NfoPath}}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		file, err := os.Open(f.path)
+		if err != nil {
+			return result, err
+		}
+		part, err := writer.CreateFormFile(f.field, filepath.Base(f.path))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		file.Close()
+		if err != nil {
+			return result, err
+		}
+	}
+	writer.Close()
+
+	logDebugCtx(ctx, "unit3dTracker.Upload: sending %d bytes to %s (category %s, type %s, resolution %s)",
+		body.Len(), t.name, release.Category, typeId, resolutionId)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/api/torrents/upload", body)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	var res struct {
+		Success bool   `json:"success"`
+		Data    string `json:"data"`
+		Message string `json:"message"`
+	}
+	if err := t.do(req, &res); err != nil {
+		return result, err
+	}
+	if !res.Success {
+		return result, fmt.Errorf("upload refused: %s", res.Message)
+	}
+	result.URL = res.Data
+	if result.URL == "" {
+		return result, nil
+	}
+
+	// The tracker adds its source flag, so the torrent to seed has another info hash
+	// The upload succeeded: a failed download only keeps the original torrent
+	torrentPath := trackerTorrentPath(release.TorrentPath, t.name)
+	if err := t.download(ctx, result.URL, torrentPath); err != nil {
+		logWarnCtx(ctx, "unit3dTracker.Upload: failed to download the torrent of %s from %s, seeding the original: %v",
+			release.Title, t.name, err)
+		return result, nil
+	}
+	result.TorrentPath = torrentPath
+	logInfoCtx(ctx, "unit3dTracker.Upload: %s uploaded to %s, torrent saved to %s", release.Title, t.name, shortPath(torrentPath))
+	return result, nil
+}
+
+// download saves the torrent at link (which carries the rsskey) to path
+func (t *unit3dTracker) download(ctx context.Context, link string, path string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if _, err := metainfo.Load(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("not a torrent file: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
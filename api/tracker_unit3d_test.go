@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeUnit3d serves the upload and download endpoints of the UNIT3D API
+type fakeUnit3d struct {
+	callRecorder
+	t *testing.T
+	// downloadStatus is the status of the torrent download, 200 serving the uploaded torrent with a source flag
+	downloadStatus int
+	uploaded       []byte
+}
+
+func newFakeUnit3d(t *testing.T, downloadStatus int) (*fakeUnit3d, *httptest.Server) {
+	f := &fakeUnit3d{t: t, downloadStatus: downloadStatus}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeUnit3d) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/torrents/upload":
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "Unauthenticated."}`)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args := map[string]interface{}{}
+		for k, v := range r.MultipartForm.Value {
+			args[k] = v[0]
+		}
+		f.record(r.URL.Path, args)
+		f.mu.Lock()
+		f.uploaded = readFormFile(f.t, r, "torrent")
+		f.mu.Unlock()
+		fmt.Fprintf(w, `{"success": true, "data": "http://%s/torrent/download/7.rsskey", "message": "Torrent uploaded successfully."}`, r.Host)
+	case "/torrent/download/7.rsskey":
+		f.record(r.URL.Path, nil)
+		if f.downloadStatus != http.StatusOK {
+			w.WriteHeader(f.downloadStatus)
+			return
+		}
+		f.mu.Lock()
+		data := f.uploaded
+		f.mu.Unlock()
+		w.Write(rewriteTorrentSource(f.t, data, "unit3d"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestUnit3dReleaseType(t *testing.T) {
+	tests := []struct {
+		info ReleaseInfo
+		want string
+	}{
+		{ReleaseInfo{Source: "WEB"}, "web-dl"},
+		{ReleaseInfo{Source: "WEB-DL"}, "web-dl"},
+		{ReleaseInfo{Source: "WEBDL"}, "web-dl"},
+		{ReleaseInfo{Source: "WEBRip"}, "webrip"},
+		{ReleaseInfo{Source: "WEB", Tags: []string{"WEBRip"}}, "webrip"},
+		{ReleaseInfo{Source: "BluRay", Tags: []string{"REMUX"}}, "remux"},
+		{ReleaseInfo{Source: "BluRay", Tags: []string{"BDMV"}}, "full disc"},
+		{ReleaseInfo{Source: "BluRay", Tags: []string{"COMPLETE.BLURAY"}}, "full disc"},
+		{ReleaseInfo{Source: "HDTV"}, "hdtv"},
+		{ReleaseInfo{Source: "BluRay"}, "encode"},
+		{ReleaseInfo{Source: "DVDRip"}, "encode"},
+		// Words merely containing a source name don't count
+		{ReleaseInfo{Source: "BluRay", ReleaseGroup: "WEBSTER"}, "encode"},
+		{ReleaseInfo{Source: "BluRay", Tags: []string{"WEBCAM"}}, "encode"},
+	}
+	for _, tt := range tests {
+		if got := unit3dReleaseType(tt.info); got != tt.want {
+			t.Errorf("unit3dReleaseType(%+v) = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestNumericId(t *testing.T) {
+	for in, want := range map[string]string{"tt0111161": "111161", "S02": "2", "E10": "10", "603": "603", "": "0", "abc": "0"} {
+		if got := numericId(in); got != want {
+			t.Errorf("numericId(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// uploadToFakeUnit3d uploads a release to the fake and returns the received fields
+func uploadToFakeUnit3d(t *testing.T, downloadStatus int, release TrackerRelease) (TrackerUploadResult, map[string]interface{}, error) {
+	t.Helper()
+	fake, srv := newFakeUnit3d(t, downloadStatus)
+	tracker, err := newTrackerUploader(TrackerProfile{Name: "blutopia", Type: "unit3d", URL: srv.URL, APIKey: "token",
+		Resolutions: map[string]string{"other": "10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.TorrentPath == "" {
+		release.TorrentPath, _ = writeTestTorrent(t, "Release.mkv")
+	}
+	if release.Category == "" {
+		release.Category, _ = tracker.MapCategory(release.MediaType)
+	}
+	result, err := tracker.Upload(context.Background(), release)
+	return result, fake.lastArgs("/api/torrents/upload"), err
+}
+
+func TestUnit3dUploadFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		release TrackerRelease
+		want    map[string]interface{}
+		absent  []string
+	}{
+		{
+			name: "movie",
+			release: TrackerRelease{Title: "Movie.2024.1080p.WEB.H264", MediaType: "movie", TmdbId: "603", ImdbId: "tt0133093",
+				ReleaseInfo: ReleaseInfo{Resolution: "1080p", Source: "WEB"}},
+			want: map[string]interface{}{"name": "Movie.2024.1080p.WEB.H264", "category_id": "1", "type_id": "4",
+				"resolution_id": "3", "tmdb": "603", "imdb": "133093", "tvdb": "0", "sd": "0", "anonymous": "0"},
+			absent: []string{"season_number", "episode_number"},
+		},
+		{
+			name: "episode",
+			release: TrackerRelease{Title: "Show.S02E05.720p.HDTV", MediaType: "episode", TvdbId: "81189",
+				ReleaseInfo: ReleaseInfo{Resolution: "720p", Source: "HDTV", Season: "S02", Episode: "E05"}},
+			want: map[string]interface{}{"category_id": "2", "type_id": "6", "resolution_id": "5", "tvdb": "81189",
+				"season_number": "2", "episode_number": "5"},
+		},
+		{
+			name: "season pack",
+			release: TrackerRelease{Title: "Show.S03.2160p.BluRay.REMUX", MediaType: "season",
+				ReleaseInfo: ReleaseInfo{Resolution: "2160p", Source: "BluRay", Tags: []string{"REMUX"}, Season: "S03"}},
+			want: map[string]interface{}{"category_id": "2", "type_id": "2", "resolution_id": "2",
+				"season_number": "3", "episode_number": "0"},
+		},
+		{
+			name: "other resolution",
+			release: TrackerRelease{Title: "Old.Movie.DVDRip", MediaType: "movie",
+				ReleaseInfo: ReleaseInfo{Resolution: "360p", Source: "DVDRip"}},
+			want: map[string]interface{}{"type_id": "3", "resolution_id": "10", "sd": "0"},
+		},
+		{
+			name: "sd",
+			release: TrackerRelease{Title: "Old.Movie.480p", MediaType: "movie", Anonymous: new(bool),
+				ReleaseInfo: ReleaseInfo{Resolution: "480p", Source: "WEBRip"}},
+			want: map[string]interface{}{"type_id": "5", "resolution_id": "8", "sd": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args, err := uploadToFakeUnit3d(t, http.StatusOK, tt.release)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if args[k] != v {
+					t.Errorf("field %s = %v, want %v", k, args[k], v)
+				}
+			}
+			for _, k := range tt.absent {
+				if _, ok := args[k]; ok {
+					t.Errorf("unexpected field %s = %v", k, args[k])
+				}
+			}
+		})
+	}
+}
+
+func TestUnit3dUploadUnknownResolution(t *testing.T) {
+	_, srv := newFakeUnit3d(t, http.StatusOK)
+	tracker, err := newUnit3dTracker(TrackerProfile{Name: "blutopia", Type: "unit3d", URL: srv.URL, APIKey: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without an "other" id, an unknown resolution can't be sent
+	delete(tracker.resolutions, "other")
+	torrentPath, _ := writeTestTorrent(t, "Release.mkv")
+	_, err = tracker.Upload(context.Background(), TrackerRelease{TorrentPath: torrentPath, MediaType: "movie", Category: "1",
+		ReleaseInfo: ReleaseInfo{Resolution: "360p"}})
+	if err == nil {
+		t.Error("upload with an unknown resolution and no fallback succeeded")
+	}
+}
+
+func TestUnit3dUploadDownloadsTorrent(t *testing.T) {
+	torrentPath, hash := writeTestTorrent(t, "Release.mkv")
+	result, _, err := uploadToFakeUnit3d(t, http.StatusOK, TrackerRelease{TorrentPath: torrentPath, Title: "Release",
+		MediaType: "movie", ReleaseInfo: ReleaseInfo{Resolution: "1080p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := trackerTorrentPath(torrentPath, "blutopia")
+	if result.TorrentPath != want || filepath.Base(result.URL) != "7.rsskey" {
+		t.Fatalf("result = %+v, want TorrentPath %s", result, want)
+	}
+	newHash, err := torrentInfoHash(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash == hash {
+		t.Error("the downloaded torrent has the original info hash")
+	}
+}
+
+func TestUnit3dUploadDownloadFailureKeepsOriginal(t *testing.T) {
+	torrentPath, _ := writeTestTorrent(t, "Release.mkv")
+	result, _, err := uploadToFakeUnit3d(t, http.StatusInternalServerError, TrackerRelease{TorrentPath: torrentPath,
+		Title: "Release", MediaType: "movie", ReleaseInfo: ReleaseInfo{Resolution: "1080p"}})
+	if err != nil {
+		t.Fatalf("a failed download failed the upload: %v", err)
+	}
+	if result.URL == "" || result.TorrentPath != "" {
+		t.Errorf("result = %+v, want the URL and no torrent path", result)
+	}
+	if _, err := os.Stat(trackerTorrentPath(torrentPath, "blutopia")); !os.IsNotExist(err) {
+		t.Errorf("torrent saved after a failed download: %v", err)
+	}
+}
+
+func TestUnit3dUploadBadToken(t *testing.T) {
+	_, srv := newFakeUnit3d(t, http.StatusOK)
+	tracker, err := newUnit3dTracker(TrackerProfile{Name: "blutopia", Type: "unit3d", URL: srv.URL, APIKey: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	torrentPath, _ := writeTestTorrent(t, "Release.mkv")
+	_, err = tracker.Upload(context.Background(), TrackerRelease{TorrentPath: torrentPath, MediaType: "movie", Category: "1",
+		ReleaseInfo: ReleaseInfo{Resolution: "1080p"}})
+	if err == nil {
+		t.Error("upload with an invalid token succeeded")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// xmlrpcFault is a <fault> answer of an XML-RPC server
+type xmlrpcFault struct {
+	Code    int
+	Message string
+}
+
+func (f *xmlrpcFault) Error() string {
+	return fmt.Sprintf("XML-RPC fault %d: %s", f.Code, f.Message)
+}
+
+// encodeXMLRPCCall builds a <methodCall> document
+// Supported parameter types: string, int, int64, bool, float64, []byte (base64) and []interface{}/[]string (array).
+func encodeXMLRPCCall(method string, params ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0"?><methodCall><methodName>`)
+	xml.EscapeText(&buf, []byte(method))
+	buf.WriteString(`</methodName><params>`)
+	for _, p := range params {
+		buf.WriteString("<param>")
+		if err := encodeXMLRPCValue(&buf, p); err != nil {
+			return nil, err
+		}
+		buf.WriteString("</param>")
+	}
+	buf.WriteString(`</params></methodCall>`)
+	return buf.Bytes(), nil
+}
+
+func encodeXMLRPCValue(buf *bytes.Buffer, v interface{}) error {
+	buf.WriteString("<value>")
+	switch val := v.(type) {
+	case string:
+		buf.WriteString("<string>")
+		xml.EscapeText(buf, []byte(val))
+		buf.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buf, "<i4>%d</i4>", val)
+	case int64:
+		fmt.Fprintf(buf, "<i8>%d</i8>", val)
+	case bool:
+		if val {
+			buf.WriteString("<boolean>1</boolean>")
+		} else {
+			buf.WriteString("<boolean>0</boolean>")
+		}
+	case float64:
+		fmt.Fprintf(buf, "<double>%s</double>", strconv.FormatFloat(val, 'f', -1, 64))
+	case []byte:
+		buf.WriteString("<base64>")
+		buf.WriteString(base64.StdEncoding.EncodeToString(val))
+		buf.WriteString("</base64>")
+	case []string:
+		buf.WriteString("<array><data>")
+		for _, item := range val {
+			if err := encodeXMLRPCValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	case []interface{}:
+		buf.WriteString("<array><data>")
+		for _, item := range val {
+			if err := encodeXMLRPCValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	default:
+		return fmt.Errorf("unsupported XML-RPC parameter type %T", v)
+	}
+	buf.WriteString("</value>")
+	return nil
+}
+
+// xmlrpcValue mirrors a <value> element; only one of the typed fields is set
+type xmlrpcValue struct {
+	String  *string `xml:"string"`
+	Int     *string `xml:"int"`
+	I4      *string `xml:"i4"`
+	I8      *string `xml:"i8"`
+	Boolean *string `xml:"boolean"`
+	Double  *string `xml:"double"`
+	Base64  *string `xml:"base64"`
+	Array   *struct {
+		Values []xmlrpcValue `xml:"data>value"`
+	} `xml:"array"`
+	Struct *struct {
+		Members []struct {
+			Name  string      `xml:"name"`
+			Value xmlrpcValue `xml:"value"`
+		} `xml:"member"`
+	} `xml:"struct"`
+	// A value without type element is a string
+	Text string `xml:",chardata"`
+}
+
+// native converts the value to string, int64, bool, float64, []byte, []interface{} or map[string]interface{}
+func (v xmlrpcValue) native() (interface{}, error) {
+	switch {
+	case v.String != nil:
+		return *v.String, nil
+	case v.Int != nil, v.I4 != nil, v.I8 != nil:
+		raw := v.Int
+		if raw == nil {
+			raw = v.I4
+		}
+		if raw == nil {
+			raw = v.I8
+		}
+		return strconv.ParseInt(strings.TrimSpace(*raw), 10, 64)
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1", nil
+	case v.Double != nil:
+		return strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+	case v.Base64 != nil:
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(*v.Base64))
+	case v.Array != nil:
+		items := make([]interface{}, 0, len(v.Array.Values))
+		for _, item := range v.Array.Values {
+			n, err := item.native()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, n)
+		}
+		return items, nil
+	case v.Struct != nil:
+		m := map[string]interface{}{}
+		for _, member := range v.Struct.Members {
+			n, err := member.Value.native()
+			if err != nil {
+				return nil, err
+			}
+			m[member.Name] = n
+		}
+		return m, nil
+	default:
+		return v.Text, nil
+	}
+}
+
+// decodeXMLRPCResponse parses a <methodResponse>, returning the first param or the fault as error
+func decodeXMLRPCResponse(data []byte) (interface{}, error) {
+	var resp struct {
+		Params []xmlrpcValue `xml:"params>param>value"`
+		Fault  *xmlrpcValue  `xml:"fault>value"`
+	}
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid XML-RPC response: %w", err)
+	}
+	if resp.Fault != nil {
+		f, err := resp.Fault.native()
+		if err != nil {
+			return nil, err
+		}
+		fault := &xmlrpcFault{Message: fmt.Sprint(f)}
+		if m, ok := f.(map[string]interface{}); ok {
+			fault.Message = fmt.Sprint(m["faultString"])
+			if code, ok := m["faultCode"].(int64); ok {
+				fault.Code = int(code)
+			}
+		}
+		return nil, fault
+	}
+	if len(resp.Params) == 0 {
+		return nil, nil
+	}
+	return resp.Params[0].native()
+}